@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
+)
+
+// envCacheDir overrides the default cache directory.
+const envCacheDir = "ITURTLE_CACHE_DIR"
+
+// cacheFlags holds the response cache settings given on the command line.
+type cacheFlags struct {
+	dir     string
+	ttl     time.Duration
+	offline bool
+	disable bool
+}
+
+// register adds the cache flags to fs.
+func (f *cacheFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.dir, "cache-dir", "", "Directory for cached MusicBrainz responses and covers (env "+envCacheDir+", default: user cache dir)")
+	fs.DurationVar(&f.ttl, "cache-ttl", cache.DefaultTTL, "How long cached responses are used before revalidating them")
+	fs.BoolVar(&f.offline, "offline", false, "Only use cached responses, never query MusicBrainz or download covers")
+	fs.BoolVar(&f.disable, "no-cache", false, "Disable the response cache")
+}
+
+// open returns the configured cache, or nil when caching is disabled.
+func (f cacheFlags) open() (*cache.Cache, error) {
+	if f.disable {
+		if f.offline {
+			return nil, fmt.Errorf("-offline needs the cache, but -no-cache was given")
+		}
+		return nil, nil
+	}
+
+	dir, err := f.resolveDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir, cache.WithTTL(f.ttl), cache.WithOffline(f.offline))
+}
+
+// resolveDir applies flag > env > default precedence to the cache directory.
+func (f cacheFlags) resolveDir() (string, error) {
+	if dir := strings.TrimSpace(f.dir); dir != "" {
+		return dir, nil
+	}
+	if dir := strings.TrimSpace(os.Getenv(envCacheDir)); dir != "" {
+		return dir, nil
+	}
+	return cache.DefaultDir()
+}
+
+// runCacheCommand implements "iturtle-smart-fetcher cache stats|clear".
+func runCacheCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	var flags cacheFlags
+	flags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: iturtle-smart-fetcher cache stats|clear [-cache-dir DIR]\n\n")
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing cache command")
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	dir, err := flags.resolveDir()
+	if err != nil {
+		return err
+	}
+	c, err := cache.New(dir, cache.WithTTL(flags.ttl))
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "stats":
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "📦 Cache: %s\n", stats.Dir)
+		fmt.Fprintf(out, "   %d entries (%d expired), %s\n", stats.Entries, stats.Expired, formatBytes(stats.Bytes))
+	case "clear":
+		removed, err := c.Clear()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "🧹 Removed %d cached response(s) from %s\n", removed, c.Dir())
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown cache command %q", command)
+	}
+	return nil
+}
+
+// formatBytes renders a size such as "1.5 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
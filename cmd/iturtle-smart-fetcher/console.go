@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// console is where the messages about one album go. Albums processed in
+// parallel each get their own buffer, so that their messages do not
+// interleave.
+type console struct {
+	out io.Writer // Progress and results
+	err io.Writer // Warnings and errors
+}
+
+// stdio is the console of the standard output and error streams.
+var stdio = console{out: os.Stdout, err: os.Stderr}
+
+// bufferedConsole returns a console writing all messages to w.
+func bufferedConsole(w io.Writer) console {
+	return console{out: w, err: w}
+}
+
+// log returns where metadata providers report to: nil, for the standard
+// streams, or the console's single writer.
+func (c console) log() io.Writer {
+	if c == stdio {
+		return nil
+	}
+	return c.out
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+	"iturtle-smart-fetcher/internal/provider"
+)
+
+// Progress states of a release group in discography mode.
+const (
+	groupPending    = "pending"
+	groupSkipped    = "skipped"
+	groupNoMetadata = "lookup failed"
+	groupDone       = "downloaded"
+	groupFailed     = "download failed"
+)
+
+// groupProgress tracks one release group through discography mode.
+type groupProgress struct {
+	group  musicbrainz.ReleaseGroup
+	status string
+	detail string
+}
+
+// discographyOptions holds the settings of a discography run.
+type discographyOptions struct {
+	artist    string   // Artist name or MBID
+	types     []string // Release group types to list
+	outputDir string   // Each album goes into a subdirectory named after it
+	edition   musicbrainz.Edition
+	picker    *musicbrainz.Picker
+}
+
+// runDiscographyMode lists an artist's release groups, lets the user choose
+// which to fetch and where from, resolves their metadata and downloads them
+// through the batch pipeline.
+func runDiscographyMode(ctx context.Context, dopts discographyOptions, opts batchOptions) error {
+	artist, err := resolveArtist(ctx, opts.client, dopts.artist)
+	if err != nil {
+		return err
+	}
+
+	all, err := opts.client.ArtistReleaseGroups(ctx, artist.ID)
+	if err != nil {
+		return fmt.Errorf("browse release groups: %w", err)
+	}
+	if artist.Name == artist.ID {
+		// Looked up by MBID; the release groups carry the name
+		for _, group := range all {
+			if name := musicbrainz.GetArtistName(group.ArtistCredit); name != "" {
+				artist.Name = name
+				break
+			}
+		}
+	}
+
+	groups := musicbrainz.FilterReleaseGroups(all, dopts.types)
+	if len(groups) == 0 {
+		return fmt.Errorf("%s has no release groups of type %s", artist.Name, strings.Join(dopts.types, ", "))
+	}
+	fmt.Fprintf(os.Stdout, "💿 %d of %d release groups match %s\n", len(groups), len(all), strings.Join(dopts.types, ", "))
+
+	chosen, err := dopts.picker.ChooseReleaseGroups(groups)
+	if err != nil {
+		if errors.Is(err, musicbrainz.ErrNoSelection) {
+			fmt.Fprintf(os.Stdout, "Nothing selected.\n")
+			return nil
+		}
+		return err
+	}
+
+	progress := make([]*groupProgress, len(chosen))
+	for i, group := range chosen {
+		progress[i] = &groupProgress{group: group, status: groupPending}
+	}
+
+	// Ask for every source up front so the downloads can run unattended
+	fmt.Fprintf(os.Stdout, "\nPaste a YouTube playlist URL for each release group (Enter = find the album on YouTube, s = skip):\n")
+	urls := make([]string, len(chosen))
+	for i, group := range chosen {
+		urls[i], err = dopts.picker.Ask(fmt.Sprintf("  %s (%s): ", group.Title, musicbrainz.ExtractYear(group.FirstReleaseDate)))
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(urls[i], "s") {
+			progress[i].status = groupSkipped
+		}
+	}
+
+	batchCfg := &config.BatchConfig{}
+	resolved := map[int]*provider.Merged{}
+	albumGroup := map[int]*groupProgress{}
+
+	for i, group := range chosen {
+		if progress[i].status == groupSkipped {
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "\n🔎 Resolving %s (%d/%d)\n", group.Title, i+1, len(chosen))
+
+		req := provider.Request{
+			ReleaseGroupID: group.ID,
+			Artist:         artist.Name,
+			Album:          group.Title,
+			URL:            urls[i],
+			Edition:        dopts.edition,
+			Flags:          opts.flags,
+			Cover:          opts.cover,
+		}
+		if urls[i] != "" {
+			describeSource(ctx, stdio, opts.downloader, opts.paths.YtDLP, &req)
+		}
+		merged, err := fetchMetadata(ctx, stdio, opts.metadata, req)
+		if err != nil {
+			progress[i].status = groupNoMetadata
+			progress[i].detail = err.Error()
+			fmt.Fprintf(os.Stderr, "⚠️  Metadata lookup failed: %v\n", err)
+			continue
+		}
+
+		index := len(batchCfg.Albums)
+		batchCfg.Albums = append(batchCfg.Albums, config.AlbumConfig{
+			URL:           urls[i],
+			Artist:        artist.Name,
+			Album:         group.Title,
+			MusicBrainzID: merged.ID(provider.NameMusicBrainz),
+			OutputDir:     filepath.Join(dopts.outputDir, downloader.SanitizeName(group.Title, opts.naming != nil && opts.naming.ASCII)),
+		})
+		resolved[index] = merged
+		albumGroup[index] = progress[i]
+	}
+
+	if len(batchCfg.Albums) > 0 {
+		opts.resolved = resolved
+		opts.onAlbumDone = func(index int, err error) {
+			p := albumGroup[index]
+			if err != nil {
+				p.status, p.detail = groupFailed, err.Error()
+			} else {
+				p.status = groupDone
+			}
+			printDiscographyProgress(progress)
+		}
+
+		fmt.Fprintf(os.Stdout, "\n")
+		// Failures are reported per release group below
+		_ = runBatchMode(ctx, "", batchCfg, opts)
+	}
+
+	return discographySummary(artist.Name, progress)
+}
+
+// resolveArtist looks up the artist by MBID or picks the best name match.
+func resolveArtist(ctx context.Context, client *musicbrainz.Client, query string) (*musicbrainz.Artist, error) {
+	query = strings.TrimSpace(query)
+	if musicbrainz.IsMBID(query) {
+		return &musicbrainz.Artist{ID: query, Name: query}, nil
+	}
+
+	results, err := client.SearchArtists(ctx, query, 5)
+	if err != nil {
+		return nil, fmt.Errorf("search artists: %w", err)
+	}
+	if len(results.Artists) == 0 {
+		return nil, fmt.Errorf("no artist found for %q", query)
+	}
+
+	artist := results.Artists[0]
+	fmt.Fprintf(os.Stdout, "👤 Artist: %s", artist.Name)
+	if artist.Disambiguation != "" {
+		fmt.Fprintf(os.Stdout, " (%s)", artist.Disambiguation)
+	}
+	fmt.Fprintf(os.Stdout, " (%s)\n", artist.ID)
+	return &artist, nil
+}
+
+// printDiscographyProgress prints how many release groups are finished.
+func printDiscographyProgress(progress []*groupProgress) {
+	done, failed := 0, 0
+	for _, p := range progress {
+		switch p.status {
+		case groupDone, groupSkipped:
+			done++
+		case groupNoMetadata, groupFailed:
+			failed++
+		}
+	}
+	fmt.Fprintf(os.Stdout, "📊 Discography progress: %d/%d release groups finished", done+failed, len(progress))
+	if failed > 0 {
+		fmt.Fprintf(os.Stdout, " (%d failed)", failed)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+}
+
+// discographySummary prints the final state of every release group and
+// returns an error if any of them failed.
+func discographySummary(artist string, progress []*groupProgress) error {
+	fmt.Fprintf(os.Stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(os.Stdout, "Discography of %s:\n", artist)
+
+	failed := 0
+	for _, p := range progress {
+		icon := "✅"
+		switch p.status {
+		case groupSkipped:
+			icon = "⏭️ "
+		case groupNoMetadata, groupFailed, groupPending:
+			icon = "❌"
+			failed++
+		}
+		fmt.Fprintf(os.Stdout, "  %s %s (%s): %s", icon, p.group.Title, musicbrainz.ExtractYear(p.group.FirstReleaseDate), p.status)
+		if p.detail != "" {
+			fmt.Fprintf(os.Stdout, ": %s", p.detail)
+		}
+		fmt.Fprintf(os.Stdout, "\n")
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d release group(s) failed", failed)
+	}
+	return nil
+}
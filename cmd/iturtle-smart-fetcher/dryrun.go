@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/provider"
+)
+
+// printTags shows the tags an album would be written with and the layer
+// each of them came from. Tags no layer supplied are left as yt-dlp wrote them.
+func printTags(w io.Writer, merged *provider.Merged) {
+	pm := merged.Metadata
+	if pm == nil {
+		pm = &downloader.PlaylistMetadata{}
+	}
+
+	fmt.Fprintf(w, "🏷️  Tags (dry run):\n")
+	for _, field := range provider.Fields {
+		if field == provider.FieldTracks {
+			break
+		}
+		fmt.Fprintf(w, "   %-13s %s\n", field, tagValue(albumTag(pm, field), merged.Sources[field]))
+	}
+
+	if len(pm.Tracks) == 0 {
+		fmt.Fprintf(w, "   tracks        from the video\n\n")
+		return
+	}
+	fmt.Fprintf(w, "   tracks        %d [%s]\n", len(pm.Tracks), merged.Sources[provider.FieldTracks])
+	for i, track := range pm.Tracks {
+		position := track.Position
+		if position <= 0 {
+			position = i + 1
+		}
+		sources := map[provider.Field]string{}
+		if i < len(merged.TrackSources) {
+			sources = merged.TrackSources[i]
+		}
+		fmt.Fprintf(w, "   %02d %s\n", position, tagValue(track.Title, sources[provider.FieldTrackTitle]))
+		for _, tag := range []struct {
+			field provider.Field
+			value string
+		}{
+			{provider.FieldTrackArtist, track.Artist},
+			{provider.FieldComposer, track.Composer},
+			{provider.FieldTrackComment, track.Comment},
+		} {
+			if tag.value != "" {
+				fmt.Fprintf(w, "      %-13s %s\n", tag.field, tagValue(tag.value, sources[tag.field]))
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// tagValue formats a tag with the layer it came from.
+func tagValue(value, source string) string {
+	if value == "" {
+		return "(not set, the video's own tag is kept)"
+	}
+	return fmt.Sprintf("%q [%s]", value, source)
+}
+
+// albumTag returns the value of an album-level field.
+func albumTag(pm *downloader.PlaylistMetadata, field provider.Field) string {
+	info := pm.AlbumInfo
+	switch field {
+	case provider.FieldTitle:
+		return info.Title
+	case provider.FieldArtist:
+		return info.Artist
+	case provider.FieldAlbumArtist:
+		return info.AlbumArtist
+	case provider.FieldYear:
+		return info.Year
+	case provider.FieldGenre:
+		return info.Genre
+	case provider.FieldLabel:
+		return info.Label
+	case provider.FieldCatalog:
+		return info.CatalogNum
+	case provider.FieldCountry:
+		return info.Country
+	case provider.FieldCover:
+		if info.CoverURL != "" {
+			return info.CoverURL
+		}
+		return info.CoverPath
+	case provider.FieldComment:
+		return info.Comment
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+// journalPath returns where the journal of a batch configuration file is
+// kept: next to it, e.g. albums.journal.json for albums.yaml.
+func journalPath(configFile string) string {
+	return strings.TrimSuffix(configFile, filepath.Ext(configFile)) + ".journal.json"
+}
+
+// openJournal returns the journal of a batch run. With resume the journal of
+// the interrupted run is continued, otherwise a new one is started.
+func openJournal(configFile string, resume bool) (*downloader.Journal, error) {
+	path := journalPath(configFile)
+	if !resume {
+		return downloader.NewJournal(path), nil
+	}
+	journal, err := downloader.LoadJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(journal.Albums) == 0 {
+		fmt.Fprintf(os.Stdout, "📝 No journal at %s, starting from the first album\n\n", path)
+	} else {
+		fmt.Fprintf(os.Stdout, "📝 Resuming from %s\n\n", path)
+	}
+	return journal, nil
+}
+
+// journalKey identifies an album in the journal by its output directory and
+// what the album is looked up or downloaded from, so that the key does not
+// change when albums are added to or removed from the configuration.
+func journalKey(outputDir string, album config.AlbumConfig) string {
+	parts := []string{outputDir}
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+	add("artist", album.Artist)
+	add("album", album.Album)
+	add("auto_fetch", album.AutoFetch)
+	add("musicbrainz_release_group_id", album.MusicBrainzReleaseGroupID)
+	if album.DiscogsID != 0 {
+		add("discogs_id", strconv.Itoa(album.DiscogsID))
+	}
+	if album.DiscogsMasterID != 0 {
+		add("discogs_master_id", strconv.Itoa(album.DiscogsMasterID))
+	}
+	add("tracklist_file", album.TracklistFile)
+	add("musicbrainz_id", album.MusicBrainzID)
+	add("url", album.URL)
+	return strings.Join(parts, "|")
+}
+
+// albumJournal returns the journal of an album. Processing an album can
+// write its url and musicbrainz_id into the configuration file, so the keys
+// it had without them in an earlier run are looked for too.
+func albumJournal(journal *downloader.Journal, outputDir string, album config.AlbumConfig) *downloader.AlbumJournal {
+	keys := []string{journalKey(outputDir, album)}
+	for _, recorded := range []func(*config.AlbumConfig){
+		func(a *config.AlbumConfig) { a.URL = "" },
+		func(a *config.AlbumConfig) { a.MusicBrainzID = "" },
+		func(a *config.AlbumConfig) { a.URL, a.MusicBrainzID = "", "" },
+	} {
+		earlier := album
+		recorded(&earlier)
+		if key := journalKey(outputDir, earlier); !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return journal.Find(keys...)
+}
+
+// closeJournal removes the journal once every album is done, or tells how to
+// resume otherwise.
+func closeJournal(journal *downloader.Journal, configFile string, failed int) {
+	if journal == nil {
+		return
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stdout, "📝 Progress is kept in %s\n", journal.Path())
+		fmt.Fprintf(os.Stdout, "   Continue with: iturtle-smart-fetcher -config %s -resume\n", configFile)
+		return
+	}
+	if err := journal.Remove(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not remove %s: %v\n", journal.Path(), err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+func TestJournalKey(t *testing.T) {
+	first := config.AlbumConfig{Artist: "Black Kids", Album: "Wizard of Ahhhs"}
+	second := config.AlbumConfig{URL: "https://www.youtube.com/playlist?list=PL2"}
+	if journalKey(".", first) == journalKey(".", second) {
+		t.Errorf("expected albums in the default output directory to have different keys, got %q", journalKey(".", first))
+	}
+	if journalKey("a", second) == journalKey("b", second) {
+		t.Errorf("expected the output directory to be part of the key, got %q", journalKey("a", second))
+	}
+	if got, want := journalKey(".", first), ".|artist=Black Kids|album=Wizard of Ahhhs"; got != want {
+		t.Errorf("got key %q, want %q", got, want)
+	}
+}
+
+func TestAlbumJournalSurvivesEdits(t *testing.T) {
+	journal := downloader.NewJournal(filepath.Join(t.TempDir(), "albums.journal.json"))
+	albums := []config.AlbumConfig{
+		{Artist: "Black Kids", Album: "Wizard of Ahhhs"},
+		{MusicBrainzID: "01234567-89ab-cdef-0123-456789abcdef"},
+	}
+	for _, album := range albums {
+		if err := albumJournal(journal, ".", album).Finish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An album was added above the others, and the run wrote the URL it
+	// found and the release it picked into the configuration
+	edited := []config.AlbumConfig{
+		{URL: "https://www.youtube.com/playlist?list=PL0"},
+		{Artist: "Black Kids", Album: "Wizard of Ahhhs", URL: "https://www.youtube.com/playlist?list=PL1", MusicBrainzID: "fedcba98-7654-3210-fedc-ba9876543210"},
+		{MusicBrainzID: "01234567-89ab-cdef-0123-456789abcdef", URL: "https://www.youtube.com/playlist?list=PL2"},
+	}
+	if albumJournal(journal, ".", edited[0]).Done {
+		t.Error("expected the new album not to be done")
+	}
+	for _, album := range edited[1:] {
+		if !albumJournal(journal, ".", album).Done {
+			t.Errorf("expected %+v to be found done", album)
+		}
+	}
+}
+
+func TestCloseJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "albums.journal.json")
+	journal := downloader.NewJournal(path)
+	if err := journal.Album("album").Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Albums left to do keep the journal for -resume
+	output := captureStdout(t, func() { closeJournal(journal, "albums.yaml", 1) })
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the journal to be kept: %v", err)
+	}
+	if !strings.Contains(output, "iturtle-smart-fetcher -config albums.yaml -resume") {
+		t.Errorf("expected how to resume, got %q", output)
+	}
+
+	closeJournal(journal, "albums.yaml", 0)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed once every album is done, got %v", err)
+	}
+
+	// Runs without -resume have no journal
+	closeJournal(nil, "albums.yaml", 0)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+	"iturtle-smart-fetcher/internal/provider"
+	"iturtle-smart-fetcher/internal/youtube"
+)
+
+// fetchMetadata asks the metadata providers about an album and merges their
+// answers with the user's tags. It fails when no provider found the album;
+// the merged user tags, if any, are returned along with that error.
+// Providers that failed while others succeeded are reported as warnings.
+func fetchMetadata(ctx context.Context, con console, chain *provider.Chain, req provider.Request) (*provider.Merged, error) {
+	req.Log = con.log()
+	merged, err := chain.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !merged.Found() {
+		return merged, merged.Err()
+	}
+
+	names := make([]string, 0, len(merged.Errors))
+	for name := range merged.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(con.err, "⚠️  %s lookup failed: %v\n", name, merged.Errors[name])
+	}
+	return merged, nil
+}
+
+// remainingFlags returns the command line tags the downloader still has to
+// apply once they were merged into pm: the track number, and the per-track
+// tags when pm has no tracks to carry them.
+func remainingFlags(flags downloader.Metadata, pm *downloader.PlaylistMetadata) downloader.Metadata {
+	if len(pm.Tracks) == 0 {
+		return downloader.Metadata{Title: flags.Title, Composer: flags.Composer, Track: flags.Track}
+	}
+	return downloader.Metadata{Track: flags.Track}
+}
+
+// describeSource inspects the playlist so that search candidates can be
+// ranked against its track count and length. Failures only cost ranking
+// accuracy, so they are reported as warnings.
+func describeSource(ctx context.Context, con console, dl *downloader.Downloader, ytDLPPath string, req *provider.Request) {
+	fmt.Fprintf(con.out, "🔎 Inspecting playlist to rank MusicBrainz releases...\n")
+
+	entries, err := dl.ProbePlaylist(ctx, ytDLPPath, req.URL)
+	if err != nil {
+		fmt.Fprintf(con.err, "⚠️  Could not inspect playlist: %v\n", err)
+		return
+	}
+
+	req.TrackCount = len(entries)
+	req.Duration = downloader.TotalDuration(entries)
+}
+
+// fetchRecordingMetadata looks up a single song ("Artist - Title") and returns
+// tags for it together with the cover of the release it was taken from.
+// The recording closest to the video length wins; its earliest official
+// album provides the album, year, track number and cover.
+func fetchRecordingMetadata(ctx context.Context, client *musicbrainz.Client, query string, duration time.Duration, prefs musicbrainz.Preferences) (downloader.Metadata, string, error) {
+	results, err := client.AutoSearchRecordings(ctx, query)
+	if err != nil {
+		return downloader.Metadata{}, "", fmt.Errorf("search recordings: %w", err)
+	}
+	if len(results.Recordings) == 0 {
+		return downloader.Metadata{}, "", fmt.Errorf("no recordings found for query: %s", query)
+	}
+
+	best := musicbrainz.RankRecordings(results.Recordings, duration)[0]
+	recording := &best.Recording
+	fmt.Fprintf(os.Stdout, "🎙️  Recording: %s - %s", musicbrainz.GetArtistName(recording.ArtistCredit), recording.Title)
+	if recording.Length > 0 {
+		fmt.Fprintf(os.Stdout, " [%s]", musicbrainz.FormatDuration(recording.Length))
+	}
+	fmt.Fprintf(os.Stdout, " (%s)\n", recording.ID)
+	if len(best.Reasons) > 0 {
+		fmt.Fprintf(os.Stdout, "   because: %s\n", strings.Join(best.Reasons, ", "))
+	}
+
+	// Search results are enough to go on if the full lookup fails
+	if full, err := client.GetRecording(ctx, recording.ID); err == nil {
+		recording = full
+	}
+
+	chosen, err := musicbrainz.SelectRecordingRelease(*recording, prefs)
+	if err != nil {
+		// A standalone recording still has a title and artist
+		fmt.Fprintf(os.Stderr, "⚠️  %v; tagging without album\n", err)
+		return musicbrainz.ToTrackMetadata(recording, nil), "", nil
+	}
+
+	release, err := client.GetReleaseByID(ctx, chosen.Release.ID)
+	if err != nil {
+		// The search data still names the album and usually the track number
+		fmt.Fprintf(os.Stderr, "⚠️  Could not fetch release details: %v\n", err)
+		release = &chosen.Release
+	}
+	fmt.Fprintf(os.Stdout, "💿 From: %s (%s)\n", release.Title, musicbrainz.Summarize(*release))
+	fmt.Fprintf(os.Stdout, "   because: %s\n", strings.Join(chosen.Reasons, ", "))
+
+	coverURL, err := client.GetFrontCoverURL(ctx, release.ID)
+	if err != nil {
+		// Cover art is optional, continue without it
+		coverURL = ""
+	}
+
+	return musicbrainz.ToTrackMetadata(recording, release), coverURL, nil
+}
+
+// videoDuration returns the length of a single video, or 0 if unknown.
+func videoDuration(ctx context.Context, dl *downloader.Downloader, ytDLPPath, url string) time.Duration {
+	entries, err := dl.ProbePlaylist(ctx, ytDLPPath, url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not inspect video: %v\n", err)
+		return 0
+	}
+	if len(entries) == 0 {
+		return 0
+	}
+	return entries[0].Duration
+}
+
+// mergeMissing fills the empty fields of meta from found, so that tags given
+// on the command line win over looked-up ones.
+func mergeMissing(meta *downloader.Metadata, found downloader.Metadata) {
+	fill := func(dst *string, src string) {
+		if strings.TrimSpace(*dst) == "" {
+			*dst = src
+		}
+	}
+	fill(&meta.Title, found.Title)
+	fill(&meta.Artist, found.Artist)
+	fill(&meta.Album, found.Album)
+	fill(&meta.AlbumArtist, found.AlbumArtist)
+	fill(&meta.Composer, found.Composer)
+	fill(&meta.Year, found.Year)
+	fill(&meta.Genre, found.Genre)
+	fill(&meta.Track, found.Track)
+	fill(&meta.Comment, found.Comment)
+}
+
+// resolveSource finds where to download a release from when no URL is given:
+// the official YouTube Music album playlist if one has the release's track
+// count, otherwise one video per selected track. Exactly one of the returned
+// URL and sources is set.
+func resolveSource(ctx context.Context, con console, searcher *youtube.Searcher, pm *downloader.PlaylistMetadata, items downloader.Items) (string, []downloader.TrackSource, error) {
+	if pm != nil && len(pm.Tracks) > 0 {
+		url, err := findAlbumPlaylist(ctx, con, searcher, pm.AlbumInfo.Artist, pm.AlbumInfo.Title, len(pm.Tracks))
+		if err == nil {
+			return url, nil, nil
+		}
+		if ctx.Err() != nil {
+			return "", nil, ctx.Err()
+		}
+		fmt.Fprintf(con.err, "⚠️  No album playlist found: %v\n", err)
+		fmt.Fprintf(con.err, "    Searching for each track instead...\n\n")
+	}
+
+	sources, err := findTrackSources(ctx, con, searcher, pm, items)
+	return "", sources, err
+}
+
+// findAlbumPlaylist searches YouTube Music for the album's official playlist
+// and returns the URL of the one with trackCount items.
+func findAlbumPlaylist(ctx context.Context, con console, searcher *youtube.Searcher, artist, album string, trackCount int) (string, error) {
+	fmt.Fprintf(con.out, "🔍 Searching YouTube Music for the album playlist of %s - %s...\n", artist, album)
+
+	playlists, err := searcher.FindAlbumPlaylists(ctx, artist, album)
+	if err != nil {
+		return "", fmt.Errorf("search album playlists: %w", err)
+	}
+	for _, p := range playlists {
+		mark := "✗"
+		if p.ItemCount == trackCount {
+			mark = "✓"
+		}
+		fmt.Fprintf(con.out, "   %s %s · %d items", mark, p.Title, p.ItemCount)
+		if p.Channel != "" {
+			fmt.Fprintf(con.out, " · %s", p.Channel)
+		}
+		fmt.Fprintf(con.out, "\n")
+	}
+
+	best, err := youtube.PickAlbumPlaylist(playlists, artist, album, trackCount)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(con.out, "   Using %s (%d tracks)\n\n", best.URL(), best.ItemCount)
+	return best.URL(), nil
+}
+
+// findTrackSources searches YouTube for a video of every selected track of
+// the release. Tracks without a good match are left out and reported; it
+// fails only if nothing was found.
+func findTrackSources(ctx context.Context, con console, searcher *youtube.Searcher, pm *downloader.PlaylistMetadata, items downloader.Items) ([]downloader.TrackSource, error) {
+	if pm == nil || len(pm.Tracks) == 0 {
+		return nil, fmt.Errorf("no url given and no MusicBrainz track list to search YouTube with")
+	}
+
+	// Tracks left out by the selection are not searched for
+	position := func(i int) int {
+		if pm.Tracks[i].Position > 0 {
+			return pm.Tracks[i].Position
+		}
+		return i + 1
+	}
+	selected := 0
+	for i := range pm.Tracks {
+		if items.Contains(position(i)) {
+			selected++
+		}
+	}
+	if selected == 0 {
+		return nil, fmt.Errorf("none of the %d tracks is selected", len(pm.Tracks))
+	}
+
+	fmt.Fprintf(con.out, "🔍 Searching YouTube for %d tracks...\n", selected)
+
+	var sources []downloader.TrackSource
+	for i, tm := range pm.Tracks {
+		position := position(i)
+		if !items.Contains(position) {
+			continue
+		}
+		artist := tm.Artist
+		if artist == "" {
+			artist = pm.AlbumInfo.Artist
+		}
+
+		best, err := searcher.FindTrack(ctx, youtube.Track{
+			Artist:   artist,
+			Title:    tm.Title,
+			Duration: config.ParseDuration(tm.Duration),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fmt.Fprintf(con.err, "   ✗ %02d %s: %v\n", position, tm.Title, err)
+			continue
+		}
+
+		fmt.Fprintf(con.out, "   ✓ %02d %s → %s", position, tm.Title, best.Title)
+		if best.Channel != "" {
+			fmt.Fprintf(con.out, " · %s", best.Channel)
+		}
+		if best.Duration > 0 {
+			fmt.Fprintf(con.out, " · %s", musicbrainz.FormatDuration(int(best.Duration/time.Millisecond)))
+		}
+		fmt.Fprintf(con.out, "\n")
+
+		sources = append(sources, downloader.TrackSource{Position: position, URL: best.URL()})
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no YouTube videos found for any track")
+	}
+	fmt.Fprintf(con.out, "   Found %d/%d tracks\n\n", len(sources), selected)
+	return sources, nil
+}
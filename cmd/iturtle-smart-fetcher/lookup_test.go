@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+func TestRemainingFlags(t *testing.T) {
+	flags := downloader.Metadata{Title: "Hurricane Jane", Artist: "Black Kids", Album: "Partie Traumatic", Composer: "Reggie Youngblood", Track: "3"}
+
+	// Without tracks the per-track tags have nowhere to go but the downloader
+	got := remainingFlags(flags, &downloader.PlaylistMetadata{})
+	want := downloader.Metadata{Title: "Hurricane Jane", Composer: "Reggie Youngblood", Track: "3"}
+	if got != want {
+		t.Errorf("without tracks got %+v, want %+v", got, want)
+	}
+
+	// With tracks the merge carries them, and only the track number is left
+	got = remainingFlags(flags, &downloader.PlaylistMetadata{Tracks: []downloader.TrackMetadata{{Position: 1, Title: "Hurricane Jane"}}})
+	if want := (downloader.Metadata{Track: "3"}); got != want {
+		t.Errorf("with tracks got %+v, want %+v", got, want)
+	}
+}
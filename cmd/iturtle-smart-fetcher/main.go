@@ -1,35 +1,84 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"syscall"
 
 	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/discogs"
 	"iturtle-smart-fetcher/internal/downloader"
 	"iturtle-smart-fetcher/internal/musicbrainz"
+	"iturtle-smart-fetcher/internal/provider"
 	"iturtle-smart-fetcher/internal/tools"
+	"iturtle-smart-fetcher/internal/youtube"
 )
 
 func main() {
+	// Subcommands are handled before the regular flags
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCacheCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var cfg downloader.Config
 	var (
 		ytDLPPath       string
 		ffmpegPath      string
 		configFile      string
 		musicBrainzID   string
+		discogsID       int
+		discogsMasterID int
+		releaseGroupID  string
 		autoFetchQuery  string
+		lookupTrack     string
+		findPlaylist    string
+		discography     string
+		groupTypes      string
+		editionPolicy   string
+		interactive     bool
 		showExampleConf bool
+		emitConfig      bool
+		dryRun          bool
+		failureFile     string
+		retryFailed     string
+		sync            bool
+		syncRemoved     string
+		items           string
+		nameTemplate    string
+		asciiNames      bool
+		overwrite       bool
+		resume          bool
+		jobs            int
+		albums          int
+		downloads       int
+		lookups         int
+		endpoints       endpointFlags
+		caching         cacheFlags
 	)
 
-	flag.StringVar(&cfg.URL, "url", "", "YouTube video or playlist URL (required unless -config is used)")
+	flag.StringVar(&cfg.URL, "url", "", "YouTube video or playlist URL (without it, the tracks of a MusicBrainz release are searched on YouTube)")
 	flag.StringVar(&cfg.OutputDir, "out", ".", "Directory where songs will be stored")
 	flag.StringVar(&cfg.Cover, "cover", "", "Path or URL to album / track cover image")
 	flag.StringVar(&cfg.AudioFormat, "format", "mp3", "Audio format to save (mp3 recommended)")
 	flag.StringVar(&ytDLPPath, "yt-dlp-path", "", "Path to yt-dlp binary (optional, searches PATH if not specified)")
 	flag.StringVar(&ffmpegPath, "ffmpeg-path", "", "Path to ffmpeg binary (optional, searches PATH if not specified)")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "Number of files tagged with ffmpeg at once")
+	flag.IntVar(&albums, "albums", 1, "Number of albums of a batch processed at once")
+	flag.IntVar(&downloads, "downloads", 2, "Number of yt-dlp downloads at once when several albums are in flight")
+	flag.IntVar(&lookups, "lookups", 1, "Number of metadata lookups at once when several albums are in flight")
 
 	flag.StringVar(&cfg.Metadata.Title, "title", "", "Song title metadata override")
 	flag.StringVar(&cfg.Metadata.Artist, "artist", "", "Artist metadata")
@@ -43,8 +92,39 @@ func main() {
 
 	flag.StringVar(&configFile, "config", "", "Path to YAML batch configuration file")
 	flag.StringVar(&musicBrainzID, "musicbrainz-id", "", "MusicBrainz release ID to fetch metadata")
+	flag.StringVar(&releaseGroupID, "musicbrainz-release-group-id", "", "MusicBrainz release group ID; an edition is picked with -edition")
+	flag.IntVar(&discogsID, "discogs-id", 0, "Discogs release ID to fetch metadata")
+	flag.IntVar(&discogsMasterID, "discogs-master-id", 0, "Discogs master release ID; its main release is used")
 	flag.StringVar(&autoFetchQuery, "auto-fetch-metadata", "", "Auto-search MusicBrainz (format: \"Artist - Album\")")
+	flag.StringVar(&lookupTrack, "lookup-track", "", "Look up a single song on MusicBrainz (format: \"Artist - Title\") and tag the file with its album, year and cover")
+	flag.StringVar(&findPlaylist, "find-playlist", "", "Print the YouTube Music album playlist whose length matches the MusicBrainz release (format: \"Artist - Album\") and exit")
+	flag.StringVar(&discography, "discography", "", "Artist name or MBID; choose release groups from the discography and download them")
+	flag.StringVar(&groupTypes, "types", "album", "Release group types listed by -discography: album, ep, single, live, compilation or all")
+	flag.StringVar(&editionPolicy, "edition", "best", "Edition to pick from a release group: best, original, deluxe or country:XX")
+	flag.BoolVar(&interactive, "interactive", false, "Choose the MusicBrainz release from a list of candidates instead of taking the best-ranked match")
 	flag.BoolVar(&showExampleConf, "example-config", false, "Print example configuration file and exit")
+	flag.BoolVar(&emitConfig, "emit-config", false, "Print a batch configuration entry for the looked-up album (with -musicbrainz-id, -auto-fetch-metadata, ...) and exit")
+	flag.StringVar(&failureFile, "failure-file", "", "Where to list items that could not be downloaded (default "+defaultFailureFile+" in the -out directory)")
+	flag.StringVar(&retryFailed, "retry-failed", "", "Download the items listed in a failure file again and tag them into their albums")
+	flag.BoolVar(&sync, "sync", false, "Keep -out in sync with the playlist: download only entries added since the last sync, numbered after the existing tracks")
+	flag.StringVar(&syncRemoved, "sync-removed", "keep", "What -sync does with files of entries that left the playlist: keep, delete or move (into \""+downloader.RemovedDir+"\")")
+	flag.StringVar(&items, "items", "", "Playlist items or track numbers to download, e.g. \"1-5,8\" or \"3-\" (in batch mode for every album, replacing items, skip and only_listed)")
+	flag.StringVar(&nameTemplate, "name-template", "", "Rename files from their tags after tagging, e.g. \"{albumartist}/{album} ({year})/{track:02} {title}\" (in batch mode for albums without name_template)")
+	flag.BoolVar(&asciiNames, "ascii-names", false, "Transliterate the names -name-template gives to ASCII")
+	flag.BoolVar(&overwrite, "overwrite", false, "Let -name-template replace existing files instead of numbering the new ones")
+	flag.BoolVar(&resume, "resume", false, "Continue an interrupted -config run from its journal, skipping finished albums and files")
+	flag.BoolVar(&dryRun, "dry-run", false, "Look up metadata and show each tag with the layer it comes from, without downloading")
+
+	flag.StringVar(&endpoints.musicBrainzURL, "musicbrainz-url", "", "MusicBrainz API base URL, e.g. a local mirror (env "+envMusicBrainzURL+")")
+	flag.StringVar(&endpoints.coverArtURL, "coverart-url", "", "Cover Art Archive base URL (env "+envCoverArtURL+")")
+	flag.StringVar(&endpoints.userAgent, "user-agent", "", "Full User-Agent for MusicBrainz requests (env "+envUserAgent+")")
+	flag.StringVar(&endpoints.contact, "contact", "", "Email or URL included in the MusicBrainz User-Agent (env "+envContact+")")
+	flag.StringVar(&endpoints.rateLimit, "rate-limit", "", "Request interval for the MusicBrainz API (\"1s\") or per host (\"host=1s,host2=0s\") (env "+envRateLimit+")")
+	flag.StringVar(&endpoints.discogsURL, "discogs-url", "", "Discogs API base URL (env "+envDiscogsURL+")")
+	flag.StringVar(&endpoints.discogsToken, "discogs-token", "", "Discogs personal access token, needed to search Discogs (env "+envDiscogsToken+")")
+	flag.StringVar(&endpoints.discogsLimit, "discogs-rate-limit", "", "Request interval for the Discogs API, e.g. \"2s\" (default 1s with a token, 2.4s without) (env "+envDiscogsLimit+")")
+
+	caching.register(flag.CommandLine)
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "iTurtle-Smart-Fetcher - download and tag music from YouTube\n\n")
@@ -61,11 +141,67 @@ Examples:
   # Auto-search MusicBrainz
   iturtle-smart-fetcher -url "..." -auto-fetch-metadata "Black Kids - Partie Traumatic"
 
+  # Tag a single video with the song's album, year and cover
+  iturtle-smart-fetcher -url https://youtube.com/watch?v=VIDEO_ID -lookup-track "Black Kids - I'm Not Gonna Teach Your Boyfriend How to Dance with You"
+
+  # No playlist at hand: search YouTube for every track of the release
+  iturtle-smart-fetcher -auto-fetch-metadata "Black Kids - Partie Traumatic" -out ./music
+
+  # Find the official YouTube Music playlist of an album
+  iturtle-smart-fetcher -find-playlist "Black Kids - Partie Traumatic"
+
+  # Choose albums and EPs from an artist's discography
+  iturtle-smart-fetcher -discography "Black Kids" -types album,ep -out ./music
+
+  # Vinyl-only release from Discogs
+  iturtle-smart-fetcher -url "..." -discogs-id 1234567
+
+  # Pick the original edition of an album
+  iturtle-smart-fetcher -url "..." -auto-fetch-metadata "Black Kids - Partie Traumatic" -edition original
+
+  # Choose the MusicBrainz edition interactively
+  iturtle-smart-fetcher -url "..." -auto-fetch-metadata "Black Kids - Partie Traumatic" -interactive
+
+  # Use a local MusicBrainz mirror without rate limiting
+  iturtle-smart-fetcher -url "..." -auto-fetch-metadata "..." \
+    -musicbrainz-url http://localhost:5000/ws/2 -rate-limit 0s -contact me@example.com
+
   # Batch mode with configuration file
   iturtle-smart-fetcher -config albums.yaml
 
+  # Re-run a batch using only cached MusicBrainz responses and covers
+  iturtle-smart-fetcher -config albums.yaml -offline
+
+  # Inspect or empty the response cache
+  iturtle-smart-fetcher cache stats
+  iturtle-smart-fetcher cache clear
+
   # Generate example configuration file
   iturtle-smart-fetcher -example-config > albums.yaml
+
+  # Generate the entry of one album, with its full track list, for review
+  iturtle-smart-fetcher -musicbrainz-id "abc-123-def" -emit-config > albums.yaml
+
+  # Download the items of earlier runs that failed, e.g. after a network outage
+  iturtle-smart-fetcher -retry-failed ./music/iturtle-failed.json
+
+  # Process four albums at once, with at most two downloads at a time
+  iturtle-smart-fetcher -config albums.yaml -albums 4 -downloads 2 -jobs 4
+
+  # Continue a batch run that was interrupted
+  iturtle-smart-fetcher -config albums.yaml -resume
+
+  # Follow a playlist: each run downloads only what was added since the last one
+  iturtle-smart-fetcher -url "https://youtube.com/playlist?list=..." -out ./music/Mix -sync -sync-removed move
+
+  # Download only tracks 3 to 7 and 9 of a playlist, numbered as on the album
+  iturtle-smart-fetcher -url "https://youtube.com/playlist?list=..." -musicbrainz-id "abc-123-def" -items 3-7,9
+
+  # Name files from their tags, e.g. "Artist/Album (1999)/03 Title.mp3"
+  iturtle-smart-fetcher -config albums.yaml -out ./music -name-template "{albumartist}/{album} ({year})/{track:02} {title}"
+
+  # Keep your own genre over MusicBrainz and check where every tag comes from
+  iturtle-smart-fetcher -url "..." -musicbrainz-id "abc-123-def" -genre "Indie Pop" -dry-run
 `)
 	}
 	flag.Parse()
@@ -76,7 +212,135 @@ Examples:
 		os.Exit(0)
 	}
 
-	ctx := context.Background()
+	albumLookup := musicBrainzID != "" || releaseGroupID != "" || autoFetchQuery != "" || discogsID != 0 || discogsMasterID != 0
+	if emitConfig && !albumLookup {
+		fmt.Fprintf(os.Stderr, "❌ -emit-config needs an album lookup: -musicbrainz-id, -musicbrainz-release-group-id, -auto-fetch-metadata, -discogs-id or -discogs-master-id\n")
+		os.Exit(1)
+	}
+	if emitConfig && (configFile != "" || discography != "" || findPlaylist != "") {
+		fmt.Fprintf(os.Stderr, "❌ -emit-config cannot be combined with -config, -discography or -find-playlist\n")
+		os.Exit(1)
+	}
+
+	// With -emit-config only the YAML goes to stdout, so it can be redirected;
+	// progress messages go to stderr instead
+	con := stdio
+	if emitConfig {
+		con = console{out: os.Stderr, err: os.Stderr}
+	}
+
+	if lookupTrack != "" && (musicBrainzID != "" || releaseGroupID != "" || autoFetchQuery != "" || discogsID != 0 || discogsMasterID != 0) {
+		fmt.Fprintf(os.Stderr, "❌ -lookup-track cannot be combined with album lookups\n")
+		os.Exit(1)
+	}
+	if dryRun && (lookupTrack != "" || emitConfig || findPlaylist != "") {
+		fmt.Fprintf(os.Stderr, "❌ -dry-run cannot be combined with -lookup-track, -emit-config or -find-playlist\n")
+		os.Exit(1)
+	}
+
+	if sync && (lookupTrack != "" || emitConfig || findPlaylist != "" || discography != "") {
+		fmt.Fprintf(os.Stderr, "❌ -sync cannot be combined with -lookup-track, -emit-config, -find-playlist or -discography\n")
+		os.Exit(1)
+	}
+	if resume && (configFile == "" || dryRun) {
+		fmt.Fprintf(os.Stderr, "❌ -resume continues a -config run and cannot be combined with -dry-run\n")
+		os.Exit(1)
+	}
+	if interactive && albums > 1 {
+		fmt.Fprintf(os.Stderr, "❌ -interactive asks about one album at a time and cannot be combined with -albums\n")
+		os.Exit(1)
+	}
+	var lookupSlots chan struct{}
+	if lookups > 0 {
+		lookupSlots = make(chan struct{}, lookups)
+	}
+	selection, err := downloader.ParseItems(items)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid -items: %v\n", err)
+		os.Exit(1)
+	}
+	var naming *downloader.Naming
+	if nameTemplate != "" || asciiNames || overwrite {
+		naming = &downloader.Naming{ASCII: asciiNames, Overwrite: overwrite}
+		if nameTemplate != "" {
+			if naming.Template, err = downloader.ParseNameTemplate(nameTemplate); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Invalid -name-template: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	removedPolicy, err := downloader.ParseRemovedPolicy(syncRemoved)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid -sync-removed: %v\n", err)
+		os.Exit(1)
+	}
+
+	edition, err := musicbrainz.ParseEdition(editionPolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid -edition: %v\n", err)
+		os.Exit(1)
+	}
+
+	types, err := musicbrainz.ParseReleaseGroupTypes(groupTypes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid -types: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Cancel in-flight downloads and lookups on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var picker *musicbrainz.Picker
+	if interactive {
+		picker = musicbrainz.NewPicker(os.Stdin, con.out)
+	}
+
+	// Batch settings also configure the MusicBrainz client, so load them first
+	var batchCfg *config.BatchConfig
+	if configFile != "" {
+		batchCfg, err = config.LoadFromFile(configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Load config failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if naming != nil && naming.Template == nil && !hasNameTemplate(batchCfg) {
+		fmt.Fprintf(os.Stderr, "❌ -ascii-names and -overwrite need -name-template or an album with name_template\n")
+		os.Exit(1)
+	}
+
+	var fileCfg config.BatchConfig
+	if batchCfg != nil {
+		fileCfg = *batchCfg
+	}
+	mbOpts, err := musicBrainzOptions(endpoints, fileCfg.MusicBrainz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid MusicBrainz settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	responseCache, err := caching.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Cache setup failed: %v\n", err)
+		os.Exit(1)
+	}
+	mbOpts = append(mbOpts, musicbrainz.WithCache(responseCache))
+
+	// One client for the whole run, so every lookup shares the rate limit
+	mbClient := musicbrainz.NewClient(nil, mbOpts...)
+	discogsOpts, err := discogsOptions(endpoints, fileCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid Discogs settings: %v\n", err)
+		os.Exit(1)
+	}
+	discogsClient := discogs.NewClient(nil, append(discogsOpts, discogs.WithCache(responseCache))...)
+
+	dl := downloader.New(nil, nil)
+	dl.SetCache(responseCache)
+	dl.SetJobs(jobs)
+	dl.SetDownloads(downloads)
 
 	// Resolve tool paths first
 	manager := tools.New()
@@ -88,155 +352,595 @@ Examples:
 		fmt.Fprintf(os.Stderr, "❌ Tool setup failed: %v\n", err)
 		os.Exit(1)
 	}
+	searcher := youtube.NewSearcher(nil, paths.YtDLP)
+
+	if retryFailed != "" {
+		if err := runRetryMode(ctx, retryFailed, dl, paths); err != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if failureFile == "" {
+		failureFile = filepath.Join(cfg.OutputDir, defaultFailureFile)
+	}
+	failures := &failureLog{path: failureFile}
+
+	// Album metadata comes from a chain of providers, MusicBrainz first
+	mbProvider := provider.NewMusicBrainz(mbClient)
+	mbProvider.Picker = picker
+	mbProvider.Prefs = fileCfg.ReleasePreferences.ToMusicBrainz()
+	chain, err := metadataChain(fileCfg.Metadata, mbProvider, provider.NewDiscogs(discogsClient), paths.YtDLP)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid metadata settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Playlist discovery mode
+	if findPlaylist != "" {
+		result, err := mbProvider.Fetch(ctx, provider.Request{Query: findPlaylist, Edition: edition})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ MusicBrainz lookup failed: %v\n", err)
+			os.Exit(1)
+		}
+		pm := result.Metadata
+		url, err := findAlbumPlaylist(ctx, stdio, searcher, pm.AlbumInfo.Artist, pm.AlbumInfo.Title, len(pm.Tracks))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(url)
+		return
+	}
+
+	// Discography mode
+	if discography != "" {
+		// Reuse the picker so buffered answers are not lost between prompts
+		prompter := picker
+		if prompter == nil {
+			prompter = musicbrainz.NewPicker(os.Stdin, os.Stdout)
+		}
+		dopts := discographyOptions{
+			artist:    discography,
+			types:     types,
+			outputDir: cfg.OutputDir,
+			edition:   edition,
+			picker:    prompter,
+		}
+		opts := batchOptions{
+			paths:         paths,
+			defaultFormat: cfg.AudioFormat,
+			client:        mbClient,
+			metadata:      chain,
+			downloader:    dl,
+			searcher:      searcher,
+			flags:         cfg.Metadata,
+			cover:         cfg.Cover,
+			dryRun:        dryRun,
+			failures:      failures,
+			items:         selection,
+			naming:        naming,
+			parallel:      albums,
+			lookups:       lookupSlots,
+		}
+		if err := runDiscographyMode(ctx, dopts, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ Discography download failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Batch mode with config file
-	if configFile != "" {
-		if err := runBatchMode(ctx, configFile, paths, cfg.AudioFormat); err != nil {
+	if batchCfg != nil {
+		var journal *downloader.Journal
+		if !dryRun {
+			journal, err = openJournal(configFile, resume)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				os.Exit(1)
+			}
+		}
+		opts := batchOptions{
+			paths:         paths,
+			defaultFormat: cfg.AudioFormat,
+			client:        mbClient,
+			metadata:      chain,
+			downloader:    dl,
+			searcher:      searcher,
+			flags:         cfg.Metadata,
+			cover:         cfg.Cover,
+			dryRun:        dryRun,
+			failures:      failures,
+			sync:          sync,
+			syncRemoved:   removedPolicy,
+			items:         selection,
+			naming:        naming,
+			journal:       journal,
+			parallel:      albums,
+			lookups:       lookupSlots,
+		}
+		if err := runBatchMode(ctx, configFile, batchCfg, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "\n❌ Batch download failed: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
-	// Single download mode
-	if strings.TrimSpace(cfg.URL) == "" {
+	// Single download mode; without a URL the album's tracks are searched for
+	if strings.TrimSpace(cfg.URL) == "" && !albumLookup {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if sync && strings.TrimSpace(cfg.URL) == "" {
+		fmt.Fprintf(os.Stderr, "❌ -sync needs the -url of the playlist to follow\n")
+		os.Exit(1)
+	}
 
+	cfg.Items = selection
+	cfg.Naming = naming
 	cfg.YtDLPPath = paths.YtDLP
 	cfg.FFmpegPath = paths.FFmpeg
+	cfg.FFprobePath = paths.FFprobe
+	// Command line tags are merged with the looked-up metadata; what the
+	// merge cannot carry is left for the downloader to apply
+	flags, cover := cfg.Metadata, cfg.Cover
+	var merged *provider.Merged
+	if albumLookup {
+		req := provider.Request{
+			ReleaseID:        musicBrainzID,
+			ReleaseGroupID:   releaseGroupID,
+			DiscogsReleaseID: discogsID,
+			DiscogsMasterID:  discogsMasterID,
+			Query:            autoFetchQuery,
+			URL:              cfg.URL,
+			Edition:          edition,
+			Flags:            flags,
+			Cover:            cover,
+		}
+		if (req.ReleaseGroupID != "" || req.Query != "") && req.ReleaseID == "" && cfg.URL != "" {
+			describeSource(ctx, con, dl, cfg.YtDLPPath, &req)
+		}
+
+		merged, err = fetchMetadata(ctx, con, chain, req)
+		if err != nil && ((cfg.URL == "" && !dryRun) || emitConfig) {
+			fmt.Fprintf(os.Stderr, "❌ Metadata lookup failed: %v\n", err)
+			os.Exit(1)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Metadata lookup failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "    Continuing without MusicBrainz metadata...\n\n")
+		} else {
+			pm := merged.Metadata
+			cfg.PlaylistMetadata = pm
+			cfg.Metadata = remainingFlags(flags, pm)
+			cfg.Cover = ""
+			fmt.Fprintf(con.out, "🎵 Found: %s - %s (%s)\n", pm.AlbumInfo.Artist, pm.AlbumInfo.Title, pm.AlbumInfo.Year)
+			fmt.Fprintf(con.out, "   %d tracks\n\n", len(pm.Tracks))
+		}
+
+		if emitConfig {
+			// The entry carries no release IDs, so edits to it are used as
+			// written instead of being looked up again
+			album := config.AlbumFromMetadata(merged.Metadata)
+			album.URL = cfg.URL
+			if cfg.OutputDir != "." {
+				album.OutputDir = cfg.OutputDir
+			}
+			data, err := config.MarshalAlbums([]config.AlbumConfig{album})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				os.Exit(1)
+			}
+			os.Stdout.Write(data)
+			return
+		}
+	}
+
+	if dryRun {
+		if merged == nil {
+			// Without a lookup only the command line tags are merged
+			merged, _ = provider.NewChain().Fetch(ctx, provider.Request{Flags: flags, Cover: cover})
+		}
+		if merged == nil {
+			merged = &provider.Merged{}
+		}
+		printTags(os.Stdout, merged)
+		return
+	}
 
-	// Fetch metadata from MusicBrainz if requested
-	if musicBrainzID != "" || autoFetchQuery != "" {
-		pm, err := fetchMusicBrainzMetadata(ctx, musicBrainzID, autoFetchQuery)
+	// Look up a single song if requested
+	if lookupTrack != "" {
+		duration := videoDuration(ctx, dl, cfg.YtDLPPath, cfg.URL)
+		meta, coverURL, err := fetchRecordingMetadata(ctx, mbClient, lookupTrack, duration, musicbrainz.DefaultPreferences())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "⚠️  MusicBrainz lookup failed: %v\n", err)
 			fmt.Fprintf(os.Stderr, "    Continuing without MusicBrainz metadata...\n\n")
 		} else {
-			cfg.PlaylistMetadata = pm
-			fmt.Fprintf(os.Stdout, "🎵 Found: %s - %s (%s)\n", pm.AlbumInfo.Artist, pm.AlbumInfo.Title, pm.AlbumInfo.Year)
-			fmt.Fprintf(os.Stdout, "   %d tracks\n\n", len(pm.Tracks))
+			mergeMissing(&cfg.Metadata, meta)
+			if cfg.Cover == "" {
+				cfg.Cover = coverURL
+			}
+			fmt.Fprintf(os.Stdout, "🎵 Found: %s - %s", meta.Artist, meta.Title)
+			if meta.Album != "" {
+				fmt.Fprintf(os.Stdout, " (%s, %s)", meta.Album, meta.Year)
+			}
+			fmt.Fprintf(os.Stdout, "\n\n")
 		}
 	}
 
-	dl := downloader.New(nil, nil)
+	if cfg.URL == "" {
+		cfg.URL, cfg.TrackSources, err = resolveSource(ctx, stdio, searcher, cfg.PlaylistMetadata, cfg.Items)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Finding YouTube sources failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	_, err = dl.Download(ctx, cfg)
+	if sync {
+		// Failed entries are not archived, so the next sync retries them
+		if _, err := dl.Sync(ctx, cfg, removedPolicy); err != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ Sync failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	result, err := dl.Download(ctx, cfg)
+	failures.add(cfg, result)
+	failures.save()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Download failed: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-// runBatchMode processes albums from a configuration file.
-func runBatchMode(ctx context.Context, configFile string, paths tools.Paths, defaultFormat string) error {
-	batchCfg, err := config.LoadFromFile(configFile)
-	if err != nil {
-		return fmt.Errorf("load config: %w", err)
-	}
+// batchOptions holds the settings shared by every album of a batch run.
+type batchOptions struct {
+	paths         tools.Paths
+	defaultFormat string
+	client        *musicbrainz.Client
+	metadata      *provider.Chain
+	downloader    *downloader.Downloader
+	searcher      *youtube.Searcher
+	flags         downloader.Metadata // Tags given on the command line
+	cover         string              // Cover given on the command line
+	// dryRun shows the tags of each album instead of downloading it, and
+	// leaves the configuration file untouched.
+	dryRun bool
+	// failures collects the items that could not be downloaded.
+	failures *failureLog
+	// sync syncs every album as with sync: true, and syncRemoved is the
+	// policy for albums without sync_removed.
+	sync        bool
+	syncRemoved downloader.RemovedPolicy
+	// items replaces the selection of every album unless nil.
+	items downloader.Items
+	// naming renames the files of every album; the template is used for
+	// albums without name_template. Nil leaves albums as configured.
+	naming *downloader.Naming
+	// journal records the progress of each album, so that -resume can
+	// skip what is done. Nil records nothing.
+	journal *downloader.Journal
+	// parallel is the number of albums processed at once, and lookups
+	// limits the metadata lookups among them (nil for no limit). The
+	// MusicBrainz client keeps its rate limit across all of them.
+	parallel int
+	lookups  chan struct{}
+
+	// resolved holds metadata already looked up, by album index; those
+	// albums skip the MusicBrainz lookup.
+	resolved map[int]*provider.Merged
+	// onAlbumDone, if set, is called after each album with its result.
+	onAlbumDone func(index int, err error)
+}
 
+// runBatchMode processes albums from a configuration file, up to
+// opts.parallel at a time. Albums in flight report into their own buffer,
+// which is printed as a whole once the album is done; the summary lists
+// albums in configuration order.
+// Releases found through auto_fetch are recorded back into the file as
+// musicbrainz_id so that later runs resolve to the same edition, and album
+// playlists found for albums without a url are recorded as their url.
+func runBatchMode(ctx context.Context, configFile string, batchCfg *config.BatchConfig, opts batchOptions) error {
 	fmt.Fprintf(os.Stdout, "🐢 Processing %d album(s) from configuration...\n\n", len(batchCfg.Albums))
 
-	dl := downloader.New(nil, nil)
-	var failed []string
-
-	for i, albumCfg := range batchCfg.Albums {
-		fmt.Fprintf(os.Stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-		fmt.Fprintf(os.Stdout, "Album %d/%d", i+1, len(batchCfg.Albums))
-		if albumCfg.Album != "" {
-			fmt.Fprintf(os.Stdout, ": %s", albumCfg.Album)
-		}
-		if albumCfg.Artist != "" {
-			fmt.Fprintf(os.Stdout, " by %s", albumCfg.Artist)
-		}
-		fmt.Fprintf(os.Stdout, "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
-
-		cfg := albumCfg.ToDownloaderConfig(".")
-		cfg.YtDLPPath = paths.YtDLP
-		cfg.FFmpegPath = paths.FFmpeg
-		if cfg.AudioFormat == "" {
-			cfg.AudioFormat = defaultFormat
+	outcomes := make([]albumOutcome, len(batchCfg.Albums))
+	// report serializes what albums in flight print and report
+	var report sync.Mutex
+	done := func(i int) {
+		if opts.onAlbumDone != nil && !outcomes[i].skipped {
+			report.Lock()
+			opts.onAlbumDone(i, outcomes[i].err)
+			report.Unlock()
 		}
+	}
 
-		// Fetch MusicBrainz metadata if needed
-		if albumCfg.NeedsMusicBrainzLookup() {
-			pm, err := fetchMusicBrainzMetadata(ctx, albumCfg.MusicBrainzID, albumCfg.AutoFetch)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "⚠️  MusicBrainz lookup failed: %v\n", err)
-				fmt.Fprintf(os.Stderr, "    Continuing with manual metadata...\n\n")
-			} else {
-				cfg.PlaylistMetadata = pm
-				fmt.Fprintf(os.Stdout, "🎵 Found: %s - %s (%s)\n", pm.AlbumInfo.Artist, pm.AlbumInfo.Title, pm.AlbumInfo.Year)
-				fmt.Fprintf(os.Stdout, "   %d tracks\n\n", len(pm.Tracks))
+	if opts.parallel <= 1 {
+		for i, albumCfg := range batchCfg.Albums {
+			if ctx.Err() != nil {
+				interrupt(outcomes[i:], batchCfg.Albums[i:])
+				break
 			}
+			outcomes[i] = processAlbum(ctx, stdio, configFile, i, len(batchCfg.Albums), albumCfg, opts)
+			done(i)
 		}
-
-		_, err := dl.Download(ctx, cfg)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "\n❌ Failed to download album: %v\n\n", err)
-			name := albumCfg.Album
-			if name == "" {
-				name = albumCfg.URL
+	} else {
+		slots := make(chan struct{}, opts.parallel)
+		var wg sync.WaitGroup
+	schedule:
+		for i, albumCfg := range batchCfg.Albums {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				interrupt(outcomes[i:], batchCfg.Albums[i:])
+				break schedule
 			}
-			failed = append(failed, name)
-			continue
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				var buf bytes.Buffer
+				album := opts
+				album.downloader = opts.downloader.WithOutput(&buf)
+				outcomes[i] = processAlbum(ctx, bufferedConsole(&buf), configFile, i, len(batchCfg.Albums), albumCfg, album)
+
+				report.Lock()
+				os.Stdout.Write(buf.Bytes())
+				report.Unlock()
+				done(i)
+			}()
 		}
-		fmt.Fprintf(os.Stdout, "\n")
+		wg.Wait()
 	}
 
 	// Summary
+	var failed, incomplete, interrupted []string
+	for _, o := range outcomes {
+		if !o.synced {
+			opts.failures.add(o.cfg, o.result)
+		}
+		if o.interrupted || (o.err != nil && errors.Is(o.err, context.Canceled)) {
+			interrupted = append(interrupted, o.name)
+		} else if o.err != nil {
+			failed = append(failed, o.name)
+		} else if o.result != nil && len(o.result.Failed) > 0 {
+			incomplete = append(incomplete, fmt.Sprintf("%s: %d item(s) missing", o.name, len(o.result.Failed)))
+		}
+	}
 	fmt.Fprintf(os.Stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	fmt.Fprintf(os.Stdout, "Batch Complete: %d/%d albums successful\n", len(batchCfg.Albums)-len(failed), len(batchCfg.Albums))
+	fmt.Fprintf(os.Stdout, "Batch Complete: %d/%d albums successful\n", len(batchCfg.Albums)-len(failed)-len(interrupted), len(batchCfg.Albums))
+	if len(incomplete) > 0 {
+		fmt.Fprintf(os.Stdout, "Incomplete albums:\n")
+		for _, line := range incomplete {
+			fmt.Fprintf(os.Stdout, "  - %s\n", line)
+		}
+	}
 	if len(failed) > 0 {
 		fmt.Fprintf(os.Stdout, "Failed albums:\n")
 		for _, name := range failed {
 			fmt.Fprintf(os.Stdout, "  - %s\n", name)
 		}
+	}
+	if len(interrupted) > 0 {
+		fmt.Fprintf(os.Stdout, "Interrupted albums:\n")
+		for _, name := range interrupted {
+			fmt.Fprintf(os.Stdout, "  - %s\n", name)
+		}
+	}
+	opts.failures.save()
+	closeJournal(opts.journal, configFile, len(failed)+len(interrupted))
+	if len(failed) > 0 {
 		return fmt.Errorf("%d album(s) failed", len(failed))
 	}
+	if len(interrupted) > 0 {
+		return fmt.Errorf("interrupted with %d album(s) not finished", len(interrupted))
+	}
 
 	return nil
 }
 
-// fetchMusicBrainzMetadata fetches album and track metadata from MusicBrainz.
-func fetchMusicBrainzMetadata(ctx context.Context, mbID, autoQuery string) (*downloader.PlaylistMetadata, error) {
-	client := musicbrainz.NewClient(nil)
+// interrupt marks the albums of outcomes as not started because the run was
+// interrupted.
+func interrupt(outcomes []albumOutcome, albums []config.AlbumConfig) {
+	for i, albumCfg := range albums {
+		outcomes[i] = albumOutcome{name: albumCfg.Album, interrupted: true}
+		if outcomes[i].name == "" {
+			outcomes[i].name = albumCfg.URL
+		}
+	}
+}
+
+// hasNameTemplate reports whether an album of batchCfg sets name_template.
+func hasNameTemplate(batchCfg *config.BatchConfig) bool {
+	if batchCfg == nil {
+		return false
+	}
+	for _, album := range batchCfg.Albums {
+		if album.NameTemplate != "" {
+			return true
+		}
+	}
+	return false
+}
 
-	var release *musicbrainz.Release
-	var err error
+// albumOutcome is what processing one album of a batch came to.
+type albumOutcome struct {
+	name        string
+	cfg         downloader.Config
+	result      *downloader.Result // Nil if nothing was downloaded
+	err         error
+	skipped     bool // Finished by an earlier run
+	synced      bool // Synced; failed entries are left to the next sync
+	interrupted bool // Not started because the run was interrupted
+}
 
-	if mbID != "" {
-		// Fetch by MusicBrainz ID
-		release, err = client.GetReleaseByID(ctx, mbID)
-		if err != nil {
-			return nil, fmt.Errorf("fetch release by ID: %w", err)
+// configWrites serializes the updates of the configuration file by albums
+// in flight.
+var configWrites sync.Mutex
+
+// processAlbum looks up, downloads and tags album number index of a batch,
+// reporting to con.
+func processAlbum(ctx context.Context, con console, configFile string, index, total int, albumCfg config.AlbumConfig, opts batchOptions) albumOutcome {
+	dl := opts.downloader
+	outcome := albumOutcome{name: albumCfg.Album}
+	if outcome.name == "" {
+		outcome.name = albumCfg.URL
+	}
+
+	fmt.Fprintf(con.out, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Fprintf(con.out, "Album %d/%d", index+1, total)
+	if albumCfg.Album != "" {
+		fmt.Fprintf(con.out, ": %s", albumCfg.Album)
+	}
+	if albumCfg.Artist != "" {
+		fmt.Fprintf(con.out, " by %s", albumCfg.Artist)
+	}
+	fmt.Fprintf(con.out, "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	cfg := albumCfg.ToDownloaderConfig(".")
+	cfg.YtDLPPath = opts.paths.YtDLP
+	cfg.FFmpegPath = opts.paths.FFmpeg
+	cfg.FFprobePath = opts.paths.FFprobe
+	if cfg.AudioFormat == "" {
+		cfg.AudioFormat = opts.defaultFormat
+	}
+	if opts.items != nil {
+		cfg.Items = opts.items
+	}
+	if opts.naming != nil {
+		naming := *opts.naming
+		if cfg.Naming != nil {
+			if cfg.Naming.Template != nil {
+				naming.Template = cfg.Naming.Template
+			}
+			naming.ASCII = naming.ASCII || cfg.Naming.ASCII
 		}
-	} else if autoQuery != "" {
-		// Auto-search
-		results, err := client.AutoSearch(ctx, autoQuery)
-		if err != nil {
-			return nil, fmt.Errorf("search releases: %w", err)
+		// Without a template from either, the album keeps yt-dlp's names
+		if naming.Template != nil {
+			cfg.Naming = &naming
 		}
-		if len(results.Releases) == 0 {
-			return nil, fmt.Errorf("no releases found for query: %s", autoQuery)
+	}
+	cfg.Journal = albumJournal(opts.journal, cfg.OutputDir, albumCfg)
+	if cfg.Journal != nil && cfg.Journal.Done {
+		fmt.Fprintf(con.out, "✅ Finished by an earlier run, skipping\n\n")
+		outcome.skipped = true
+		return outcome
+	}
+
+	// Look up metadata unless discography mode already did
+	merged, ok := opts.resolved[index]
+	if !ok {
+		// Already validated by config.Parse
+		edition, _ := musicbrainz.ParseEdition(albumCfg.Edition)
+		req := provider.Request{
+			ReleaseID:        albumCfg.MusicBrainzID,
+			ReleaseGroupID:   albumCfg.MusicBrainzReleaseGroupID,
+			DiscogsReleaseID: albumCfg.DiscogsID,
+			DiscogsMasterID:  albumCfg.DiscogsMasterID,
+			Query:            albumCfg.AutoFetch,
+			Artist:           albumCfg.Artist,
+			Album:            albumCfg.Album,
+			URL:              cfg.URL,
+			Edition:          edition,
+			Known:            cfg.PlaylistMetadata,
+			Flags:            opts.flags,
+			Cover:            opts.cover,
 		}
-		// Get full release details for the first result
-		release, err = client.GetReleaseByID(ctx, results.Releases[0].ID)
-		if err != nil {
-			return nil, fmt.Errorf("fetch release details: %w", err)
+		if albumCfg.NeedsMusicBrainzLookup() && req.ReleaseID == "" && cfg.URL != "" {
+			describeSource(ctx, con, dl, cfg.YtDLPPath, &req)
+		}
+
+		var err error
+		if opts.lookups != nil {
+			select {
+			case opts.lookups <- struct{}{}:
+			case <-ctx.Done():
+				outcome.err = ctx.Err()
+				return outcome
+			}
+		}
+		merged, err = fetchMetadata(ctx, con, opts.metadata, req)
+		if opts.lookups != nil {
+			<-opts.lookups
+		}
+		if errors.Is(err, provider.ErrNotApplicable) {
+			// Nothing to look up; the album config is all there is
+		} else if err != nil {
+			fmt.Fprintf(con.err, "⚠️  Metadata lookup failed: %v\n", err)
+			fmt.Fprintf(con.err, "    Continuing with manual metadata...\n\n")
+		} else {
+			pm := merged.Metadata
+			fmt.Fprintf(con.out, "🎵 Found: %s - %s (%s)\n", pm.AlbumInfo.Artist, pm.AlbumInfo.Title, pm.AlbumInfo.Year)
+			fmt.Fprintf(con.out, "   %d tracks\n\n", len(pm.Tracks))
+
+			releaseID := merged.ID(provider.NameMusicBrainz)
+			if albumCfg.MusicBrainzID == "" && releaseID != "" && configFile != "" && !opts.dryRun {
+				configWrites.Lock()
+				err := config.RecordMusicBrainzID(configFile, index, releaseID)
+				configWrites.Unlock()
+				if err != nil {
+					fmt.Fprintf(con.err, "⚠️  Could not record musicbrainz_id: %v\n\n", err)
+				} else {
+					fmt.Fprintf(con.out, "📝 Recorded musicbrainz_id %s in %s\n\n", releaseID, configFile)
+				}
+			}
 		}
-	} else {
-		return nil, fmt.Errorf("either musicbrainz-id or auto-fetch-metadata is required")
+	}
+	// The merge already holds the album config and command line tags
+	if merged != nil {
+		cfg.PlaylistMetadata = merged.Metadata
+		cfg.Metadata = remainingFlags(opts.flags, merged.Metadata)
+		cfg.Cover = ""
 	}
 
-	// Try to get cover art
-	var coverURL string
-	coverURL, err = client.GetFrontCoverURL(ctx, release.ID)
-	if err != nil {
-		// Cover art is optional, continue without it
-		coverURL = ""
+	if opts.dryRun {
+		if merged == nil {
+			merged = &provider.Merged{}
+		}
+		printTags(con.out, merged)
+		return outcome
 	}
 
-	return musicbrainz.ToPlaylistMetadataWithCover(release, coverURL), nil
+	var err error
+	if cfg.URL == "" {
+		cfg.URL, cfg.TrackSources, err = resolveSource(ctx, con, opts.searcher, cfg.PlaylistMetadata, cfg.Items)
+		if cfg.URL != "" && configFile != "" {
+			configWrites.Lock()
+			err := config.RecordURL(configFile, index, cfg.URL)
+			configWrites.Unlock()
+			if err != nil {
+				fmt.Fprintf(con.err, "⚠️  Could not record url: %v\n\n", err)
+			} else {
+				fmt.Fprintf(con.out, "📝 Recorded url %s in %s\n\n", cfg.URL, configFile)
+			}
+		}
+	}
+	var result *downloader.Result
+	switch {
+	case err != nil:
+	case albumCfg.Sync || opts.sync:
+		// Failed entries are not archived, so the next sync retries them
+		policy := opts.syncRemoved
+		if albumCfg.SyncRemoved != "" {
+			// Already validated by config.Parse
+			policy, _ = downloader.ParseRemovedPolicy(albumCfg.SyncRemoved)
+		}
+		result, err = dl.Sync(ctx, cfg, policy)
+		outcome.synced = true
+	default:
+		result, err = dl.Download(ctx, cfg)
+	}
+	outcome.cfg, outcome.result, outcome.err = cfg, result, err
+	if err != nil {
+		fmt.Fprintf(con.err, "\n❌ Failed to download album: %v\n\n", err)
+		return outcome
+	}
+	if err := cfg.Journal.Finish(); err != nil {
+		fmt.Fprintf(con.err, "⚠️  Could not update the journal: %v\n", err)
+	}
+	fmt.Fprintf(con.out, "\n")
+	return outcome
 }
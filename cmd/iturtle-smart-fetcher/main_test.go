@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/provider"
+)
+
+// albumRunner fakes yt-dlp and ffprobe for whole albums: every playlist
+// download writes one file, once its gate is opened. Albums whose URL
+// contains "fail" fail instead of writing it.
+type albumRunner struct {
+	mu      sync.Mutex
+	gates   map[string]chan struct{} // By URL; albums without one do not wait
+	started chan string              // Receives the URL of every download that starts
+	active  int
+	peak    int // Most downloads in flight at once
+}
+
+func (r *albumRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	switch name {
+	case "yt-dlp":
+		url := args[len(args)-1]
+		r.mu.Lock()
+		r.active++
+		r.peak = max(r.peak, r.active)
+		gate := r.gates[url]
+		r.mu.Unlock()
+		defer func() {
+			r.mu.Lock()
+			r.active--
+			r.mu.Unlock()
+		}()
+		if r.started != nil {
+			r.started <- url
+		}
+
+		if gate != nil {
+			select {
+			case <-gate:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		if strings.Contains(url, "fail") {
+			return "", errors.New("video unavailable")
+		}
+		return "ok", writeDownload(args)
+	case "ffprobe":
+		return `{"format": {"duration": "180.000000"}}`, nil
+	default:
+		return "", fmt.Errorf("unexpected command: %s", name)
+	}
+}
+
+// writeDownload writes a file where yt-dlp's -o template points and adds it
+// to the file list given with --print-to-file.
+func writeDownload(args []string) error {
+	var dir, list string
+	for i := 0; i < len(args)-1; i++ {
+		switch {
+		case args[i] == "-o":
+			dir = filepath.Dir(args[i+1])
+		case args[i] == "--print-to-file" && i+2 < len(args):
+			list = args[i+2]
+		}
+	}
+	if dir == "" || list == "" {
+		return errors.New("missing -o or --print-to-file")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "1 - Track.mp3")
+	if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(list, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "vid1|1|%s\n", path)
+	return err
+}
+
+// testBatch returns albums downloading the given playlists into dir, and
+// options that run them through runner.
+func testBatch(dir string, runner *albumRunner, urls ...string) (*config.BatchConfig, batchOptions) {
+	batchCfg := &config.BatchConfig{}
+	for _, url := range urls {
+		batchCfg.Albums = append(batchCfg.Albums, config.AlbumConfig{
+			URL:       url,
+			OutputDir: filepath.Join(dir, filepath.Base(url)),
+		})
+	}
+	return batchCfg, batchOptions{
+		defaultFormat: "mp3",
+		metadata:      provider.NewChain(),
+		downloader:    downloader.New(runner, nil),
+	}
+}
+
+// captureStdout returns what fn writes to the standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestRunBatchModeRunsAlbumsInParallel(t *testing.T) {
+	gate := make(chan struct{})
+	runner := &albumRunner{gates: map[string]chan struct{}{}, started: make(chan string, 4)}
+	urls := []string{"https://example.com/one", "https://example.com/two", "https://example.com/three", "https://example.com/four"}
+	for _, url := range urls {
+		runner.gates[url] = gate
+	}
+	batchCfg, opts := testBatch(t.TempDir(), runner, urls...)
+	opts.parallel = 2
+
+	// onAlbumDone must never be called by two albums at once
+	var inside atomic.Int32
+	var finished []int
+	opts.onAlbumDone = func(index int, err error) {
+		if inside.Add(1) != 1 {
+			t.Error("onAlbumDone called concurrently")
+		}
+		finished = append(finished, index)
+		if err != nil {
+			t.Errorf("album %d failed: %v", index+1, err)
+		}
+		inside.Add(-1)
+	}
+
+	errs := make(chan error, 1)
+	output := captureStdout(t, func() {
+		go func() { errs <- runBatchMode(context.Background(), "", batchCfg, opts) }()
+		for range opts.parallel {
+			<-runner.started
+		}
+		select {
+		case url := <-runner.started:
+			t.Errorf("expected only %d albums at once, %s started too", opts.parallel, url)
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(gate)
+		if err := <-errs; err != nil {
+			t.Errorf("runBatchMode failed: %v", err)
+		}
+	})
+
+	if runner.peak != opts.parallel {
+		t.Errorf("expected %d albums in flight, got %d", opts.parallel, runner.peak)
+	}
+	if len(finished) != len(urls) {
+		t.Errorf("expected every album to be reported done, got %v", finished)
+	}
+	if !strings.Contains(output, "Batch Complete: 4/4 albums successful") {
+		t.Errorf("expected every album to succeed, got:\n%s", output)
+	}
+}
+
+func TestRunBatchModeSummaryKeepsConfigOrder(t *testing.T) {
+	// The first album fails last
+	first := make(chan struct{})
+	runner := &albumRunner{gates: map[string]chan struct{}{"https://example.com/fail-first": first}}
+	batchCfg, opts := testBatch(t.TempDir(), runner, "https://example.com/fail-first", "https://example.com/ok", "https://example.com/fail-last")
+	opts.parallel = 3
+	var failed int
+	opts.onAlbumDone = func(index int, err error) {
+		if err != nil {
+			if failed++; failed == 1 {
+				close(first)
+			}
+		}
+	}
+
+	var err error
+	output := captureStdout(t, func() {
+		err = runBatchMode(context.Background(), "", batchCfg, opts)
+	})
+	if err == nil || !strings.Contains(err.Error(), "2 album(s) failed") {
+		t.Errorf("expected two failed albums, got %v", err)
+	}
+	want := "Failed albums:\n  - https://example.com/fail-first\n  - https://example.com/fail-last\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("expected the failed albums in configuration order, got:\n%s", output)
+	}
+}
+
+func TestRunBatchModeStopsStartingAlbumsOnInterrupt(t *testing.T) {
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallel %d", parallel), func(t *testing.T) {
+			gate := make(chan struct{}) // Never opened
+			runner := &albumRunner{gates: map[string]chan struct{}{}, started: make(chan string, 4)}
+			urls := []string{"https://example.com/one", "https://example.com/two", "https://example.com/three", "https://example.com/four"}
+			for _, url := range urls {
+				runner.gates[url] = gate
+			}
+			batchCfg, opts := testBatch(t.TempDir(), runner, urls...)
+			opts.parallel = parallel
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errs := make(chan error, 1)
+			output := captureStdout(t, func() {
+				go func() { errs <- runBatchMode(ctx, "", batchCfg, opts) }()
+				for range parallel {
+					<-runner.started
+				}
+				cancel()
+				err := <-errs
+				if err == nil || !strings.Contains(err.Error(), "interrupted with 4 album(s) not finished") {
+					t.Errorf("expected the run to be interrupted, got %v", err)
+				}
+			})
+
+			if len(runner.started) != 0 {
+				t.Errorf("expected no album to start after the interrupt, %s did", <-runner.started)
+			}
+			want := "Interrupted albums:\n"
+			for _, url := range urls {
+				want += "  - " + url + "\n"
+			}
+			if !strings.Contains(output, want) {
+				t.Errorf("expected every album to be reported interrupted, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestProcessAlbumStopsWaitingForLookupOnInterrupt(t *testing.T) {
+	runner := &albumRunner{}
+	batchCfg, opts := testBatch(t.TempDir(), runner, "https://example.com/one")
+	// Another album holds the only lookup slot
+	opts.lookups = make(chan struct{}, 1)
+	opts.lookups <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf strings.Builder
+	outcome := processAlbum(ctx, bufferedConsole(&buf), "", 0, 1, batchCfg.Albums[0], opts)
+	if !errors.Is(outcome.err, context.Canceled) {
+		t.Errorf("expected the album to be canceled, got %v", outcome.err)
+	}
+	if runner.peak != 0 {
+		t.Error("expected nothing to be downloaded")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/tools"
+)
+
+// defaultFailureFile is the name of the failure file in the output directory.
+const defaultFailureFile = "iturtle-failed.json"
+
+// failureLog collects the items that failed during a run, to be written to
+// a failure file for -retry-failed. A nil log collects nothing.
+type failureLog struct {
+	path   string
+	albums []downloader.FailedAlbum
+}
+
+// add records the failed items of a download made with cfg.
+func (l *failureLog) add(cfg downloader.Config, result *downloader.Result) {
+	if l == nil || result == nil || len(result.Failed) == 0 {
+		return
+	}
+	l.albums = append(l.albums, downloader.NewFailedAlbum(cfg, result.Failed))
+}
+
+// save adds the failed items to the failure file if anything failed,
+// keeping what earlier runs recorded there. A failure file from an earlier
+// run is left alone otherwise.
+func (l *failureLog) save() {
+	if l == nil || len(l.albums) == 0 {
+		return
+	}
+	albums, err := downloader.MergeFailures(l.path, l.albums)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not save failed items: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stdout, "📝 %d failed item(s) written to %s", countFailed(l.albums), l.path)
+	if total := countFailed(albums); total > countFailed(l.albums) {
+		fmt.Fprintf(os.Stdout, " (%d in total)", total)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	fmt.Fprintf(os.Stdout, "   Retry them with: iturtle-smart-fetcher -retry-failed %s\n\n", l.path)
+}
+
+// countFailed returns the number of failed items of all albums.
+func countFailed(albums []downloader.FailedAlbum) int {
+	n := 0
+	for _, album := range albums {
+		n += len(album.Failed)
+	}
+	return n
+}
+
+// runRetryMode downloads the items listed in a failure file again and tags
+// them into their albums. Items that fail again stay in the file; it is
+// removed once nothing is left.
+func runRetryMode(ctx context.Context, path string, dl *downloader.Downloader, paths tools.Paths) error {
+	albums, err := downloader.LoadFailures(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "🐢 Retrying %d failed item(s) of %d album(s)...\n\n", countFailed(albums), len(albums))
+
+	var remaining []downloader.FailedAlbum
+	for i, album := range albums {
+		name := album.URL
+		if pm := album.PlaylistMetadata; pm != nil && pm.AlbumInfo.Title != "" {
+			name = pm.AlbumInfo.Title
+		}
+		if name == "" {
+			name = filepath.Base(album.OutputDir)
+		}
+		fmt.Fprintf(os.Stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+		fmt.Fprintf(os.Stdout, "Album %d/%d: %s (%d item(s))\n", i+1, len(albums), name, len(album.Failed))
+		fmt.Fprintf(os.Stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+		cfg := album.RetryConfig()
+		cfg.YtDLPPath = paths.YtDLP
+		cfg.FFmpegPath = paths.FFmpeg
+		cfg.FFprobePath = paths.FFprobe
+		if skipped := len(album.Failed) - len(cfg.TrackSources); skipped > 0 {
+			fmt.Fprintf(os.Stderr, "⚠️  Dropping %d item(s) without a video ID to retry\n\n", skipped)
+		}
+		if len(cfg.TrackSources) == 0 {
+			continue
+		}
+
+		result, err := dl.Download(ctx, cfg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		switch {
+		case result != nil:
+			album.Failed = result.Failed
+		case err != nil:
+			// Nothing was attempted, so every item is still missing
+			fmt.Fprintf(os.Stderr, "\n❌ Retry failed: %v\n\n", err)
+		}
+		if len(album.Failed) > 0 {
+			remaining = append(remaining, album)
+		}
+	}
+
+	if len(remaining) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not remove %s: %v\n", path, err)
+		}
+		fmt.Fprintf(os.Stdout, "✅ All failed items were downloaded; removed %s\n", path)
+		return nil
+	}
+	if err := downloader.SaveFailures(path, remaining); err != nil {
+		return err
+	}
+	return fmt.Errorf("%d item(s) still failing, kept in %s", countFailed(remaining), path)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/config"
+	"iturtle-smart-fetcher/internal/discogs"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+	"iturtle-smart-fetcher/internal/provider"
+)
+
+// Version is set at build time via -ldflags "-X main.Version=...".
+var Version = "dev"
+
+// Environment variables that configure the MusicBrainz client. Flags take
+// precedence over them, and they take precedence over the YAML config.
+const (
+	envMusicBrainzURL = "ITURTLE_MUSICBRAINZ_URL"
+	envCoverArtURL    = "ITURTLE_COVERART_URL"
+	envUserAgent      = "ITURTLE_USER_AGENT"
+	envContact        = "ITURTLE_CONTACT"
+	envRateLimit      = "ITURTLE_RATE_LIMIT"
+	envDiscogsURL     = "ITURTLE_DISCOGS_URL"
+	envDiscogsToken   = "ITURTLE_DISCOGS_TOKEN"
+	envDiscogsLimit   = "ITURTLE_DISCOGS_RATE_LIMIT"
+)
+
+// endpointFlags holds the MusicBrainz and Discogs client settings given on
+// the command line.
+type endpointFlags struct {
+	musicBrainzURL string
+	coverArtURL    string
+	userAgent      string
+	contact        string
+	rateLimit      string
+	discogsURL     string
+	discogsToken   string
+	discogsLimit   string
+}
+
+// musicBrainzOptions merges flags, environment and YAML settings into client
+// options, in that order of precedence.
+func musicBrainzOptions(flags endpointFlags, fileCfg config.MusicBrainzConfig) ([]musicbrainz.Option, error) {
+	opts := []musicbrainz.Option{
+		musicbrainz.WithBaseURL(first(flags.musicBrainzURL, os.Getenv(envMusicBrainzURL), fileCfg.URL)),
+		musicbrainz.WithCoverArtBaseURL(first(flags.coverArtURL, os.Getenv(envCoverArtURL), fileCfg.CoverArtURL)),
+		musicbrainz.WithUserAgent(userAgent(flags, fileCfg)),
+	}
+
+	limits, err := fileCfg.RateLimitIntervals()
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range []string{os.Getenv(envRateLimit), flags.rateLimit} {
+		overrides, err := parseRateLimits(spec)
+		if err != nil {
+			return nil, err
+		}
+		for host, d := range overrides {
+			limits[host] = d
+		}
+	}
+	for host, d := range limits {
+		opts = append(opts, musicbrainz.WithRateLimit(host, d))
+	}
+
+	return opts, nil
+}
+
+// discogsOptions merges flags, environment and YAML settings into Discogs
+// client options, in that order of precedence. The User-Agent is shared with
+// the MusicBrainz client.
+func discogsOptions(flags endpointFlags, fileCfg config.BatchConfig) ([]discogs.Option, error) {
+	opts := []discogs.Option{
+		discogs.WithBaseURL(first(flags.discogsURL, os.Getenv(envDiscogsURL), fileCfg.Discogs.URL)),
+		discogs.WithToken(first(flags.discogsToken, os.Getenv(envDiscogsToken), fileCfg.Discogs.Token)),
+		discogs.WithUserAgent(userAgent(flags, fileCfg.MusicBrainz)),
+	}
+	if limit := first(flags.discogsLimit, os.Getenv(envDiscogsLimit), fileCfg.Discogs.RateLimit); limit != "" {
+		d, err := time.ParseDuration(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Discogs rate limit %q: %w", limit, err)
+		}
+		opts = append(opts, discogs.WithRateLimit(d))
+	}
+	return opts, nil
+}
+
+// userAgent returns the configured User-Agent, or builds one from the contact.
+func userAgent(flags endpointFlags, fileCfg config.MusicBrainzConfig) string {
+	if ua := first(flags.userAgent, os.Getenv(envUserAgent), fileCfg.UserAgent); ua != "" {
+		return ua
+	}
+	return musicbrainz.UserAgent(Version, first(flags.contact, os.Getenv(envContact), fileCfg.Contact))
+}
+
+// first returns the first non-blank value.
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// metadataChain builds the chain of metadata providers configured in the
+// YAML metadata block, defaulting to MusicBrainz and Discogs.
+func metadataChain(fileCfg config.MetadataConfig, mb *provider.MusicBrainz, dc *provider.Discogs, ytDLPPath string) (*provider.Chain, error) {
+	var providers []provider.Provider
+	for _, name := range fileCfg.ProviderNames() {
+		switch name {
+		case provider.NameMusicBrainz:
+			providers = append(providers, mb)
+		case provider.NameDiscogs:
+			providers = append(providers, dc)
+		case provider.NameGenres:
+			providers = append(providers, provider.NewGenres(fileCfg.Genres))
+		case provider.NameYouTube:
+			providers = append(providers, provider.NewYouTube(nil, ytDLPPath))
+		default:
+			return nil, fmt.Errorf("unknown metadata provider %q", name)
+		}
+	}
+
+	chain := provider.NewChain(providers...)
+	for name, names := range fileCfg.Precedence {
+		field, err := provider.ParseField(name)
+		if err != nil {
+			return nil, err
+		}
+		chain.Prefer(field, names...)
+	}
+	return chain, nil
+}
+
+// parseRateLimits parses "1s" (the MusicBrainz API host) or a comma-separated
+// list of host=interval pairs such as "musicbrainz.org=1s,coverartarchive.org=0s".
+func parseRateLimits(spec string) (map[string]time.Duration, error) {
+	limits := map[string]time.Duration{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		host, value, found := strings.Cut(part, "=")
+		if !found {
+			host, value = "", part
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %w", part, err)
+		}
+		limits[strings.TrimSpace(host)] = d
+	}
+	return limits, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]time.Duration
+		wantErr bool
+	}{
+		{spec: "", want: map[string]time.Duration{}},
+		{spec: "2s", want: map[string]time.Duration{"": 2 * time.Second}},
+		{
+			spec: "musicbrainz.org=1s, coverartarchive.org = 0s,",
+			want: map[string]time.Duration{"musicbrainz.org": time.Second, "coverartarchive.org": 0},
+		},
+		{spec: "500ms,api.discogs.com=1s", want: map[string]time.Duration{"": 500 * time.Millisecond, "api.discogs.com": time.Second}},
+		{spec: "musicbrainz.org=fast", wantErr: true},
+		{spec: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRateLimits(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRateLimits(%q): expected an error, got %v", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRateLimits(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("parseRateLimits(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Package cache stores HTTP responses on disk, keyed by request URL, so that
+// repeated runs do not query MusicBrainz or download covers again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long a response is served without revalidation.
+	DefaultTTL = 7 * 24 * time.Hour
+	// Extension of entry files inside the cache directory
+	entryExt = ".json"
+)
+
+// ErrOffline is returned in offline mode when a URL is not cached.
+var ErrOffline = errors.New("not in cache (offline mode)")
+
+// Entry is a cached HTTP response.
+type Entry struct {
+	URL          string    `json:"url"`
+	Status       int       `json:"status"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	StoredAt     time.Time `json:"stored_at"` // Last time the server confirmed the body
+	Body         []byte    `json:"body"`
+}
+
+// Stats summarizes the contents of a cache directory.
+type Stats struct {
+	Dir     string
+	Entries int
+	Expired int
+	Bytes   int64
+}
+
+// Cache is an on-disk HTTP response cache. A nil *Cache is valid and caches
+// nothing, so callers do not need to check whether caching is enabled.
+// It is safe for concurrent use: entries are written to a temporary file and
+// renamed into place.
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	offline bool
+	now     func() time.Time
+}
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithTTL sets how long entries are served without revalidation.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+	}
+}
+
+// WithOffline serves every request from the cache, even expired entries,
+// and never touches the network.
+func WithOffline(offline bool) Option {
+	return func(c *Cache) {
+		c.offline = offline
+	}
+}
+
+// DefaultDir returns the cache directory under the user cache directory,
+// e.g. ~/.cache/iturtle-smart-fetcher on Linux.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locate user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "iturtle-smart-fetcher"), nil
+}
+
+// New opens the cache in dir, creating the directory if needed.
+func New(dir string, opts ...Option) (*Cache, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("cache dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	c := &Cache{
+		dir: dir,
+		ttl: DefaultTTL,
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Dir returns the cache directory, or "" for a nil cache.
+func (c *Cache) Dir() string {
+	if c == nil {
+		return ""
+	}
+	return c.dir
+}
+
+// Offline reports whether the network must not be used.
+func (c *Cache) Offline() bool {
+	return c != nil && c.offline
+}
+
+// Get returns the entry stored for url, fresh or not.
+func (c *Cache) Get(url string) (*Entry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	// A corrupt or colliding entry is treated as a miss
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Fresh reports whether entry can be served without asking the server.
+// In offline mode every entry is fresh.
+func (c *Cache) Fresh(entry *Entry) bool {
+	if c == nil || entry == nil {
+		return false
+	}
+	return c.offline || c.fresh(entry)
+}
+
+// fresh reports whether entry is younger than the TTL.
+func (c *Cache) fresh(entry *Entry) bool {
+	return c.now().Before(entry.StoredAt.Add(c.ttl))
+}
+
+// Put stores a response for url. Responses marked no-store are skipped.
+func (c *Cache) Put(url string, status int, header http.Header, body []byte) error {
+	if c == nil {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-store") {
+		return nil
+	}
+
+	return c.write(&Entry{
+		URL:          url,
+		Status:       status,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		StoredAt:     c.now(),
+		Body:         body,
+	})
+}
+
+// Revalidated extends the lifetime of entry after the server answered
+// 304 Not Modified, taking over any new validators from header.
+func (c *Cache) Revalidated(entry *Entry, header http.Header) error {
+	if c == nil || entry == nil {
+		return nil
+	}
+	if etag := header.Get("ETag"); etag != "" {
+		entry.ETag = etag
+	}
+	if lm := header.Get("Last-Modified"); lm != "" {
+		entry.LastModified = lm
+	}
+	entry.StoredAt = c.now()
+	return c.write(entry)
+}
+
+// SetValidators adds If-None-Match / If-Modified-Since headers to req so the
+// server can answer 304 Not Modified when entry is still current.
+func SetValidators(req *http.Request, entry *Entry) {
+	if entry == nil {
+		return
+	}
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// Stats counts the entries in the cache.
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Dir: c.Dir()}
+	if c == nil {
+		return stats, nil
+	}
+
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		stats.Entries++
+		stats.Bytes += info.Size()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil || !c.fresh(&entry) {
+			stats.Expired++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Clear removes every entry and returns how many were removed.
+func (c *Cache) Clear() (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+
+	removed := 0
+	err := c.walk(func(path string, _ fs.FileInfo) error {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove cache entry: %w", err)
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// walk calls fn for every entry file in the cache directory.
+func (c *Cache) walk(fn func(path string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != entryExt {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		return fn(path, info)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read cache dir: %w", err)
+	}
+	return nil
+}
+
+// write stores entry atomically.
+func (c *Cache) write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode cache entry: %w", err)
+	}
+
+	path := c.path(entry.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	return nil
+}
+
+// path returns the entry file for url. Entries are spread over
+// subdirectories named after the first byte of the key.
+func (c *Cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, key[:2], key+entryExt)
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPutAndGet(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	url := "https://musicbrainz.org/ws/2/release/abc?inc=recordings"
+	header := http.Header{}
+	header.Set("ETag", `"abc"`)
+	header.Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+	if err := c.Put(url, http.StatusOK, header, []byte(`{"id":"abc"}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	entry, ok := c.Get(url)
+	if !ok {
+		t.Fatal("expected a cached entry")
+	}
+	if string(entry.Body) != `{"id":"abc"}` || entry.Status != http.StatusOK {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if !c.Fresh(entry) {
+		t.Error("expected a new entry to be fresh")
+	}
+
+	if _, ok := c.Get("https://musicbrainz.org/ws/2/release/other"); ok {
+		t.Error("expected a miss for another URL")
+	}
+}
+
+func TestExpiryAndRevalidation(t *testing.T) {
+	c, err := New(t.TempDir(), WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	url := "https://coverartarchive.org/release/abc"
+	header := http.Header{}
+	header.Set("ETag", `"v1"`)
+	if err := c.Put(url, http.StatusOK, header, []byte("body")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	entry, _ := c.Get(url)
+	if c.Fresh(entry) {
+		t.Fatal("expected entry to be stale after its TTL")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	SetValidators(req, entry)
+	if req.Header.Get("If-None-Match") != `"v1"` {
+		t.Errorf("expected If-None-Match, got %q", req.Header.Get("If-None-Match"))
+	}
+
+	if err := c.Revalidated(entry, http.Header{}); err != nil {
+		t.Fatalf("Revalidated failed: %v", err)
+	}
+	entry, _ = c.Get(url)
+	if !c.Fresh(entry) {
+		t.Error("expected entry to be fresh after revalidation")
+	}
+	if entry.ETag != `"v1"` || string(entry.Body) != "body" {
+		t.Errorf("revalidation lost data: %+v", entry)
+	}
+}
+
+func TestOfflineServesStaleEntries(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, WithTTL(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := c.Put("https://example.com/a", http.StatusOK, http.Header{}, []byte("a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	offline, err := New(dir, WithOffline(true))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	entry, ok := offline.Get("https://example.com/a")
+	if !ok || !offline.Fresh(entry) {
+		t.Error("expected offline mode to serve the stale entry")
+	}
+	if !offline.Offline() {
+		t.Error("expected Offline to report true")
+	}
+}
+
+func TestNoStoreIsNotCached(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	header := http.Header{}
+	header.Set("Cache-Control", "private, no-store")
+	if err := c.Put("https://example.com/a", http.StatusOK, header, []byte("a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, ok := c.Get("https://example.com/a"); ok {
+		t.Error("expected no-store response to be skipped")
+	}
+}
+
+func TestStatsAndClear(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, url := range []string{"https://example.com/a", "https://example.com/b"} {
+		if err := c.Put(url, http.StatusOK, http.Header{}, []byte("data")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 2 || stats.Expired != 0 || stats.Bytes == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	removed, err := c.Clear()
+	if err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 removed entries, got %d", removed)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 {
+		t.Errorf("expected empty cache, got %+v", stats)
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	if err := c.Put("https://example.com/a", http.StatusOK, http.Header{}, nil); err != nil {
+		t.Errorf("Put on nil cache: %v", err)
+	}
+	if _, ok := c.Get("https://example.com/a"); ok {
+		t.Error("expected nil cache to miss")
+	}
+	if c.Offline() {
+		t.Error("expected nil cache not to be offline")
+	}
+}
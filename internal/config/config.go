@@ -1,41 +1,101 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+	"iturtle-smart-fetcher/internal/provider"
 )
 
 // BatchConfig represents the root configuration file structure.
 type BatchConfig struct {
-	Albums []AlbumConfig `yaml:"albums"`
+	MusicBrainz        MusicBrainzConfig  `yaml:"musicbrainz"`
+	Discogs            DiscogsConfig      `yaml:"discogs"`
+	ReleasePreferences ReleasePreferences `yaml:"release_preferences"`
+	Metadata           MetadataConfig     `yaml:"metadata"`
+	Albums             []AlbumConfig      `yaml:"albums"`
+}
+
+// MusicBrainzConfig overrides the MusicBrainz and Cover Art Archive endpoints
+// and how the client identifies itself. Empty fields keep the defaults.
+type MusicBrainzConfig struct {
+	URL         string            `yaml:"url"`           // API base URL, e.g. a local mirror
+	CoverArtURL string            `yaml:"cover_art_url"` // Cover Art Archive base URL
+	UserAgent   string            `yaml:"user_agent"`    // Full User-Agent override
+	Contact     string            `yaml:"contact"`       // Email or URL included in the User-Agent
+	RateLimit   string            `yaml:"rate_limit"`    // Interval between API requests (default: 1s)
+	RateLimits  map[string]string `yaml:"rate_limits"`   // Interval per host, e.g. coverartarchive.org: 500ms
+}
+
+// DiscogsConfig configures the Discogs provider. Empty fields keep the
+// defaults.
+type DiscogsConfig struct {
+	URL       string `yaml:"url"`        // API base URL
+	Token     string `yaml:"token"`      // Personal access token; needed for searching
+	RateLimit string `yaml:"rate_limit"` // Interval between requests (default: 1s with a token, 2.4s without)
+}
+
+// ReleasePreferences controls how auto_fetch ranks MusicBrainz search results.
+type ReleasePreferences struct {
+	Countries []string `yaml:"countries"` // Preferred release countries, most preferred first
+	Formats   []string `yaml:"formats"`   // Preferred media formats (default: Digital Media, CD)
+	Lookups   int      `yaml:"lookups"`   // Search results fetched in full to compare durations (default: 5)
+}
+
+// MetadataConfig chooses the metadata providers and which one each album
+// field is taken from.
+type MetadataConfig struct {
+	Providers  []string            `yaml:"providers"`  // Chain order (default: musicbrainz, discogs, plus genres when set)
+	Precedence map[string][]string `yaml:"precedence"` // Field → providers, most preferred first
+	Genres     map[string]string   `yaml:"genres"`     // Artist → genre, used by the genres provider
 }
 
 // AlbumConfig represents configuration for a single album download.
 type AlbumConfig struct {
-	URL            string        `yaml:"url"`
-	Artist         string        `yaml:"artist"`
-	Album          string        `yaml:"album"`
-	AlbumArtist    string        `yaml:"album_artist"`
-	Year           string        `yaml:"year"`
-	Genre          string        `yaml:"genre"`
-	Cover          string        `yaml:"cover"`
-	OutputDir      string        `yaml:"output_dir"`
-	MusicBrainzID  string        `yaml:"musicbrainz_id"`
-	AutoFetch      string        `yaml:"auto_fetch"` // "Artist - Album" format for auto-search
-	Tracks         []TrackConfig `yaml:"tracks"`
+	URL                       string        `yaml:"url"` // Optional with a lookup or listed tracks; the tracks are then searched on YouTube
+	Artist                    string        `yaml:"artist"`
+	Album                     string        `yaml:"album"`
+	AlbumArtist               string        `yaml:"album_artist"`
+	Year                      string        `yaml:"year"`
+	Genre                     string        `yaml:"genre"`
+	Label                     string        `yaml:"label"`
+	Cover                     string        `yaml:"cover"`
+	OutputDir                 string        `yaml:"output_dir"`
+	MusicBrainzID             string        `yaml:"musicbrainz_id"`
+	MusicBrainzReleaseGroupID string        `yaml:"musicbrainz_release_group_id"`
+	DiscogsID                 int           `yaml:"discogs_id"`        // Discogs release ID
+	DiscogsMasterID           int           `yaml:"discogs_master_id"` // Discogs master release ID
+	AutoFetch                 string        `yaml:"auto_fetch"`        // "Artist - Album" format for auto-search
+	Edition                   string        `yaml:"edition"`           // best, original, deluxe or country:XX
+	Tracks                    []TrackConfig `yaml:"tracks"`
+	TracklistFile             string        `yaml:"tracklist_file"` // CSV, JSON or CUE file with the tracks
+	TotalTracks               int           `yaml:"total_tracks"`   // Tracks on the album, when tracks lists only some
+	Items                     string        `yaml:"items"`          // Playlist items or tracks to download, e.g. "1-5,8"
+	Skip                      string        `yaml:"skip"`           // Playlist items or tracks to leave out, e.g. "1,14"
+	OnlyListed                bool          `yaml:"only_listed"`    // Download only the tracks listed in tracks
+	NameTemplate              string        `yaml:"name_template"`  // Path of each file from its tags, e.g. "{album}/{track:02} {title}"
+	ASCIINames                bool          `yaml:"ascii_names"`    // Transliterate file names to ASCII
+	Sync                      bool          `yaml:"sync"`           // Download only entries added since the last run
+	SyncRemoved               string        `yaml:"sync_removed"`   // keep, delete or move files of entries that left the playlist
 }
 
 // TrackConfig represents per-track configuration.
 type TrackConfig struct {
-	Num      int    `yaml:"num"`
-	Title    string `yaml:"title"`
-	Artist   string `yaml:"artist"`
-	Composer string `yaml:"composer"`
-	Duration string `yaml:"duration"`
-	Comment  string `yaml:"comment"`
+	Num      int    `yaml:"num" json:"num"`
+	Title    string `yaml:"title" json:"title"`
+	Artist   string `yaml:"artist" json:"artist"`
+	Composer string `yaml:"composer" json:"composer"`
+	Duration string `yaml:"duration" json:"duration"`
+	Comment  string `yaml:"comment" json:"comment"`
 }
 
 // LoadFromFile reads and parses a YAML configuration file.
@@ -45,7 +105,42 @@ func LoadFromFile(path string) (*BatchConfig, error) {
 		return nil, fmt.Errorf("read config file: %w", err)
 	}
 
-	return Parse(data)
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.loadTracklists(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadTracklists fills the tracks of albums with a tracklist_file. Relative
+// paths are resolved against dir, the directory of the configuration file.
+// Artist and album from a CUE sheet are used when the album has none.
+func (bc *BatchConfig) loadTracklists(dir string) error {
+	for i := range bc.Albums {
+		album := &bc.Albums[i]
+		if album.TracklistFile == "" {
+			continue
+		}
+		path := album.TracklistFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		tl, err := LoadTracklist(path)
+		if err != nil {
+			return fmt.Errorf("album %d: %w", i+1, err)
+		}
+		album.Tracks = tl.Tracks
+		if album.Artist == "" {
+			album.Artist = tl.Artist
+		}
+		if album.Album == "" {
+			album.Album = tl.Title
+		}
+	}
+	return nil
 }
 
 // Parse parses YAML configuration data.
@@ -59,16 +154,351 @@ func Parse(data []byte) (*BatchConfig, error) {
 		return nil, fmt.Errorf("no albums defined in configuration")
 	}
 
+	if _, err := cfg.MusicBrainz.RateLimitIntervals(); err != nil {
+		return nil, fmt.Errorf("musicbrainz: %w", err)
+	}
+	if cfg.Discogs.RateLimit != "" {
+		if _, err := time.ParseDuration(strings.TrimSpace(cfg.Discogs.RateLimit)); err != nil {
+			return nil, fmt.Errorf("discogs: rate limit: %w", err)
+		}
+	}
+
+	if err := cfg.Metadata.validate(); err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+
 	// Validate each album config
 	for i, album := range cfg.Albums {
-		if album.URL == "" {
-			return nil, fmt.Errorf("album %d: url is required", i+1)
+		// Without a URL the sources are searched for on YouTube, which needs
+		// the tracks: listed in the entry or from a MusicBrainz or Discogs release
+		if album.URL == "" && !album.NeedsMusicBrainzLookup() && album.DiscogsID == 0 && album.DiscogsMasterID == 0 &&
+			len(album.Tracks) == 0 && album.TracklistFile == "" {
+			return nil, fmt.Errorf("album %d: url is required unless tracks, tracklist_file, musicbrainz_id, musicbrainz_release_group_id, discogs_id, discogs_master_id or auto_fetch is set", i+1)
+		}
+		if album.TracklistFile != "" && len(album.Tracks) > 0 {
+			return nil, fmt.Errorf("album %d: use either tracks or tracklist_file, not both", i+1)
+		}
+		if _, err := musicbrainz.ParseEdition(album.Edition); err != nil {
+			return nil, fmt.Errorf("album %d: %w", i+1, err)
+		}
+		if album.Sync && album.URL == "" {
+			return nil, fmt.Errorf("album %d: sync needs the url of the playlist to follow", i+1)
+		}
+		if _, err := downloader.ParseRemovedPolicy(album.SyncRemoved); err != nil {
+			return nil, fmt.Errorf("album %d: sync_removed: %w", i+1, err)
+		}
+		if album.OnlyListed && len(album.Tracks) == 0 && album.TracklistFile == "" {
+			return nil, fmt.Errorf("album %d: only_listed needs tracks or a tracklist_file", i+1)
+		}
+		if album.NameTemplate != "" {
+			if _, err := downloader.ParseNameTemplate(album.NameTemplate); err != nil {
+				return nil, fmt.Errorf("album %d: name_template: %w", i+1, err)
+			}
+		} else if album.ASCIINames {
+			return nil, fmt.Errorf("album %d: ascii_names needs a name_template", i+1)
+		}
+		items, err := album.Selection()
+		if err != nil {
+			return nil, fmt.Errorf("album %d: %w", i+1, err)
+		}
+		// The tracks of a tracklist file are not loaded yet
+		if items != nil && len(items) == 0 && album.TracklistFile == "" {
+			return nil, fmt.Errorf("album %d: items and skip leave no tracks to download", i+1)
 		}
 	}
 
 	return &cfg, nil
 }
 
+// RecordMusicBrainzID stores mbid as the musicbrainz_id of the album at index
+// (0-based) in the configuration file at path, so later runs reuse the same
+// release instead of searching again. Only the line of the field changes, or
+// one is added (see recordAlbumField).
+func RecordMusicBrainzID(path string, index int, mbid string) error {
+	return recordAlbumField(path, index, "musicbrainz_id", mbid)
+}
+
+// RecordURL stores url as the url of the album at index (0-based) in the
+// configuration file at path, so later runs download from the same playlist
+// instead of searching again. Only the line of the field changes, or one is
+// added (see recordAlbumField).
+func RecordURL(path string, index int, url string) error {
+	return recordAlbumField(path, index, "url", url)
+}
+
+// recordAlbumField sets key to value in the album at index of the
+// configuration file at path. The value replaces the old one on its line,
+// keeping a comment after it; a missing key is added on a line of its own
+// after the album's last one, or at the end of an album written on one line
+// as {url: ...}. Everything else in the file is left as it is. Multi-line
+// values and flow-style albums over several lines cannot be edited in place,
+// so for those the file is encoded anew, which loses blank lines and the
+// spacing of comments.
+func recordAlbumField(path string, index int, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+
+	album, err := albumNode(&doc, index)
+	if err != nil {
+		return err
+	}
+
+	edited, ok := editAlbumField(string(data), album, key, value)
+	if !ok {
+		setMappingValue(album, key, value)
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return fmt.Errorf("encode config: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return fmt.Errorf("encode config: %w", err)
+		}
+		edited = buf.String()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat config file: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(edited), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("write config file: %w", err)
+	}
+	return nil
+}
+
+// editAlbumField sets key to value in the text of a configuration file by
+// rewriting only the line of the key, or adding a line for it, using the
+// positions of the parsed album. It reports false if the album cannot be
+// edited that way.
+func editAlbumField(text string, album *yaml.Node, key, value string) (string, bool) {
+	flow := album.Style&yaml.FlowStyle != 0
+	if len(album.Content) == 0 || (flow && lastLine(album) != album.Line) {
+		return "", false
+	}
+	lines := strings.SplitAfter(text, "\n")
+	field := key + ": " + strconv.Quote(value)
+
+	for i := 0; i+1 < len(album.Content); i += 2 {
+		k, v := album.Content[i], album.Content[i+1]
+		if k.Value != key {
+			continue
+		}
+		if v.Kind != yaml.ScalarNode || (v.Line != k.Line && v.Value != "") ||
+			v.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return "", false
+		}
+		line := lines[k.Line-1]
+		start := k.Column - 1
+		end, ok := scalarEnd(line, k, v, flow)
+		if !ok {
+			return "", false
+		}
+		lines[k.Line-1] = line[:start] + field + line[end:]
+		return strings.Join(lines, ""), true
+	}
+
+	if flow {
+		// A new key goes after the last value, inside the braces
+		k, v := album.Content[len(album.Content)-2], album.Content[len(album.Content)-1]
+		if v.Kind != yaml.ScalarNode {
+			return "", false
+		}
+		line := lines[k.Line-1]
+		end, ok := scalarEnd(line, k, v, flow)
+		if !ok {
+			return "", false
+		}
+		lines[k.Line-1] = line[:end] + ", " + field + line[end:]
+		return strings.Join(lines, ""), true
+	}
+
+	// A new key goes after the album's last line, indented like its first key
+	last := lastLine(album)
+	newline := "\n"
+	if strings.HasSuffix(lines[last-1], "\r\n") {
+		newline = "\r\n"
+	}
+	if !strings.HasSuffix(lines[last-1], "\n") {
+		lines[last-1] += newline
+	}
+	added := strings.Repeat(" ", album.Content[0].Column-1) + field + newline
+	lines = slices.Insert(lines, last, added)
+	return strings.Join(lines, ""), true
+}
+
+// scalarEnd returns the byte offset in line where the value v of key k ends,
+// before any comment, or in a flow mapping before the next "," or "}". It
+// reports false for a value it cannot delimit.
+func scalarEnd(line string, k, v *yaml.Node, flow bool) (int, bool) {
+	if v.Line != k.Line {
+		// "key:" with the value left out
+		colon := strings.IndexByte(line[k.Column-1:], ':')
+		if colon < 0 {
+			return 0, false
+		}
+		return k.Column + colon, true
+	}
+	pos := v.Column - 1
+	if pos >= len(line) {
+		return 0, false
+	}
+	switch {
+	case v.Style&yaml.DoubleQuotedStyle != 0:
+		for i := pos + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case v.Style&yaml.SingleQuotedStyle != 0:
+		for i := pos + 1; i < len(line); i++ {
+			if line[i] != '\'' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+		return 0, false
+	}
+	rest := line[pos:]
+	if i := strings.Index(rest, " #"); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.IndexAny(rest, ",}"); flow && i >= 0 {
+		rest = rest[:i]
+	}
+	if v.Value == "" {
+		// An empty value such as "key: ~" ends at the next blank
+		if i := strings.IndexAny(rest, " \t\r\n"); i >= 0 {
+			rest = rest[:i]
+		}
+	}
+	return pos + len(strings.TrimRight(rest, " \t\r\n")), true
+}
+
+// lastLine returns the last line a node and its children take up.
+func lastLine(node *yaml.Node) int {
+	last := node.Line
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		last += strings.Count(strings.TrimRight(node.Value, "\n"), "\n") + 1
+	}
+	for _, child := range node.Content {
+		last = max(last, lastLine(child))
+	}
+	return last
+}
+
+// albumNode returns the mapping node of the album at index in a parsed document.
+func albumNode(doc *yaml.Node, index int) (*yaml.Node, error) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("config is empty")
+	}
+	albums := mappingValue(doc.Content[0], "albums")
+	if albums == nil || albums.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("no albums defined in configuration")
+	}
+	if index < 0 || index >= len(albums.Content) {
+		return nil, fmt.Errorf("album %d not found in configuration", index+1)
+	}
+	album := albums.Content[index]
+	if album.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("album %d is not a mapping", index+1)
+	}
+	return album, nil
+}
+
+// mappingValue returns the value node stored under key, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to a string value, appending the key if missing.
+func setMappingValue(mapping *yaml.Node, key, value string) {
+	if node := mappingValue(mapping, key); node != nil {
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!str"
+		node.Value = value
+		node.Content = nil
+		return
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle},
+	)
+}
+
+// Selection returns the playlist items or tracks to download: those of
+// items, or the numbers listed in tracks with only_listed, without those of
+// skip. Nil selects everything.
+func (ac *AlbumConfig) Selection() (downloader.Items, error) {
+	items, err := downloader.ParseItems(ac.Items)
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+	skip, err := downloader.ParseItems(ac.Skip)
+	if err != nil {
+		return nil, fmt.Errorf("skip: %w", err)
+	}
+	if ac.OnlyListed {
+		numbers := make([]int, len(ac.Tracks))
+		for i, tc := range ac.Tracks {
+			numbers[i] = trackNumber(tc, i)
+		}
+		listed := downloader.ItemsOf(numbers)
+		if items != nil {
+			// Listed tracks that items selects too
+			listed = listed.Without(downloader.Items(nil).Without(items))
+		}
+		items = listed
+	}
+	return items.Without(skip), nil
+}
+
+// trackNumber returns the number of the track at index i of the tracks.
+func trackNumber(tc TrackConfig, i int) int {
+	if tc.Num > 0 {
+		return tc.Num
+	}
+	return i + 1
+}
+
+// totalTracks returns the number of tracks on the album: total_tracks if
+// set, otherwise the highest listed track number, so that listing only some
+// tracks keeps totals such as 7/12 right.
+func (ac *AlbumConfig) totalTracks() int {
+	if ac.TotalTracks > 0 {
+		return ac.TotalTracks
+	}
+	total := len(ac.Tracks)
+	for i, tc := range ac.Tracks {
+		total = max(total, trackNumber(tc, i))
+	}
+	return total
+}
+
 // ToDownloaderConfig converts an AlbumConfig to a downloader.Config.
 func (ac *AlbumConfig) ToDownloaderConfig(defaultOutputDir string) downloader.Config {
 	outputDir := ac.OutputDir
@@ -91,9 +521,16 @@ func (ac *AlbumConfig) ToDownloaderConfig(defaultOutputDir string) downloader.Co
 			Genre:       ac.Genre,
 		},
 	}
+	// Already validated by Parse
+	cfg.Items, _ = ac.Selection()
+	if ac.NameTemplate != "" {
+		cfg.Naming = &downloader.Naming{ASCII: ac.ASCIINames}
+		cfg.Naming.Template, _ = downloader.ParseNameTemplate(ac.NameTemplate)
+	}
 
-	// Convert track configs to playlist metadata if present
-	if len(ac.Tracks) > 0 || ac.Artist != "" || ac.Album != "" {
+	// Convert track configs to playlist metadata if present; album tags alone
+	// are carried too, so that the config can be merged with looked-up data
+	if len(ac.Tracks) > 0 || cfg.Metadata != (downloader.Metadata{}) || ac.Label != "" || ac.Cover != "" {
 		pm := &downloader.PlaylistMetadata{
 			AlbumInfo: downloader.AlbumMetadata{
 				Title:       ac.Album,
@@ -101,7 +538,8 @@ func (ac *AlbumConfig) ToDownloaderConfig(defaultOutputDir string) downloader.Co
 				AlbumArtist: ac.AlbumArtist,
 				Year:        ac.Year,
 				Genre:       ac.Genre,
-				TotalTracks: len(ac.Tracks),
+				Label:       ac.Label,
+				TotalTracks: ac.totalTracks(),
 				CoverURL:    ac.Cover,
 			},
 		}
@@ -128,14 +566,101 @@ func (ac *AlbumConfig) ToDownloaderConfig(defaultOutputDir string) downloader.Co
 	return cfg
 }
 
+// RateLimitIntervals parses the configured rate limits into intervals per
+// host. The MusicBrainz API host is stored under the empty key.
+func (mc MusicBrainzConfig) RateLimitIntervals() (map[string]time.Duration, error) {
+	limits := map[string]time.Duration{}
+	for host, value := range mc.RateLimits {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("rate limit for %s: %w", host, err)
+		}
+		limits[host] = d
+	}
+	if mc.RateLimit != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(mc.RateLimit))
+		if err != nil {
+			return nil, fmt.Errorf("rate limit: %w", err)
+		}
+		limits[""] = d
+	}
+	return limits, nil
+}
+
+// ProviderNames returns the configured provider chain, or the default one:
+// MusicBrainz and Discogs, followed by the genre map when it has entries.
+func (mc MetadataConfig) ProviderNames() []string {
+	if len(mc.Providers) > 0 {
+		return mc.Providers
+	}
+	names := []string{provider.NameMusicBrainz, provider.NameDiscogs}
+	if len(mc.Genres) > 0 {
+		names = append(names, provider.NameGenres)
+	}
+	return names
+}
+
+// validate checks provider and field names.
+func (mc MetadataConfig) validate() error {
+	known := func(name string) bool {
+		switch name {
+		case provider.NameMusicBrainz, provider.NameDiscogs, provider.NameGenres, provider.NameYouTube:
+			return true
+		}
+		return false
+	}
+	for _, name := range mc.Providers {
+		if !known(name) {
+			return fmt.Errorf("unknown provider %q", name)
+		}
+	}
+	for field, names := range mc.Precedence {
+		if _, err := provider.ParseField(field); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !known(name) && name != provider.NameConfig && name != provider.NameCLI {
+				return fmt.Errorf("precedence of %s: unknown provider %q", field, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ToMusicBrainz converts the preferences, filling in defaults for unset fields.
+func (rp ReleasePreferences) ToMusicBrainz() musicbrainz.Preferences {
+	prefs := musicbrainz.DefaultPreferences()
+	prefs.Countries = rp.Countries
+	if len(rp.Formats) > 0 {
+		prefs.Formats = rp.Formats
+	}
+	if rp.Lookups > 0 {
+		prefs.Lookups = rp.Lookups
+	}
+	return prefs
+}
+
 // NeedsMusicBrainzLookup returns true if the album should fetch metadata from MusicBrainz.
 func (ac *AlbumConfig) NeedsMusicBrainzLookup() bool {
-	return ac.MusicBrainzID != "" || ac.AutoFetch != ""
+	return ac.MusicBrainzID != "" || ac.MusicBrainzReleaseGroupID != "" || ac.AutoFetch != ""
 }
 
 // Example returns an example configuration file content.
 func Example() string {
 	return `# iturtle-smart-fetcher batch configuration
+
+# How auto_fetch ranks MusicBrainz search results (optional)
+release_preferences:
+  countries: ["US", "GB", "XW"]
+  formats: ["Digital Media", "CD"]
+
+# Where album fields come from (optional)
+metadata:
+  precedence:
+    genre: [genres, musicbrainz]
+  genres:
+    Black Kids: "Indie Pop"
+
 albums:
   # Example 1: Manual metadata
   - url: "https://youtube.com/playlist?list=PLxxxxxx"
@@ -159,5 +684,48 @@ albums:
   - url: "https://youtube.com/playlist?list=PLzzzzzz"
     auto_fetch: "Motion City Soundtrack - Commit This to Memory"
     output_dir: "./music/Motion City Soundtrack"
+
+  # Example 4: Pick an edition from a MusicBrainz release group
+  - url: "https://youtube.com/playlist?list=PLwwwwww"
+    musicbrainz_release_group_id: "ghi-789-jkl-012"
+    edition: "deluxe"  # best, original, deluxe or country:XX
+    output_dir: "./music/Black Kids"
+
+  # Example 5: No playlist; the YouTube Music album (or each track) is searched for
+  - auto_fetch: "Black Kids - Partie Traumatic"
+    output_dir: "./music/Black Kids"
+
+  # Example 6: Vinyl-only release from Discogs
+  - url: "https://youtube.com/playlist?list=PLvvvvvv"
+    discogs_id: 1234567
+    output_dir: "./music/Black Kids"
+
+  # Example 7: Follow a playlist; each run only downloads what was added
+  - url: "https://youtube.com/playlist?list=PLuuuuuu"
+    album: "Weekly Mix"
+    output_dir: "./music/Weekly Mix"
+    sync: true
+    sync_removed: "move"  # keep, delete or move (into removed/)
+
+  # Example 8: Leave out the intro and the skits; files keep their album numbers
+  - url: "https://youtube.com/playlist?list=PLvvvvvv"
+    musicbrainz_id: "fedcba98-7654-3210-fedc-ba9876543210"
+    skip: "1,5,9"  # or items: "2-4,6-8,10-"
+
+  # Example 9: Only the tracks listed, tagged as 3/12 and 7/12
+  - url: "https://youtube.com/playlist?list=PLwwwwww"
+    artist: "Artist"
+    album: "Album"
+    total_tracks: 12
+    only_listed: true
+    tracks:
+      - {num: 3, title: "Third Song"}
+      - {num: 7, title: "Seventh Song"}
+
+  # Example 10: Name files from their tags, e.g. "Artist/Album (1999)/03 Title.mp3"
+  - url: "https://youtube.com/playlist?list=PLxxxxxx"
+    musicbrainz_id: "01234567-89ab-cdef-0123-456789abcdef"
+    name_template: "{albumartist}/{album} ({year})/{track:02} {title}"
+    ascii_names: true  # "Sigur Rós" becomes "Sigur Ros"
 `
 }
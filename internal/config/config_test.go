@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParse(t *testing.T) {
@@ -224,3 +227,447 @@ func TestExample(t *testing.T) {
 		t.Error("Example should contain at least one album")
 	}
 }
+
+func TestRecordMusicBrainzID(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "albums.yaml")
+
+	yaml := `# my albums
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    auto_fetch: "Artist 1 - Album 1" # search me
+  - url: "https://youtube.com/playlist?list=PL2"
+    musicbrainz_id: "old-id"
+    tracks:
+      - {num: 1, title: "Track 1"}
+`
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	if err := RecordMusicBrainzID(configPath, 0, "new-id-1"); err != nil {
+		t.Fatalf("RecordMusicBrainzID failed: %v", err)
+	}
+	if err := RecordMusicBrainzID(configPath, 1, "new-id-2"); err != nil {
+		t.Fatalf("RecordMusicBrainzID failed: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if cfg.Albums[0].MusicBrainzID != "new-id-1" {
+		t.Errorf("expected new-id-1, got %q", cfg.Albums[0].MusicBrainzID)
+	}
+	if cfg.Albums[0].AutoFetch != "Artist 1 - Album 1" {
+		t.Errorf("expected auto_fetch to be kept, got %q", cfg.Albums[0].AutoFetch)
+	}
+	if cfg.Albums[1].MusicBrainzID != "new-id-2" {
+		t.Errorf("expected new-id-2, got %q", cfg.Albums[1].MusicBrainzID)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+	for _, comment := range []string{"# my albums", "# search me"} {
+		if !strings.Contains(string(data), comment) {
+			t.Errorf("expected comment %q to be preserved:\n%s", comment, data)
+		}
+	}
+}
+
+func TestRecordMusicBrainzIDOutOfRange(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "albums.yaml")
+	if err := os.WriteFile(configPath, []byte("albums:\n  - url: \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	if err := RecordMusicBrainzID(configPath, 3, "id"); err == nil {
+		t.Fatal("expected error for missing album")
+	}
+}
+
+func TestRecordURL(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "albums.yaml")
+
+	yaml := `albums:
+  - auto_fetch: "Artist 1 - Album 1" # no playlist yet
+    output_dir: "./music"
+`
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	url := "https://music.youtube.com/playlist?list=OLAK5uy_abc"
+	if err := RecordURL(configPath, 0, url); err != nil {
+		t.Fatalf("RecordURL failed: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if cfg.Albums[0].URL != url {
+		t.Errorf("expected %s, got %q", url, cfg.Albums[0].URL)
+	}
+	if cfg.Albums[0].AutoFetch != "Artist 1 - Album 1" {
+		t.Errorf("expected auto_fetch to be kept, got %q", cfg.Albums[0].AutoFetch)
+	}
+}
+
+func TestRecordKeepsLayout(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "albums.yaml")
+	before := `# My albums
+
+albums:
+  # Found by search
+  - auto_fetch: "Artist 1 - Album 1"   # aligned comment
+    musicbrainz_id:
+    tracks:
+      - {num: 1, title: "Intro"}
+
+  # Needs a playlist
+  - musicbrainz_id: 'old-id' # picked by hand
+    comment: |
+      Two
+      lines
+
+  - {auto_fetch: "Artist 3 - Album 3"}  # flow style
+`
+	want := `# My albums
+
+albums:
+  # Found by search
+  - auto_fetch: "Artist 1 - Album 1"   # aligned comment
+    musicbrainz_id: "mbid-1"
+    tracks:
+      - {num: 1, title: "Intro"}
+    url: "https://music.youtube.com/playlist?list=OLAK1"
+
+  # Needs a playlist
+  - musicbrainz_id: "mbid-2" # picked by hand
+    comment: |
+      Two
+      lines
+    url: "https://music.youtube.com/playlist?list=OLAK2"
+
+  - {auto_fetch: "Artist 3 - Album 3", url: "https://music.youtube.com/playlist?list=OLAK3"}  # flow style
+`
+	if err := os.WriteFile(configPath, []byte(before), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	for i := range 3 {
+		if i < 2 {
+			if err := RecordMusicBrainzID(configPath, i, fmt.Sprintf("mbid-%d", i+1)); err != nil {
+				t.Fatalf("RecordMusicBrainzID failed: %v", err)
+			}
+		}
+		if err := RecordURL(configPath, i, fmt.Sprintf("https://music.youtube.com/playlist?list=OLAK%d", i+1)); err != nil {
+			t.Fatalf("RecordURL failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+	if string(data) != want {
+		t.Errorf("expected only the recorded fields to change, got:\n%s", data)
+	}
+	if _, err := LoadFromFile(configPath); err != nil {
+		t.Errorf("expected the edited file to load, got %v", err)
+	}
+}
+
+func TestParseReleasePreferences(t *testing.T) {
+	yaml := `
+release_preferences:
+  countries: ["GB", "US"]
+  lookups: 3
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    auto_fetch: "Artist - Album"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	prefs := cfg.ReleasePreferences.ToMusicBrainz()
+	if len(prefs.Countries) != 2 || prefs.Countries[0] != "GB" {
+		t.Errorf("unexpected countries: %v", prefs.Countries)
+	}
+	if prefs.Lookups != 3 {
+		t.Errorf("expected 3 lookups, got %d", prefs.Lookups)
+	}
+	// Formats fall back to the defaults when not configured
+	if len(prefs.Formats) == 0 || prefs.Formats[0] != "Digital Media" {
+		t.Errorf("expected default formats, got %v", prefs.Formats)
+	}
+}
+
+func TestParseReleaseGroupAndEdition(t *testing.T) {
+	yaml := `
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    musicbrainz_release_group_id: "rg-123"
+    edition: "country:GB"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	album := cfg.Albums[0]
+	if album.MusicBrainzReleaseGroupID != "rg-123" {
+		t.Errorf("unexpected release group ID: %s", album.MusicBrainzReleaseGroupID)
+	}
+	if !album.NeedsMusicBrainzLookup() {
+		t.Error("expected release group ID to trigger a MusicBrainz lookup")
+	}
+}
+
+func TestParseInvalidEdition(t *testing.T) {
+	yaml := `
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    auto_fetch: "Artist - Album"
+    edition: "remastered"
+`
+	if _, err := Parse([]byte(yaml)); err == nil {
+		t.Fatal("expected error for unknown edition")
+	}
+}
+
+func TestParseMusicBrainzSettings(t *testing.T) {
+	yaml := `
+musicbrainz:
+  url: "http://localhost:5000/ws/2"
+  contact: "me@example.com"
+  rate_limit: "0s"
+  rate_limits:
+    coverartarchive.org: "500ms"
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if cfg.MusicBrainz.URL != "http://localhost:5000/ws/2" {
+		t.Errorf("unexpected URL: %s", cfg.MusicBrainz.URL)
+	}
+	if cfg.MusicBrainz.Contact != "me@example.com" {
+		t.Errorf("unexpected contact: %s", cfg.MusicBrainz.Contact)
+	}
+
+	limits, err := cfg.MusicBrainz.RateLimitIntervals()
+	if err != nil {
+		t.Fatalf("RateLimitIntervals failed: %v", err)
+	}
+	if d, ok := limits[""]; !ok || d != 0 {
+		t.Errorf("expected API rate limit 0s, got %v (set: %v)", d, ok)
+	}
+	if limits["coverartarchive.org"] != 500*time.Millisecond {
+		t.Errorf("unexpected cover art rate limit: %v", limits["coverartarchive.org"])
+	}
+}
+
+func TestParseInvalidRateLimit(t *testing.T) {
+	yaml := `
+musicbrainz:
+  rate_limit: "fast"
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+`
+	if _, err := Parse([]byte(yaml)); err == nil {
+		t.Error("expected error for invalid rate limit")
+	}
+}
+
+func TestParseMetadataSettings(t *testing.T) {
+	yaml := `
+metadata:
+  precedence:
+    year: [musicbrainz]
+    genre: [genres, config]
+    track_title: [cli, musicbrainz]
+  genres:
+    Black Kids: Indie Pop
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := strings.Join(cfg.Metadata.ProviderNames(), ","); got != "musicbrainz,discogs,genres" {
+		t.Errorf("expected default chain musicbrainz,discogs,genres, got %s", got)
+	}
+	if cfg.Metadata.Genres["Black Kids"] != "Indie Pop" {
+		t.Errorf("unexpected genres %v", cfg.Metadata.Genres)
+	}
+
+	for _, bad := range []string{
+		"metadata:\n  providers: [lastfm]\n",
+		"metadata:\n  precedence:\n    mood: [musicbrainz]\n",
+		"metadata:\n  precedence:\n    year: [somewhere]\n",
+	} {
+		if _, err := Parse([]byte(bad + "albums:\n  - url: \"x\"\n")); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseAlbumWithoutURL(t *testing.T) {
+	yaml := `
+albums:
+  - auto_fetch: "Black Kids - Partie Traumatic"
+  - musicbrainz_id: "abc-123"
+  - artist: "Black Kids"
+    album: "Partie Traumatic"
+    tracks:
+      - {num: 1, title: "Hit The Heartbrakes"}
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cfg.Albums) != 3 || cfg.Albums[0].URL != "" {
+		t.Errorf("unexpected albums: %+v", cfg.Albums)
+	}
+}
+
+func TestParseDiscogsSettings(t *testing.T) {
+	yaml := `
+discogs:
+  url: "http://localhost:8080"
+  token: "secret"
+  rate_limit: "500ms"
+albums:
+  - discogs_id: 1234
+  - discogs_master_id: 99
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if cfg.Discogs.URL != "http://localhost:8080" || cfg.Discogs.Token != "secret" || cfg.Discogs.RateLimit != "500ms" {
+		t.Errorf("unexpected discogs settings %+v", cfg.Discogs)
+	}
+	if cfg.Albums[0].DiscogsID != 1234 || cfg.Albums[1].DiscogsMasterID != 99 {
+		t.Errorf("unexpected albums: %+v", cfg.Albums)
+	}
+
+	invalid := "discogs:\n  rate_limit: \"fast\"\nalbums:\n  - discogs_id: 1234\n"
+	if _, err := Parse([]byte(invalid)); err == nil {
+		t.Errorf("expected error for %q", invalid)
+	}
+}
+
+func TestParseSyncSettings(t *testing.T) {
+	yaml := `
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    sync: true
+    sync_removed: "move"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if album := cfg.Albums[0]; !album.Sync || album.SyncRemoved != "move" {
+		t.Errorf("unexpected sync settings: %+v", album)
+	}
+
+	for _, invalid := range []string{
+		"albums:\n  - auto_fetch: \"Artist - Album\"\n    sync: true\n",
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    sync: true\n    sync_removed: \"trash\"\n",
+	} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseTrackSelection(t *testing.T) {
+	yaml := `
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    items: "1-8"
+    skip: 3
+  - url: "https://youtube.com/playlist?list=PL2"
+    album: "Album"
+    only_listed: true
+    skip: "7"
+    tracks:
+      - num: 2
+        title: "Two"
+      - num: 7
+        title: "Seven"
+      - num: 9
+        title: "Nine"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	first := cfg.Albums[0].ToDownloaderConfig(".")
+	if got := first.Items.String(); got != "1-2,4-8" {
+		t.Errorf("expected items 1-2,4-8, got %q", got)
+	}
+
+	// Only the listed tracks are downloaded, and they keep the album's total
+	second := cfg.Albums[1].ToDownloaderConfig(".")
+	if got := second.Items.String(); got != "2,9" {
+		t.Errorf("expected items 2,9, got %q", got)
+	}
+	if total := second.PlaylistMetadata.AlbumInfo.TotalTracks; total != 9 {
+		t.Errorf("expected 9 tracks in total, got %d", total)
+	}
+
+	for _, invalid := range []string{
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    items: \"5-3\"\n",
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    skip: \"one\"\n",
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    items: \"2\"\n    skip: \"1-3\"\n",
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    only_listed: true\n",
+	} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseNameTemplate(t *testing.T) {
+	yaml := `
+albums:
+  - url: "https://youtube.com/playlist?list=PL1"
+    name_template: "{artist}/{track:02} {title}"
+    ascii_names: true
+  - url: "https://youtube.com/playlist?list=PL2"
+`
+	cfg, err := Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	naming := cfg.Albums[0].ToDownloaderConfig(".").Naming
+	if naming == nil || naming.Template.String() != "{artist}/{track:02} {title}" || !naming.ASCII {
+		t.Errorf("expected the template with ASCII names, got %+v", naming)
+	}
+	if naming := cfg.Albums[1].ToDownloaderConfig(".").Naming; naming != nil {
+		t.Errorf("expected no renaming without name_template, got %+v", naming)
+	}
+
+	for _, invalid := range []string{
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    name_template: \"../{title}\"\n",
+		"albums:\n  - url: \"https://youtube.com/playlist?list=PL1\"\n    ascii_names: true\n",
+	} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+// AlbumFromMetadata builds an album entry from looked-up metadata, for
+// reviewing and editing before downloading. The album artist is only kept
+// when it differs from the artist.
+func AlbumFromMetadata(pm *downloader.PlaylistMetadata) AlbumConfig {
+	if pm == nil {
+		return AlbumConfig{}
+	}
+
+	info := pm.AlbumInfo
+	ac := AlbumConfig{
+		Artist: info.Artist,
+		Album:  info.Title,
+		Year:   info.Year,
+		Genre:  info.Genre,
+		Label:  info.Label,
+		Cover:  info.CoverURL,
+	}
+	if info.AlbumArtist != info.Artist {
+		ac.AlbumArtist = info.AlbumArtist
+	}
+	if ac.Cover == "" {
+		ac.Cover = info.CoverPath
+	}
+
+	for i, track := range pm.Tracks {
+		num := track.Position
+		if num <= 0 {
+			num = i + 1
+		}
+		ac.Tracks = append(ac.Tracks, TrackConfig{
+			Num:      num,
+			Title:    track.Title,
+			Artist:   track.Artist,
+			Composer: track.Composer,
+			Duration: track.Duration,
+			Comment:  track.Comment,
+		})
+	}
+	return ac
+}
+
+// MarshalAlbums renders albums as an "albums:" block in the style of the
+// example configuration: empty fields are left out, strings are quoted and
+// each track takes one line.
+func MarshalAlbums(albums []AlbumConfig) ([]byte, error) {
+	var list yaml.Node
+	if err := list.Encode(albums); err != nil {
+		return nil, fmt.Errorf("encode albums: %w", err)
+	}
+	for _, album := range list.Content {
+		prune(album)
+		if tracks := mappingValue(album, "tracks"); tracks != nil {
+			for _, track := range tracks.Content {
+				track.Style = yaml.FlowStyle
+			}
+		}
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "albums"},
+		&list,
+	}}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("encode albums: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode albums: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// prune drops empty values from a mapping and its nested mappings, and
+// double-quotes the remaining strings.
+func prune(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		kept := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if isEmpty(value) {
+				continue
+			}
+			prune(value)
+			kept = append(kept, key, value)
+		}
+		node.Content = kept
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			prune(item)
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!str" {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+}
+
+// isEmpty reports whether a value is a zero value worth leaving out.
+func isEmpty(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value == "" || node.Tag == "!!null" || (node.Tag == "!!int" && node.Value == "0")
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
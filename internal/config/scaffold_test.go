@@ -0,0 +1,61 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+func TestMarshalAlbums(t *testing.T) {
+	pm := &downloader.PlaylistMetadata{
+		AlbumInfo: downloader.AlbumMetadata{
+			Title:       "Partie Traumatic",
+			Artist:      "Black Kids",
+			AlbumArtist: "Black Kids",
+			Year:        "2008",
+			Label:       "Almost Gold",
+			CoverURL:    "https://coverartarchive.org/release/abc/front",
+		},
+		Tracks: []downloader.TrackMetadata{
+			{Position: 1, Title: "Hit The Heartbrakes", Duration: "3:24"},
+			{Position: 2, Title: "Partie Traumatic: Reprise", Artist: "Black Kids & Friends", Duration: "3:41"},
+		},
+	}
+	album := AlbumFromMetadata(pm)
+	album.MusicBrainzID = "abc-123"
+
+	data, err := MarshalAlbums([]AlbumConfig{album})
+	if err != nil {
+		t.Fatalf("MarshalAlbums failed: %v", err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		"albums:\n  - artist: \"Black Kids\"\n",
+		"    label: \"Almost Gold\"\n",
+		"    musicbrainz_id: \"abc-123\"\n",
+		"      - {num: 1, title: \"Hit The Heartbrakes\", duration: \"3:24\"}\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"album_artist", "url:", "discogs_id", "genre"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected no %s in output:\n%s", unwanted, out)
+		}
+	}
+
+	cfg, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse of generated config failed: %v\n%s", err, out)
+	}
+	got := cfg.Albums[0]
+	if got.Album != "Partie Traumatic" || got.Year != "2008" || got.Cover != pm.AlbumInfo.CoverURL {
+		t.Errorf("unexpected album after round trip: %+v", got)
+	}
+	if len(got.Tracks) != 2 || got.Tracks[1].Title != "Partie Traumatic: Reprise" || got.Tracks[1].Artist != "Black Kids & Friends" {
+		t.Errorf("unexpected tracks after round trip: %+v", got.Tracks)
+	}
+}
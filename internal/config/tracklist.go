@@ -0,0 +1,442 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tracklist is a track list read from a tracklist_file.
+type Tracklist struct {
+	Title  string // Album title; only CUE sheets carry one
+	Artist string // Album performer; only CUE sheets carry one
+	Tracks []TrackConfig
+}
+
+// LoadTracklist reads a CSV, JSON or CUE track list, chosen by the file
+// extension. Parse errors name the file and line.
+func LoadTracklist(path string) (*Tracklist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tracklist: %w", err)
+	}
+
+	var tl *Tracklist
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv", ".tsv":
+		tl, err = ParseCSVTracklist(data)
+	case ".json":
+		tl, err = ParseJSONTracklist(data)
+	case ".cue":
+		tl, err = ParseCUETracklist(data)
+	default:
+		return nil, fmt.Errorf("%s: unsupported tracklist format %q (want .csv, .json or .cue)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tl, nil
+}
+
+// csvColumns maps lower-cased CSV header names to track fields.
+var csvColumns = map[string]string{
+	"num": "num", "#": "num", "no": "num", "no.": "num", "track": "num", "position": "num",
+	"title": "title", "name": "title", "song": "title",
+	"artist": "artist", "performer": "artist",
+	"composer": "composer", "songwriter": "composer", "writer": "composer",
+	"duration": "duration", "length": "duration", "time": "duration",
+	"comment": "comment", "comments": "comment", "notes": "comment",
+}
+
+// ParseCSVTracklist parses a CSV track list. The header row names the
+// columns, e.g. "#,Title,Artist,Length"; unknown columns are ignored and a
+// title column is required. Tracks without a number are numbered in order.
+// Semicolon- and tab-separated files, as exported by some spreadsheets, are
+// recognized by their header.
+func ParseCSVTracklist(data []byte) (*Tracklist, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = csvDelimiter(data)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("line 1: empty tracklist")
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[name]; ok {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("line 1: header has no title column")
+	}
+
+	tl := &Tracklist{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := r.FieldPos(0)
+
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		tc := TrackConfig{
+			Title:    value("title"),
+			Artist:   value("artist"),
+			Composer: value("composer"),
+			Duration: value("duration"),
+			Comment:  value("comment"),
+		}
+		if num := value("num"); num != "" {
+			n, err := strconv.Atoi(strings.TrimSuffix(num, "."))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("line %d: invalid track number %q", line, num)
+			}
+			tc.Num = n
+		}
+		if tc.Title == "" {
+			return nil, fmt.Errorf("line %d: missing title", line)
+		}
+		tl.Tracks = append(tl.Tracks, tc)
+	}
+	numberTracks(tl.Tracks)
+	return tl, nil
+}
+
+// csvDelimiter picks the separator used in the header line.
+func csvDelimiter(data []byte) rune {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', bytes.Count(header, []byte(","))
+	for _, sep := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(sep))); n > count {
+			best, count = sep, n
+		}
+	}
+	return best
+}
+
+// csvError reports a CSV syntax error at the line its record starts on.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("line %d: %w", parseErr.StartLine, parseErr.Err)
+	}
+	return err
+}
+
+// ParseJSONTracklist parses a JSON array of tracks with the same keys as the
+// tracks of the YAML configuration:
+//
+//	[{"num": 1, "title": "Hit The Heartbrakes", "duration": "3:24"}]
+func ParseJSONTracklist(data []byte) (*Tracklist, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, jsonError(data, err, dec.InputOffset())
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("line %d: expected an array of tracks", lineAt(data, dec.InputOffset()))
+	}
+
+	tl := &Tracklist{}
+	for dec.More() {
+		start := elementStart(data, dec.InputOffset())
+		var tc TrackConfig
+		if err := dec.Decode(&tc); err != nil {
+			return nil, jsonError(data, err, start)
+		}
+		if strings.TrimSpace(tc.Title) == "" {
+			return nil, fmt.Errorf("line %d: missing title", lineAt(data, start))
+		}
+		if tc.Num < 0 {
+			return nil, fmt.Errorf("line %d: invalid track number %d", lineAt(data, start), tc.Num)
+		}
+		tl.Tracks = append(tl.Tracks, tc)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, jsonError(data, err, dec.InputOffset())
+	}
+	numberTracks(tl.Tracks)
+	return tl, nil
+}
+
+// jsonError reports a JSON error at the line of its offset, or of fallback
+// when the error carries none. Type errors are relative to fallback, the
+// start of the value being decoded.
+func jsonError(data []byte, err error, fallback int64) error {
+	offset := fallback
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = fallback + typeErr.Offset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(data))
+		err = fmt.Errorf("unexpected end of file")
+	}
+	return fmt.Errorf("line %d: %w", lineAt(data, offset), err)
+}
+
+// elementStart skips the separator and whitespace before the next array
+// element.
+func elementStart(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(", \t\r\n", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineAt returns the 1-based line of a byte offset.
+func lineAt(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// cueFramesPerSecond is the resolution of CUE sheet times (mm:ss:ff).
+const cueFramesPerSecond = 75
+
+// ParseCUETracklist parses a CUE sheet. TITLE, PERFORMER and SONGWRITER give
+// the titles, artists and composers, and INDEX 01 the start of each track.
+// Durations run to the start of the next track in the same file; the last
+// track has none.
+func ParseCUETracklist(data []byte) (*Tracklist, error) {
+	tl := &Tracklist{}
+	var (
+		track  *TrackConfig
+		starts []int // Start in frames per track, -1 if unknown
+		files  []int // FILE index per track
+		file   = -1
+	)
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := i + 1
+		fields, err := cueFields(strings.TrimSpace(strings.TrimPrefix(raw, "\ufeff")))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		arg := func(n int) (string, error) {
+			if len(fields) <= n {
+				return "", fmt.Errorf("line %d: %s needs %d argument(s)", line, fields[0], n)
+			}
+			return fields[n], nil
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "FILE":
+			if _, err := arg(1); err != nil {
+				return nil, err
+			}
+			file++
+		case "TRACK":
+			num, err := arg(1)
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(num)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("line %d: invalid track number %q", line, num)
+			}
+			tl.Tracks = append(tl.Tracks, TrackConfig{Num: n})
+			track = &tl.Tracks[len(tl.Tracks)-1]
+			starts = append(starts, -1)
+			files = append(files, file)
+		case "TITLE", "PERFORMER", "SONGWRITER":
+			value, err := arg(1)
+			if err != nil {
+				return nil, err
+			}
+			setCUEField(tl, track, strings.ToUpper(fields[0]), value)
+		case "INDEX":
+			if track == nil {
+				return nil, fmt.Errorf("line %d: INDEX before the first TRACK", line)
+			}
+			number, err := arg(1)
+			if err != nil {
+				return nil, err
+			}
+			at, err := arg(2)
+			if err != nil {
+				return nil, err
+			}
+			frames, err := parseCUETime(at)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if n, err := strconv.Atoi(number); err == nil && n == 1 {
+				starts[len(starts)-1] = frames
+			}
+		}
+	}
+
+	if len(tl.Tracks) == 0 {
+		return nil, fmt.Errorf("line 1: no TRACK entries")
+	}
+	for i := range tl.Tracks {
+		if tl.Tracks[i].Artist == tl.Artist {
+			tl.Tracks[i].Artist = ""
+		}
+		if i+1 < len(tl.Tracks) && files[i] == files[i+1] && starts[i] >= 0 && starts[i+1] > starts[i] {
+			tl.Tracks[i].Duration = formatFrames(starts[i+1] - starts[i])
+		}
+	}
+	return tl, nil
+}
+
+// setCUEField stores a TITLE, PERFORMER or SONGWRITER value on the current
+// track, or on the album before the first track.
+func setCUEField(tl *Tracklist, track *TrackConfig, command, value string) {
+	if track == nil {
+		switch command {
+		case "TITLE":
+			tl.Title = value
+		case "PERFORMER":
+			tl.Artist = value
+		}
+		return
+	}
+	switch command {
+	case "TITLE":
+		track.Title = value
+	case "PERFORMER":
+		track.Artist = value
+	case "SONGWRITER":
+		track.Composer = value
+	}
+}
+
+// cueFields splits a CUE line into words, keeping double-quoted strings
+// together.
+func cueFields(line string) ([]string, error) {
+	var fields []string
+	for line != "" {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			fields = append(fields, line[1:end+1])
+			line = strings.TrimLeft(line[end+2:], " \t")
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = strings.TrimLeft(line[end:], " \t")
+	}
+	return fields, nil
+}
+
+// parseCUETime parses an "mm:ss:ff" time into frames.
+func parseCUETime(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q (want mm:ss:ff)", value)
+	}
+	var n [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time %q (want mm:ss:ff)", value)
+		}
+		n[i] = v
+	}
+	if n[1] >= 60 || n[2] >= cueFramesPerSecond {
+		return 0, fmt.Errorf("invalid time %q (want mm:ss:ff)", value)
+	}
+	return (n[0]*60+n[1])*cueFramesPerSecond + n[2], nil
+}
+
+// formatFrames formats frames as "m:ss" or "m:ss.mmm", or with hours when
+// needed.
+func formatFrames(frames int) string {
+	ms := frames * 1000 / cueFramesPerSecond
+	s := ms / 1000
+	var out string
+	if s >= 3600 {
+		out = fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	} else {
+		out = fmt.Sprintf("%d:%02d", s/60, s%60)
+	}
+	if ms%1000 != 0 {
+		out += fmt.Sprintf(".%03d", ms%1000)
+	}
+	return out
+}
+
+// ParseDuration parses a track duration such as "3:45", "1:02:03" or
+// "3:24.533", as written in tracks and by CUE sheets. It returns 0 for
+// anything else.
+func ParseDuration(s string) time.Duration {
+	clock, fraction, hasFraction := strings.Cut(strings.TrimSpace(s), ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0
+	}
+	var total time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + time.Duration(n)*time.Second
+	}
+	if hasFraction {
+		n, err := strconv.Atoi(fraction)
+		if err != nil || n < 0 || len(fraction) > 9 {
+			return 0
+		}
+		for i := len(fraction); i < 9; i++ {
+			n *= 10
+		}
+		total += time.Duration(n)
+	}
+	return total
+}
+
+// numberTracks numbers tracks without a number after the track before them.
+func numberTracks(tracks []TrackConfig) {
+	prev := 0
+	for i := range tracks {
+		if tracks[i].Num == 0 {
+			tracks[i].Num = prev + 1
+		}
+		prev = tracks[i].Num
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVTracklist(t *testing.T) {
+	csv := "#,Title,Length,Rating\n" +
+		"1,Hit The Heartbrakes,3:24,5\n" +
+		"\n" +
+		",\"Partie Traumatic\",3:41,4\n" +
+		"3.,\"I'm Not Gonna Teach Your Boyfriend How to Dance with You\",3:35,5\n"
+
+	tl, err := ParseCSVTracklist([]byte(csv))
+	if err != nil {
+		t.Fatalf("ParseCSVTracklist failed: %v", err)
+	}
+	if len(tl.Tracks) != 3 {
+		t.Fatalf("expected 3 tracks, got %d", len(tl.Tracks))
+	}
+	want := TrackConfig{Num: 2, Title: "Partie Traumatic", Duration: "3:41"}
+	if tl.Tracks[1] != want {
+		t.Errorf("track 2: got %+v, want %+v", tl.Tracks[1], want)
+	}
+	if tl.Tracks[2].Num != 3 {
+		t.Errorf("expected track number 3, got %d", tl.Tracks[2].Num)
+	}
+}
+
+func TestParseCSVTracklistSemicolons(t *testing.T) {
+	tl, err := ParseCSVTracklist([]byte("Track;Name;Artist\n1;Hurricane Jane;Black Kids\n"))
+	if err != nil {
+		t.Fatalf("ParseCSVTracklist failed: %v", err)
+	}
+	if len(tl.Tracks) != 1 || tl.Tracks[0].Title != "Hurricane Jane" || tl.Tracks[0].Artist != "Black Kids" {
+		t.Errorf("unexpected tracks %+v", tl.Tracks)
+	}
+}
+
+func TestParseCSVTracklistErrors(t *testing.T) {
+	tests := map[string]string{
+		"num,length\n1,3:24\n":                 "line 1: header has no title column",
+		"num,title\n1,One\ntwo,Two\n":          `line 3: invalid track number "two"`,
+		"num,title\n1,One\n2,\n":               "line 3: missing title",
+		"num,title\n1,One\n2,\"Two\n3,Three\n": "line 3",
+		"":                                     "line 1: empty tracklist",
+	}
+	for input, want := range tests {
+		_, err := ParseCSVTracklist([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCSVTracklist(%q) error = %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestParseJSONTracklist(t *testing.T) {
+	data := `[
+  {"num": 1, "title": "Hit The Heartbrakes", "duration": "3:24"},
+  {"title": "Partie Traumatic", "composer": "Reggie Youngblood"}
+]`
+	tl, err := ParseJSONTracklist([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseJSONTracklist failed: %v", err)
+	}
+	if len(tl.Tracks) != 2 || tl.Tracks[1].Num != 2 || tl.Tracks[1].Composer != "Reggie Youngblood" {
+		t.Errorf("unexpected tracks %+v", tl.Tracks)
+	}
+}
+
+func TestParseJSONTracklistErrors(t *testing.T) {
+	tests := map[string]string{
+		"[\n  {\"title\": \"One\"},\n  {\"num\": \"two\", \"title\": \"Two\"}\n]": "line 3",
+		"[\n  {\"title\": \"One\"},\n  {\"num\": 2}\n]":                           "line 3: missing title",
+		"[\n  {\"title\": \"One\"}\n  {\"title\": \"Two\"}\n]":                    "line 3",
+		"[\n  {\"title\": \"One\"},\n":                                            "line 3: unexpected end",
+		"{\"title\": \"One\"}":                                                    "line 1: expected an array",
+	}
+	for input, want := range tests {
+		_, err := ParseJSONTracklist([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseJSONTracklist(%q) error = %v, want %q", input, err, want)
+		}
+	}
+}
+
+const cueSheet = `REM GENRE "Indie Pop"
+PERFORMER "Black Kids"
+TITLE "Partie Traumatic"
+FILE "Black Kids - Partie Traumatic.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "Hit The Heartbrakes"
+    PERFORMER "Black Kids"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Partie Traumatic"
+    SONGWRITER "Reggie Youngblood"
+    INDEX 00 03:23:50
+    INDEX 01 03:24:40
+  TRACK 03 AUDIO
+    TITLE "Listen to Your Body"
+    PERFORMER "Black Kids feat. Guest"
+    INDEX 01 07:05:00
+`
+
+func TestParseCUETracklist(t *testing.T) {
+	tl, err := ParseCUETracklist([]byte(cueSheet))
+	if err != nil {
+		t.Fatalf("ParseCUETracklist failed: %v", err)
+	}
+	if tl.Title != "Partie Traumatic" || tl.Artist != "Black Kids" {
+		t.Errorf("unexpected album %q by %q", tl.Title, tl.Artist)
+	}
+
+	want := []TrackConfig{
+		{Num: 1, Title: "Hit The Heartbrakes", Duration: "3:24.533"},
+		{Num: 2, Title: "Partie Traumatic", Composer: "Reggie Youngblood", Duration: "3:40.466"},
+		{Num: 3, Title: "Listen to Your Body", Artist: "Black Kids feat. Guest"},
+	}
+	if len(tl.Tracks) != len(want) {
+		t.Fatalf("expected %d tracks, got %d", len(want), len(tl.Tracks))
+	}
+	for i := range want {
+		if tl.Tracks[i] != want[i] {
+			t.Errorf("track %d: got %+v, want %+v", i+1, tl.Tracks[i], want[i])
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"3:45":     3*time.Minute + 45*time.Second,
+		"1:02:03":  time.Hour + 2*time.Minute + 3*time.Second,
+		"3:24.5":   3*time.Minute + 24*time.Second + 500*time.Millisecond,
+		"3:40.466": 3*time.Minute + 40*time.Second + 466*time.Millisecond,
+		"245":      0,
+		"3:4x":     0,
+		"3:45.":    0,
+	}
+	for s, want := range tests {
+		if got := ParseDuration(s); got != want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", s, got, want)
+		}
+	}
+
+	// Durations read from a CUE sheet are the ones the YouTube search gets
+	tl, err := ParseCUETracklist([]byte(cueSheet))
+	if err != nil {
+		t.Fatalf("ParseCUETracklist failed: %v", err)
+	}
+	if got := ParseDuration(tl.Tracks[0].Duration); got != 204533*time.Millisecond {
+		t.Errorf("expected 3:24.533 for the first track, got %s", got)
+	}
+}
+
+func TestParseCUETracklistErrors(t *testing.T) {
+	tests := map[string]string{
+		"FILE \"a.flac\" WAVE\n  INDEX 01 00:00:00\n":                 "line 2: INDEX before the first TRACK",
+		"FILE \"a.flac\" WAVE\n  TRACK xx AUDIO\n":                    `line 2: invalid track number "xx"`,
+		"FILE \"a.flac\" WAVE\n  TRACK 01 AUDIO\n    INDEX 01 1:75\n": `line 3: invalid time "1:75"`,
+		"TRACK 01 AUDIO\n  TITLE \"Unterminated\n":                    "line 2: unterminated quote",
+		"REM nothing here\n":                                          "no TRACK entries",
+	}
+	for input, want := range tests {
+		_, err := ParseCUETracklist([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCUETracklist(%q) error = %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestLoadFromFileWithTracklist(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "partie.cue"), []byte(cueSheet), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tempDir, "albums.yaml")
+	yaml := `
+albums:
+  - url: "https://youtube.com/watch?v=full-album"
+    tracklist_file: "partie.cue"
+`
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	album := cfg.Albums[0]
+	if len(album.Tracks) != 3 || album.Artist != "Black Kids" || album.Album != "Partie Traumatic" {
+		t.Errorf("unexpected album %+v", album)
+	}
+
+	bad := filepath.Join(tempDir, "bad.csv")
+	if err := os.WriteFile(bad, []byte("num,title\nx,One\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	yaml = "albums:\n  - url: \"x\"\n    tracklist_file: \"" + bad + "\"\n"
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(configPath); err == nil || !strings.Contains(err.Error(), "bad.csv: line 2") {
+		t.Errorf("expected an error naming the file and line, got %v", err)
+	}
+}
+
+func TestParseTracksAndTracklistFile(t *testing.T) {
+	yaml := `
+albums:
+  - url: "x"
+    tracklist_file: "tracks.csv"
+    tracks:
+      - {num: 1, title: "One"}
+`
+	if _, err := Parse([]byte(yaml)); err == nil {
+		t.Error("expected error when both tracks and tracklist_file are set")
+	}
+}
@@ -0,0 +1,192 @@
+package discogs
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+// Discogs tells artists and labels with the same name apart with a number:
+// "Nirvana (2)".
+var artistNumber = regexp.MustCompile(`\s\(\d+\)$`)
+
+// Track positions such as "1-3", "2.05" or "CD2-5" name the disc first.
+var discPosition = regexp.MustCompile(`^(?i:cd|dvd|disc)?(\d+)[-.]\d+$`)
+
+// Vinyl and cassette positions such as "A1" or "B" name the side.
+var sidePosition = regexp.MustCompile(`^([A-Z])[A-Z]*\d*[a-z]?$`)
+
+// ToPlaylistMetadata converts a Discogs release to PlaylistMetadata. Styles
+// become the genre, since Discogs genres ("Rock", "Electronic") are too broad
+// to be useful; the genres are used only when a release has no styles.
+func ToPlaylistMetadata(release *Release) *downloader.PlaylistMetadata {
+	if release == nil {
+		return nil
+	}
+
+	pm := &downloader.PlaylistMetadata{
+		AlbumInfo: downloader.AlbumMetadata{
+			Title:    release.Title,
+			Artist:   ArtistName(release.Artists),
+			Year:     releaseYear(release.Released, release.Year),
+			Genre:    genre(release.Styles, release.Genres),
+			Country:  release.Country,
+			CoverURL: coverURL(release.Images),
+		},
+	}
+	pm.AlbumInfo.AlbumArtist = pm.AlbumInfo.Artist
+	if len(release.Labels) > 0 {
+		pm.AlbumInfo.Label = cleanName(release.Labels[0].Name)
+		pm.AlbumInfo.CatalogNum = release.Labels[0].CatNo
+	}
+
+	pm.Tracks = convertTracklist(release.Tracklist, pm.AlbumInfo.Artist)
+	pm.AlbumInfo.TotalTracks = len(pm.Tracks)
+	return pm
+}
+
+// MasterToPlaylistMetadata converts a master release, for when its main
+// release cannot be fetched. Masters carry no label or country.
+func MasterToPlaylistMetadata(master *Master) *downloader.PlaylistMetadata {
+	if master == nil {
+		return nil
+	}
+
+	pm := &downloader.PlaylistMetadata{
+		AlbumInfo: downloader.AlbumMetadata{
+			Title:    master.Title,
+			Artist:   ArtistName(master.Artists),
+			Year:     releaseYear("", master.Year),
+			Genre:    genre(master.Styles, master.Genres),
+			CoverURL: coverURL(master.Images),
+		},
+	}
+	pm.AlbumInfo.AlbumArtist = pm.AlbumInfo.Artist
+	pm.Tracks = convertTracklist(master.Tracklist, pm.AlbumInfo.Artist)
+	pm.AlbumInfo.TotalTracks = len(pm.Tracks)
+	return pm
+}
+
+// ArtistName joins artist credits the way Discogs displays them, using the
+// name variation printed on the release and dropping disambiguation numbers.
+func ArtistName(artists []Artist) string {
+	var b strings.Builder
+	for i, a := range artists {
+		name := a.ANV
+		if name == "" {
+			name = a.Name
+		}
+		b.WriteString(cleanName(name))
+		if i < len(artists)-1 {
+			if join := strings.TrimSpace(a.Join); join == "" || join == "," {
+				b.WriteString(", ")
+			} else {
+				b.WriteString(" " + join + " ")
+			}
+		}
+	}
+	return b.String()
+}
+
+// cleanName drops the number Discogs adds to tell namesakes apart.
+func cleanName(name string) string {
+	return artistNumber.ReplaceAllString(name, "")
+}
+
+// DiscNumber returns the disc a track position belongs to: the number before
+// the dash in "2-05", or the record for vinyl sides (A and B are disc 1, C and
+// D disc 2). Other positions belong to disc 1.
+func DiscNumber(position string) int {
+	position = strings.TrimSpace(position)
+	if m := discPosition.FindStringSubmatch(position); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			return n
+		}
+	}
+	if m := sidePosition.FindStringSubmatch(position); m != nil {
+		return int(m[1][0]-'A')/2 + 1
+	}
+	return 1
+}
+
+// convertTracklist numbers the playable tracks in order, skipping headings
+// and expanding index tracks into their sub-tracks.
+func convertTracklist(tracklist []Track, albumArtist string) []downloader.TrackMetadata {
+	var tracks []downloader.TrackMetadata
+	discs := 1
+	for _, t := range flatten(tracklist) {
+		tm := downloader.TrackMetadata{
+			Position:   len(tracks) + 1,
+			Title:      t.Title,
+			Duration:   t.Duration,
+			DiscNumber: DiscNumber(t.Position),
+		}
+		if artist := ArtistName(t.Artists); artist != "" && artist != albumArtist {
+			tm.Artist = artist
+		}
+		discs = max(discs, tm.DiscNumber)
+		tracks = append(tracks, tm)
+	}
+
+	for i := range tracks {
+		if discs > 1 {
+			tracks[i].TotalDiscs = discs
+		} else {
+			tracks[i].DiscNumber = 0
+		}
+	}
+	return tracks
+}
+
+// flatten returns the playable tracks of a tracklist.
+func flatten(tracklist []Track) []Track {
+	var tracks []Track
+	for _, t := range tracklist {
+		switch t.Type {
+		case "heading":
+			continue
+		case "index":
+			tracks = append(tracks, flatten(t.SubTracks)...)
+		default:
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// releaseYear returns the year of a "2008-07-07" style date, falling back to
+// the numeric year. Discogs uses 0 for unknown years.
+func releaseYear(released string, year int) string {
+	if len(released) >= 4 && released[:4] != "0000" {
+		return released[:4]
+	}
+	if year > 0 {
+		return strconv.Itoa(year)
+	}
+	return ""
+}
+
+// genre joins styles, or genres when there are no styles.
+func genre(styles, genres []string) string {
+	if len(styles) > 0 {
+		return strings.Join(styles, "; ")
+	}
+	return strings.Join(genres, "; ")
+}
+
+// coverURL returns the primary image, or the first image if none is primary.
+func coverURL(images []Image) string {
+	for _, img := range images {
+		if img.Type == "primary" && img.URI != "" {
+			return img.URI
+		}
+	}
+	for _, img := range images {
+		if img.URI != "" {
+			return img.URI
+		}
+	}
+	return ""
+}
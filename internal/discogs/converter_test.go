@@ -0,0 +1,120 @@
+package discogs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToPlaylistMetadata(t *testing.T) {
+	var release Release
+	if err := json.Unmarshal([]byte(releaseJSON), &release); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := ToPlaylistMetadata(&release)
+	if pm == nil {
+		t.Fatal("expected non-nil PlaylistMetadata")
+	}
+
+	album := pm.AlbumInfo
+	if album.Artist != "Black Kids" || album.AlbumArtist != "Black Kids" {
+		t.Errorf("expected artist without disambiguation number, got %q / %q", album.Artist, album.AlbumArtist)
+	}
+	if album.Year != "2007" || album.Country != "UK" {
+		t.Errorf("unexpected year %q or country %q", album.Year, album.Country)
+	}
+	if album.Genre != "Indie Pop; New Wave" {
+		t.Errorf("expected styles as genre, got %q", album.Genre)
+	}
+	if album.Label != "Almost Gold" || album.CatalogNum != "AG-001" {
+		t.Errorf("unexpected label %q / %q", album.Label, album.CatalogNum)
+	}
+	if album.CoverURL != "https://i.discogs.com/front.jpg" {
+		t.Errorf("expected primary image, got %q", album.CoverURL)
+	}
+
+	if len(pm.Tracks) != 5 || album.TotalTracks != 5 {
+		t.Fatalf("expected 5 tracks without the heading, got %d", len(pm.Tracks))
+	}
+	want := []struct {
+		title string
+		disc  int
+	}{
+		{"Hit the Heartbrakes", 1},
+		{"I'm Not Gonna Teach Your Boyfriend How to Dance with You", 1},
+		{"Hurricane Jane", 1},
+		{"Love Me Already", 1},
+		{"Listen to Your Body", 2},
+	}
+	for i, w := range want {
+		track := pm.Tracks[i]
+		if track.Position != i+1 || track.Title != w.title || track.DiscNumber != w.disc || track.TotalDiscs != 2 {
+			t.Errorf("track %d: got %+v, want %q on disc %d of 2", i+1, track, w.title, w.disc)
+		}
+	}
+	if pm.Tracks[0].Duration != "3:24" {
+		t.Errorf("expected duration 3:24, got %q", pm.Tracks[0].Duration)
+	}
+	if pm.Tracks[3].Artist != "Guest" || pm.Tracks[0].Artist != "" {
+		t.Errorf("expected only the guest track to carry an artist, got %q and %q", pm.Tracks[3].Artist, pm.Tracks[0].Artist)
+	}
+}
+
+func TestSingleDiscHasNoDiscNumbers(t *testing.T) {
+	pm := ToPlaylistMetadata(&Release{Tracklist: []Track{
+		{Position: "A1", Title: "One"},
+		{Position: "B1", Title: "Two"},
+	}})
+	for _, track := range pm.Tracks {
+		if track.DiscNumber != 0 || track.TotalDiscs != 0 {
+			t.Errorf("expected no disc numbers on a single record, got %+v", track)
+		}
+	}
+}
+
+func TestDiscNumber(t *testing.T) {
+	tests := map[string]int{
+		"A1":    1,
+		"B2":    1,
+		"C1":    2,
+		"D3":    2,
+		"E":     3,
+		"AA1":   1,
+		"B1b":   1,
+		"1":     1,
+		"12":    1,
+		"2-05":  2,
+		"3.1":   3,
+		"CD2-4": 2,
+		"":      1,
+	}
+	for position, want := range tests {
+		if got := DiscNumber(position); got != want {
+			t.Errorf("DiscNumber(%q) = %d, want %d", position, got, want)
+		}
+	}
+}
+
+func TestArtistName(t *testing.T) {
+	artists := []Artist{
+		{Name: "Daft Punk", Join: "Feat."},
+		{Name: "Pharrell Williams (2)", ANV: "Pharrell", Join: ","},
+		{Name: "Nile Rodgers"},
+	}
+	if got, want := ArtistName(artists), "Daft Punk Feat. Pharrell, Nile Rodgers"; got != want {
+		t.Errorf("ArtistName() = %q, want %q", got, want)
+	}
+}
+
+func TestMasterToPlaylistMetadata(t *testing.T) {
+	pm := MasterToPlaylistMetadata(&Master{
+		Title:     "Wizard of Ahhhs",
+		Artists:   []Artist{{Name: "Black Kids"}},
+		Year:      2007,
+		Genres:    []string{"Rock"},
+		Tracklist: []Track{{Position: "1", Title: "Hit the Heartbrakes"}},
+	})
+	if pm.AlbumInfo.Year != "2007" || pm.AlbumInfo.Genre != "Rock" || len(pm.Tracks) != 1 {
+		t.Errorf("unexpected metadata %+v", pm)
+	}
+}
@@ -0,0 +1,362 @@
+// Package discogs is a client for the Discogs database API, used for
+// releases that MusicBrainz does not list, such as vinyl-only pressings.
+package discogs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
+	"iturtle-smart-fetcher/internal/ratelimit"
+)
+
+const (
+	// DefaultBaseURL is the public Discogs API
+	DefaultBaseURL = "https://api.discogs.com"
+	// Discogs allows 60 requests per minute with a token and 25 without
+	tokenInterval     = time.Second
+	anonymousInterval = 2400 * time.Millisecond
+	// Retries after the first attempt when the server throttles us
+	maxRetries = 4
+	// Base delay for exponential backoff when no Retry-After is sent. Discogs
+	// counts requests over a moving minute, so waiting briefly rarely helps.
+	defaultBackoff = 2 * time.Second
+)
+
+// ErrNotFound is returned when a release or master does not exist.
+var ErrNotFound = fmt.Errorf("not found")
+
+// ErrNoToken is returned by searches, which Discogs only allows with a token.
+var ErrNoToken = fmt.Errorf("searching Discogs requires a token")
+
+// Client provides access to the Discogs API. It is safe for concurrent use;
+// all requests share one rate limit.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+	userAgent  string
+	cache      *cache.Cache // Optional; nil disables caching
+	interval   time.Duration
+	intervalOK bool          // interval was set explicitly
+	backoff    time.Duration // Base delay before retrying a throttled request
+	limiter    *ratelimit.Limiter
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL points the client at another Discogs API server, e.g. a local
+// stand-in for tests.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/"); baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithToken authenticates requests with a personal access token, which
+// enables searching, image URLs and a higher rate limit.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = strings.TrimSpace(token)
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with every request. Discogs rejects
+// requests without a descriptive one.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		if userAgent = strings.TrimSpace(userAgent); userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithRateLimit sets the minimum interval between requests. An interval of 0
+// disables limiting.
+func WithRateLimit(interval time.Duration) Option {
+	return func(c *Client) {
+		c.interval = max(interval, 0)
+		c.intervalOK = true
+	}
+}
+
+// WithCache serves responses from an on-disk cache.
+func WithCache(c *cache.Cache) Option {
+	return func(client *Client) {
+		client.cache = c
+	}
+}
+
+// NewClient creates a new Discogs API client.
+func NewClient(httpClient *http.Client, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	c := &Client{
+		httpClient: httpClient,
+		baseURL:    DefaultBaseURL,
+		userAgent:  "iturtle-smart-fetcher/dev",
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if !c.intervalOK {
+		c.interval = anonymousInterval
+		if c.token != "" {
+			c.interval = tokenInterval
+		}
+	}
+	c.limiter = ratelimit.New(c.interval)
+	return c
+}
+
+// HasToken reports whether the client is authenticated.
+func (c *Client) HasToken() bool {
+	return c.token != ""
+}
+
+// Artist is an artist credit on a release or track.
+type Artist struct {
+	Name string `json:"name"`
+	ANV  string `json:"anv"`  // Artist name variation used on this release
+	Join string `json:"join"` // Joiner to the next artist, e.g. "&" or "Feat."
+}
+
+// Label is a label credit with its catalog number.
+type Label struct {
+	Name  string `json:"name"`
+	CatNo string `json:"catno"`
+}
+
+// Track is an entry of a tracklist. Headings and index tracks group other
+// entries; index tracks carry them as sub-tracks.
+type Track struct {
+	Position  string   `json:"position"` // e.g. "A1", "B2", "1-3" or "" for headings
+	Type      string   `json:"type_"`    // track, heading or index
+	Title     string   `json:"title"`
+	Duration  string   `json:"duration"` // e.g. "3:24"
+	Artists   []Artist `json:"artists"`
+	SubTracks []Track  `json:"sub_tracks"`
+}
+
+// Image is a release or master image. URIs are only returned to
+// authenticated clients.
+type Image struct {
+	Type   string `json:"type"` // primary or secondary
+	URI    string `json:"uri"`
+	URI150 string `json:"uri150"`
+}
+
+// Release is a specific pressing.
+type Release struct {
+	ID        int      `json:"id"`
+	Title     string   `json:"title"`
+	Artists   []Artist `json:"artists"`
+	Year      int      `json:"year"`
+	Released  string   `json:"released"` // e.g. "2008-07-07" or "2008"
+	Country   string   `json:"country"`
+	Genres    []string `json:"genres"`
+	Styles    []string `json:"styles"`
+	Labels    []Label  `json:"labels"`
+	Tracklist []Track  `json:"tracklist"`
+	Images    []Image  `json:"images"`
+	Notes     string   `json:"notes"`
+	MasterID  int      `json:"master_id"`
+}
+
+// Master groups the releases of one album.
+type Master struct {
+	ID          int      `json:"id"`
+	Title       string   `json:"title"`
+	Artists     []Artist `json:"artists"`
+	Year        int      `json:"year"`
+	Genres      []string `json:"genres"`
+	Styles      []string `json:"styles"`
+	Tracklist   []Track  `json:"tracklist"`
+	Images      []Image  `json:"images"`
+	MainRelease int      `json:"main_release"`
+}
+
+// SearchResult is one database search hit.
+type SearchResult struct {
+	ID       int      `json:"id"`
+	Type     string   `json:"type"`  // release or master
+	Title    string   `json:"title"` // "Artist - Title"
+	Year     string   `json:"year"`
+	Country  string   `json:"country"`
+	Format   []string `json:"format"`
+	Label    []string `json:"label"`
+	CatNo    string   `json:"catno"`
+	MasterID int      `json:"master_id"`
+}
+
+// searchResponse is the body of a database search.
+type searchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+// GetRelease fetches a release by its Discogs ID.
+func (c *Client) GetRelease(ctx context.Context, id int) (*Release, error) {
+	var release Release
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/releases/%d", c.baseURL, id), &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// GetMaster fetches a master release by its Discogs ID.
+func (c *Client) GetMaster(ctx context.Context, id int) (*Master, error) {
+	var master Master
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/masters/%d", c.baseURL, id), &master); err != nil {
+		return nil, err
+	}
+	return &master, nil
+}
+
+// Search searches masters and releases by artist and album title, masters
+// first. It needs a token.
+func (c *Client) Search(ctx context.Context, artist, album string, limit int) ([]SearchResult, error) {
+	if c.token == "" {
+		return nil, ErrNoToken
+	}
+
+	params := url.Values{}
+	if artist != "" {
+		params.Set("artist", artist)
+	}
+	params.Set("release_title", album)
+	params.Set("per_page", strconv.Itoa(limit))
+
+	var results []SearchResult
+	for _, kind := range []string{"master", "release"} {
+		params.Set("type", kind)
+		var resp searchResponse
+		if err := c.getJSON(ctx, c.baseURL+"/database/search?"+params.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		results = append(results, resp.Results...)
+		if len(results) > 0 {
+			break
+		}
+	}
+	return results, nil
+}
+
+// getJSON fetches url and decodes the JSON body into v.
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	body, err := c.doRequest(ctx, url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// doRequest performs a rate-limited GET, served from the cache when fresh.
+// Throttled responses (503/429) are retried with exponential backoff,
+// honoring Retry-After.
+func (c *Client) doRequest(ctx context.Context, url string) ([]byte, error) {
+	key := c.cacheKey(url)
+	cached, ok := c.cache.Get(key)
+	if ok && c.cache.Fresh(cached) {
+		return cachedBody(cached)
+	}
+	if c.cache.Offline() {
+		return nil, fmt.Errorf("%s: %w", url, cache.ErrOffline)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, body, err := c.get(ctx, url, cached)
+		if err != nil {
+			return nil, err
+		}
+
+		if ratelimit.IsThrottled(resp.StatusCode) && attempt < maxRetries {
+			// Hold back every request sharing this client, not just this one
+			c.limiter.Delay(ratelimit.RetryDelay(resp.Header, attempt, c.backoff))
+			continue
+		}
+
+		if resp.StatusCode == http.StatusNotModified && cached != nil {
+			_ = c.cache.Revalidated(cached, resp.Header)
+			return cachedBody(cached)
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotFound {
+			_ = c.cache.Put(key, resp.StatusCode, resp.Header, body)
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("API error: status %d: %s", resp.StatusCode, string(body))
+		}
+
+		return body, nil
+	}
+}
+
+// cacheKey returns the key url is cached under. Discogs leaves image URLs
+// out of responses to anonymous requests, so these are cached apart from
+// those to requests with a token.
+func (c *Client) cacheKey(url string) string {
+	if c.token == "" {
+		return url + "#anonymous"
+	}
+	return url + "#token"
+}
+
+// cachedBody returns the body of a cached response, mapping cached misses
+// back to ErrNotFound.
+func cachedBody(entry *cache.Entry) ([]byte, error) {
+	if entry.Status == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return entry.Body, nil
+}
+
+// get performs a single GET request and reads the whole response body.
+func (c *Client) get(ctx context.Context, url string, cached *cache.Entry) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/vnd.discogs.v2.discogs+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Discogs token="+c.token)
+	}
+	cache.SetValidators(req, cached)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response: %w", err)
+	}
+
+	return resp, body, nil
+}
@@ -0,0 +1,179 @@
+package discogs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
+)
+
+const releaseJSON = `{
+  "id": 1234,
+  "title": "Wizard of Ahhhs",
+  "artists": [{"name": "Black Kids (2)", "anv": "", "join": ""}],
+  "year": 2007,
+  "released": "2007-10-00",
+  "country": "UK",
+  "genres": ["Rock"],
+  "styles": ["Indie Pop", "New Wave"],
+  "labels": [{"name": "Almost Gold (3)", "catno": "AG-001"}],
+  "images": [
+    {"type": "secondary", "uri": "https://i.discogs.com/back.jpg"},
+    {"type": "primary", "uri": "https://i.discogs.com/front.jpg"}
+  ],
+  "master_id": 99,
+  "tracklist": [
+    {"position": "", "type_": "heading", "title": "Side One"},
+    {"position": "A1", "type_": "track", "title": "Hit the Heartbrakes", "duration": "3:24"},
+    {"position": "A2", "type_": "track", "title": "I'm Not Gonna Teach Your Boyfriend How to Dance with You", "duration": "3:35"},
+    {"position": "", "type_": "index", "title": "Medley", "sub_tracks": [
+      {"position": "B1a", "type_": "track", "title": "Hurricane Jane"},
+      {"position": "B1b", "type_": "track", "title": "Love Me Already", "artists": [{"name": "Guest", "join": ""}]}
+    ]},
+    {"position": "C1", "type_": "track", "title": "Listen to Your Body"}
+  ]
+}`
+
+func TestGetRelease(t *testing.T) {
+	var auth, agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, agent = r.Header.Get("Authorization"), r.Header.Get("User-Agent")
+		if r.URL.Path != "/releases/1234" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(releaseJSON))
+	}))
+	defer server.Close()
+
+	client := NewClient(nil, WithBaseURL(server.URL+"/"), WithToken("secret"), WithUserAgent("test/1.0"), WithRateLimit(0))
+	release, err := client.GetRelease(context.Background(), 1234)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release.Title != "Wizard of Ahhhs" || len(release.Tracklist) != 5 {
+		t.Errorf("unexpected release %+v", release)
+	}
+	if auth != "Discogs token=secret" || agent != "test/1.0" {
+		t.Errorf("unexpected headers Authorization=%q User-Agent=%q", auth, agent)
+	}
+
+	if _, err := client.GetRelease(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetMaster(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 99, "title": "Wizard of Ahhhs", "year": 2007, "main_release": 1234}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(nil, WithBaseURL(server.URL), WithRateLimit(0))
+	master, err := client.GetMaster(context.Background(), 99)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if master.MainRelease != 1234 || master.Year != 2007 {
+		t.Errorf("unexpected master %+v", master)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("type") == "master" {
+			w.Write([]byte(`{"results": []}`))
+			return
+		}
+		w.Write([]byte(`{"results": [{"id": 1234, "type": "release", "title": "Black Kids - Wizard of Ahhhs"}]}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewClient(nil, WithBaseURL(server.URL)).Search(context.Background(), "Black Kids", "Wizard of Ahhhs", 5); !errors.Is(err, ErrNoToken) {
+		t.Errorf("expected ErrNoToken without a token, got %v", err)
+	}
+
+	client := NewClient(nil, WithBaseURL(server.URL), WithToken("secret"), WithRateLimit(0))
+	results, err := client.Search(context.Background(), "Black Kids", "Wizard of Ahhhs", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 1234 {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if len(queries) != 2 || !strings.Contains(queries[0], "type=master") || !strings.Contains(queries[1], "release_title=Wizard+of+Ahhhs") {
+		t.Errorf("expected a master search then a release search, got %v", queries)
+	}
+}
+
+func TestRetryWhenThrottled(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id": 99}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(nil, WithBaseURL(server.URL), WithRateLimit(0))
+	client.backoff = time.Millisecond
+	if _, err := client.GetMaster(context.Background(), 99); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected two retries, got %d calls", calls)
+	}
+}
+
+func TestCacheKeepsAnonymousResponsesApart(t *testing.T) {
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "" {
+			// Anonymous responses have no image URLs
+			w.Write([]byte(`{"id": 1234, "title": "Wizard of Ahhhs"}`))
+			return
+		}
+		w.Write([]byte(releaseJSON))
+	}))
+	defer server.Close()
+
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous := NewClient(nil, WithBaseURL(server.URL), WithRateLimit(0), WithCache(c))
+	withToken := NewClient(nil, WithBaseURL(server.URL), WithToken("secret"), WithRateLimit(0), WithCache(c))
+
+	if _, err := anonymous.GetRelease(context.Background(), 1234); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release, err := withToken.GetRelease(context.Background(), 1234)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(release.Images) == 0 {
+		t.Errorf("expected the release with images for a client with a token, got %+v", release)
+	}
+	if _, err := withToken.GetRelease(context.Background(), 1234); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(auths) != 2 || auths[0] != "" || auths[1] != "Discogs token=secret" {
+		t.Errorf("expected one anonymous and one authenticated request, got %q", auths)
+	}
+}
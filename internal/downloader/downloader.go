@@ -2,23 +2,29 @@ package downloader
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
-	"sort"
+	"strconv"
 	"strings"
+
+	"iturtle-smart-fetcher/internal/cache"
 )
 
 // Downloader orchestrates fetching audio with yt-dlp and tagging it with ffmpeg.
 type Downloader struct {
 	runner     Runner
 	httpClient *http.Client
+	cache      *cache.Cache
 	progress   *ProgressPrinter
+	jobs       int           // ffmpeg processes tagging one download's files
+	tagSlots   chan struct{} // ffmpeg processes tagging at once, across downloads
+	fetchSlots chan struct{} // yt-dlp downloads at once, across downloads (nil for no limit)
 }
 
 // New creates a Downloader with sensible defaults for runner and HTTP client.
@@ -33,15 +39,64 @@ func New(r Runner, client *http.Client) *Downloader {
 		runner:     r,
 		httpClient: client,
 		progress:   NewProgressPrinter(os.Stdout),
+		jobs:       1,
+		tagSlots:   make(chan struct{}, 1),
 	}
 }
 
-// Download fetches audio from the provided URL, embeds metadata and cover art,
-// and returns the relative paths of the new files.
-func (d *Downloader) Download(ctx context.Context, cfg Config) ([]string, error) {
-	if strings.TrimSpace(cfg.URL) == "" {
+// WithOutput returns a Downloader that reports to w instead, e.g. a buffer
+// for one album of several in flight. It shares the runner, the cache and
+// the limits on yt-dlp and ffmpeg processes with d. Progress animations are
+// left out, since w is not a terminal line.
+func (d *Downloader) WithOutput(w io.Writer) *Downloader {
+	out := *d
+	out.progress = NewProgressPrinter(w)
+	out.progress.static = true
+	return &out
+}
+
+// SetCache makes cover downloads go through an on-disk cache.
+func (d *Downloader) SetCache(c *cache.Cache) {
+	d.cache = c
+}
+
+// SetJobs sets how many files are tagged at once, in total over the
+// downloads of d and the Downloaders made from it. Values below 1 mean 1.
+func (d *Downloader) SetJobs(n int) {
+	d.jobs = max(n, 1)
+	d.tagSlots = make(chan struct{}, d.jobs)
+}
+
+// SetDownloads sets how many yt-dlp downloads run at once, in total over
+// the downloads of d and the Downloaders made from it. Values below 1 mean
+// no limit.
+func (d *Downloader) SetDownloads(n int) {
+	d.fetchSlots = nil
+	if n > 0 {
+		d.fetchSlots = make(chan struct{}, n)
+	}
+}
+
+// Result is what a download produced.
+type Result struct {
+	Files      []string         // New files, relative to the output directory
+	Downloaded []DownloadedFile // The same files with their videos and tags
+	Failed     []FailedItem     // Items yt-dlp skipped, e.g. private or blocked videos
+	Unfinished []string         // Downloaded files that could not be tagged, verified or moved into place
+}
+
+// Download fetches audio from the provided URL, or from one video per track
+// when TrackSources are set, and embeds metadata and cover art. Items that
+// fail do not fail the download as long as others arrive; the result lists
+// them, also when the download fails as a whole.
+func (d *Downloader) Download(ctx context.Context, cfg Config) (*Result, error) {
+	if strings.TrimSpace(cfg.URL) == "" && len(cfg.TrackSources) == 0 {
 		return nil, errors.New("url is required")
 	}
+	if cfg.Items != nil && len(cfg.Items) == 0 {
+		// yt-dlp would read an empty --playlist-items as the whole playlist
+		return nil, errors.New("the item selection is empty: nothing to download")
+	}
 
 	if cfg.OutputDir == "" {
 		cfg.OutputDir = "."
@@ -56,48 +111,19 @@ func (d *Downloader) Download(ctx context.Context, cfg Config) ([]string, error)
 		return nil, fmt.Errorf("create output dir: %w", err)
 	}
 
-	before, err := snapshotFiles(cfg.OutputDir, format)
-	if err != nil {
-		return nil, err
-	}
-
 	ytCmd := strings.TrimSpace(cfg.YtDLPPath)
 	if ytCmd == "" {
 		ytCmd = "yt-dlp"
 	}
 
-	d.progress.PrintSection("Downloading from YouTube")
-	d.progress.PrintStart(fmt.Sprintf("Fetching audio from %s", cfg.URL))
-
-	ytArgs := buildYtDlpArgs(cfg.URL, cfg.OutputDir, format)
-	if _, err := d.runner.Run(ctx, ytCmd, ytArgs...); err != nil {
-		d.progress.PrintError("Download failed")
-		return nil, err
-	}
-
-	after, err := snapshotFiles(cfg.OutputDir, format)
-	if err != nil {
-		return nil, err
-	}
-
-	newFiles := diffFiles(before, after)
-	if len(newFiles) == 0 {
-		d.progress.PrintError("No new audio files found")
-		return nil, errors.New("no new audio files found after download")
-	}
-
-	d.progress.PrintComplete("Downloaded", len(newFiles))
-	for _, file := range newFiles {
-		d.progress.PrintFile(file)
-	}
-
-	// Determine cover path - check playlist metadata first, then config
+	// Cover and tags are settled first, so that each file can be tagged as
+	// soon as it arrives. An explicit cover wins over playlist metadata.
 	coverSource := cfg.Cover
-	if cfg.PlaylistMetadata != nil && cfg.PlaylistMetadata.AlbumInfo.CoverURL != "" {
-		coverSource = cfg.PlaylistMetadata.AlbumInfo.CoverURL
-	}
-	if cfg.PlaylistMetadata != nil && cfg.PlaylistMetadata.AlbumInfo.CoverPath != "" {
+	if coverSource == "" && cfg.PlaylistMetadata != nil {
 		coverSource = cfg.PlaylistMetadata.AlbumInfo.CoverPath
+		if coverSource == "" {
+			coverSource = cfg.PlaylistMetadata.AlbumInfo.CoverURL
+		}
 	}
 
 	coverPath, cleanup, err := d.prepareCover(ctx, coverSource)
@@ -114,38 +140,288 @@ func (d *Downloader) Download(ctx context.Context, cfg Config) ([]string, error)
 		cfg.Metadata.Comment != "" || coverPath != "" ||
 		cfg.PlaylistMetadata != nil
 
+	ffmpegCmd := strings.TrimSpace(cfg.FFmpegPath)
+	if ffmpegCmd == "" {
+		ffmpegCmd = "ffmpeg"
+	}
+	ffprobeCmd := strings.TrimSpace(cfg.FFprobePath)
+	if ffprobeCmd == "" {
+		ffprobeCmd = "ffprobe"
+	}
+
+	// yt-dlp writes into the staging directory; files that are not finished
+	// stay there for the next run, and the directory goes once it is empty
+	staging := filepath.Join(cfg.OutputDir, StagingDir)
+	if err := os.MkdirAll(staging, 0o755); err != nil {
+		return nil, fmt.Errorf("create staging dir: %w", err)
+	}
+	defer os.Remove(staging)
+
+	// Files an earlier run got are not downloaded again; those it did not
+	// finish are picked up at the stage where it stopped
+	previous := cfg.Journal.resumable(cfg.OutputDir)
+	pipe := d.startPipeline(ctx, cfg, ffmpegCmd, ffprobeCmd, coverPath, hasMetadata)
+	defer d.flushJournal(cfg.Journal)
+	finished := 0
+	for _, f := range previous {
+		if f.Stage == StageVerified {
+			finished++
+			continue
+		}
+		pipe.resume(DownloadedFile{Path: f.Path, ID: f.ID, Index: f.Index}, f.Stage)
+	}
+
+	// yt-dlp reports every file it writes, so only those are tagged, each
+	// while the next ones are downloading
+	result := &Result{}
+	d.progress.PrintSection("Downloading from YouTube")
+	if len(previous) > 0 {
+		d.progress.PrintStart(fmt.Sprintf("Resuming: %d file(s) finished, %d to complete", finished, len(previous)-finished))
+	}
+	var fetchErr error
+	if len(cfg.TrackSources) > 0 {
+		result.Failed = d.downloadTracks(ctx, ytCmd, skipTracks(selectTracks(cfg.TrackSources, cfg.Items), previous), staging, format, pipe.add)
+	} else {
+		d.progress.PrintStart(fmt.Sprintf("Fetching audio from %s", cfg.URL))
+
+		ytArgs := buildYtDlpArgs(cfg.URL, staging, format)
+		if cfg.Items != nil {
+			ytArgs = beforeURL(ytArgs, "--playlist-items", cfg.Items.String())
+		}
+		if len(previous) > 0 {
+			archive, cleanup, err := writeDownloadArchive(previous)
+			if err != nil {
+				pipe.wait()
+				return nil, err
+			}
+			defer cleanup()
+			ytArgs = beforeURL(ytArgs, "--download-archive", archive)
+		}
+		var files []DownloadedFile
+		files, result.Failed, fetchErr = d.fetch(ctx, ytCmd, ytArgs, staging, func(p DownloadProgress) {
+			d.progress.PrintProgress(p.String())
+		}, pipe.add)
+		d.indexFailures(ctx, ytCmd, cfg.URL, result.Failed)
+		// With --ignore-errors yt-dlp also fails when only some items did
+		if len(files) > 0 || len(previous) > 0 {
+			if ctx.Err() == nil {
+				fetchErr = nil
+			}
+		}
+	}
+
+	done, unfinished, errs := pipe.wait()
+	d.progress.ClearLine()
+	result.Downloaded = done
+	result.Files = make([]string, len(done))
+	for i, file := range done {
+		result.Files[i] = file.Path
+	}
+	for _, file := range unfinished {
+		result.Unfinished = append(result.Unfinished, file.Path)
+	}
+	if fetchErr != nil {
+		// What arrived before the download failed is finished all the same
+		d.progress.PrintError("Download failed")
+		d.printFailures(result.Failed)
+		return result, fetchErr
+	}
+
+	total := len(done) + len(unfinished)
+	if total == 0 && finished > 0 {
+		d.progress.PrintComplete("Every file was finished by an earlier run", finished)
+		d.printFailures(result.Failed)
+		return result, nil
+	}
+	if total == 0 {
+		d.progress.PrintError("No new audio files found")
+		d.printFailures(result.Failed)
+		return result, errors.New("no new audio files found after download")
+	}
+
+	// Files that could not be finished stay in the staging directory; the
+	// journal has them at the stage they got to, so a resumed run finishes
+	// them
+	if len(errs) > 0 {
+		d.progress.PrintError(fmt.Sprintf("%d of %d file(s) could not be finished", len(errs), total))
+		d.printFailures(result.Failed)
+		return result, fmt.Errorf("%d file(s) could not be finished: %w", len(errs), errors.Join(errs...))
+	}
 	if hasMetadata {
-		d.progress.PrintSection("Applying Metadata")
-		d.progress.PrintStart("Embedding ID3 tags and cover art")
+		d.progress.PrintComplete("Downloaded and tagged", len(done))
+	} else {
+		d.progress.PrintComplete("Downloaded", len(done))
+	}
+
+	d.progress.PrintSection("Complete")
+	fmt.Fprintf(d.progress.writer, "🎵 Successfully processed %d file(s) 🎵\n\n", len(done))
+	d.printFailures(result.Failed)
+
+	return result, nil
+}
 
-		ffmpegCmd := strings.TrimSpace(cfg.FFmpegPath)
-		if ffmpegCmd == "" {
-			ffmpegCmd = "ffmpeg"
+// record notes in journal that a file reached stage. A journal that cannot
+// be written only costs the ability to resume, so the download goes on.
+func (d *Downloader) record(journal *AlbumJournal, file DownloadedFile, stage FileStage) {
+	if err := journal.record(file, stage); err != nil {
+		d.progress.PrintWarning(fmt.Sprintf("Could not update the journal: %v", err))
+	}
+}
+
+// flushJournal writes what journal has not written yet, at the end of a
+// download.
+func (d *Downloader) flushJournal(journal *AlbumJournal) {
+	if err := journal.flush(); err != nil {
+		d.progress.PrintWarning(fmt.Sprintf("Could not update the journal: %v", err))
+	}
+}
+
+// selectTracks returns the sources whose track is in items.
+func selectTracks(sources []TrackSource, items Items) []TrackSource {
+	var selected []TrackSource
+	for _, src := range sources {
+		if items.Contains(src.Position) {
+			selected = append(selected, src)
+		}
+	}
+	return selected
+}
+
+// skipTracks returns the sources whose track an earlier run did not get.
+func skipTracks(sources []TrackSource, previous []JournalFile) []TrackSource {
+	got := map[int]bool{}
+	for _, f := range previous {
+		if f.Index > 0 {
+			got[f.Index] = true
 		}
+	}
+	var remaining []TrackSource
+	for _, src := range sources {
+		if !got[src.Position] {
+			remaining = append(remaining, src)
+		}
+	}
+	return remaining
+}
 
-		for i, file := range newFiles {
-			d.progress.PrintProgress(fmt.Sprintf("Tagging %d/%d: %s", i+1, len(newFiles), filepath.Base(file)))
+// printFailures lists the items that could not be downloaded.
+func (d *Downloader) printFailures(failed []FailedItem) {
+	if len(failed) == 0 {
+		return
+	}
+	d.progress.PrintWarning(fmt.Sprintf("%d item(s) could not be downloaded:", len(failed)))
+	for _, item := range failed {
+		d.progress.PrintFailure(item)
+	}
+	fmt.Fprintf(d.progress.writer, "\n")
+}
 
-			// Determine metadata for this file
-			meta := cfg.Metadata
-			if cfg.PlaylistMetadata != nil {
-				meta = d.getTrackMetadata(cfg.PlaylistMetadata, file, i)
+// downloadTracks downloads one video per track and hands each file to
+// onFile with its track position. A failed track is reported and skipped so
+// that the rest of the album still arrives; the caller notices when nothing
+// was downloaded at all.
+func (d *Downloader) downloadTracks(ctx context.Context, ytCmd string, sources []TrackSource, outputDir, format string, onFile func(DownloadedFile)) []FailedItem {
+	var failed []FailedItem
+	for i, src := range sources {
+		d.progress.PrintProgress(fmt.Sprintf("Fetching track %d/%d from %s", i+1, len(sources), src.URL))
+
+		args := buildTrackArgs(src.URL, outputDir, format, src.Position)
+		files, failures, err := d.fetch(ctx, ytCmd, args, outputDir, func(p DownloadProgress) {
+			p.Item, p.Items = i+1, len(sources)
+			d.progress.PrintProgress(p.String())
+		}, func(file DownloadedFile) {
+			file.Index = src.Position
+			onFile(file)
+		})
+		if err != nil && len(files) == 0 {
+			d.progress.ClearLine()
+			d.progress.PrintWarning(fmt.Sprintf("Track %d failed: %v", src.Position, err))
+			if len(failures) == 0 {
+				failures = []FailedItem{{Category: FailureOther, Reason: err.Error()}}
 			}
-
-			if err := d.applyMetadata(ctx, ffmpegCmd, filepath.Join(cfg.OutputDir, file), coverPath, meta); err != nil {
-				d.progress.ClearLine()
-				d.progress.PrintError(fmt.Sprintf("Failed to tag %s: %v", file, err))
-				return newFiles, err
+			for _, item := range failures {
+				item.URL, item.Index = src.URL, src.Position
+				failed = append(failed, item)
 			}
 		}
-		d.progress.ClearLine()
-		d.progress.PrintComplete("Metadata applied to all files", len(newFiles))
 	}
+	d.progress.ClearLine()
+	return failed
+}
 
-	d.progress.PrintSection("Complete")
-	fmt.Fprintf(os.Stdout, "🎵 Successfully processed %d file(s) 🎵\n\n", len(newFiles))
+// fetch runs yt-dlp and returns the files it wrote and the items it could
+// not download. The files are reported by yt-dlp through a list file, and
+// handed to onFile as they appear in it while yt-dlp runs.
+func (d *Downloader) fetch(ctx context.Context, ytCmd string, args []string, outputDir string, onProgress func(DownloadProgress), onFile func(DownloadedFile)) ([]DownloadedFile, []FailedItem, error) {
+	list, err := os.CreateTemp("", "iturtle-files-*.txt")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create file list: %w", err)
+	}
+	list.Close()
+	defer os.Remove(list.Name())
+
+	args = beforeURL(args, "--print-to-file", filePrintTemplate, list.Name())
+	if d.fetchSlots != nil {
+		select {
+		case d.fetchSlots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		defer func() { <-d.fetchSlots }()
+	}
+	watcher := &fileWatcher{path: list.Name(), dir: outputDir, seen: map[string]bool{}, onFile: onFile}
+	output, runErr := d.runYtDlp(ctx, ytCmd, args, onProgress, func() {
+		// A failed read is retried with the next line of output
+		_ = watcher.poll(false)
+	})
 
-	return newFiles, nil
+	if err := watcher.poll(true); err != nil {
+		return watcher.files, parseFailures(output), fmt.Errorf("read file list: %w", err)
+	}
+	return watcher.files, parseFailures(output), runErr
+}
+
+// beforeURL returns yt-dlp args with extra inserted before the URL, which
+// stays last.
+func beforeURL(args []string, extra ...string) []string {
+	url := args[len(args)-1]
+	out := append(args[:len(args)-1:len(args)-1], extra...)
+	return append(out, url)
+}
+
+// runYtDlp runs yt-dlp and reports its progress to onProgress as it goes,
+// provided the runner can stream output. onOutput is called after every
+// line of output.
+func (d *Downloader) runYtDlp(ctx context.Context, ytCmd string, args []string, onProgress func(DownloadProgress), onOutput func()) (string, error) {
+	sr, ok := d.runner.(StreamRunner)
+	if !ok {
+		return d.runner.Run(ctx, ytCmd, args...)
+	}
+	parser := newProgressParser()
+	return sr.Stream(ctx, func(line string) {
+		if parser.Parse(line) {
+			onProgress(parser.state)
+		}
+		onOutput()
+	}, ytCmd, args...)
+}
+
+// buildTrackArgs downloads a single video, naming the file after the track
+// position so that per-track metadata lines up as it does for playlists.
+func buildTrackArgs(url, outputDir, format string, position int) []string {
+	template := filepath.Join(outputDir, fmt.Sprintf("%02d - %%(title)s.%%(ext)s", position))
+	return []string{
+		"--extract-audio",
+		"--audio-format", format,
+		"--audio-quality", "0",
+		"--prefer-ffmpeg",
+		"--no-playlist",
+		"--no-continue",
+		"--newline",
+		"--progress-template", progressTemplate,
+		"-o", template,
+		url,
+	}
 }
 
 func buildYtDlpArgs(url, outputDir, format string) []string {
@@ -160,48 +436,62 @@ func buildYtDlpArgs(url, outputDir, format string) []string {
 		"--ignore-errors",
 		"--no-continue",
 		"--newline",
+		"--progress-template", progressTemplate,
 		"-o", template,
 		url,
 	}
 }
 
-func snapshotFiles(dir, format string) (map[string]struct{}, error) {
-	files := map[string]struct{}{}
-	targetExt := strings.ToLower("." + strings.TrimPrefix(format, "."))
+// filePrintTemplate makes yt-dlp write "id|playlist index|path" to the file
+// list for every file once it has its final name. Unlike --print,
+// --print-to-file leaves yt-dlp's regular output alone.
+const filePrintTemplate = "after_move:%(id)s|%(playlist_index|0)s|%(filepath)s"
+
+// DownloadedFile is an audio file written by yt-dlp.
+type DownloadedFile struct {
+	Path  string   // Relative to the output directory
+	ID    string   // Video ID
+	Index int      // Playlist index, or track position of a per-track download (0 if unknown)
+	Tags  Metadata // Tags the file was written with, if any
+}
 
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if targetExt == "." && !strings.HasPrefix(format, ".") {
-			return nil
+// parseDownloadedFiles reads the files yt-dlp reported through
+// filePrintTemplate, in the order they were written.
+func parseDownloadedFiles(list, outputDir string) []DownloadedFile {
+	var files []DownloadedFile
+	seen := map[string]bool{}
+	for _, line := range strings.Split(list, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "|", 3)
+		if len(fields) != 3 || fields[2] == "" || fields[2] == "NA" {
+			continue
 		}
-		if !strings.EqualFold(filepath.Ext(d.Name()), targetExt) {
-			return nil
+		index, _ := strconv.Atoi(fields[1])
+		path := relativePath(outputDir, fields[2])
+		if seen[path] {
+			continue
 		}
-		rel, err := filepath.Rel(dir, path)
-		if err != nil {
-			return err
-		}
-		files[rel] = struct{}{}
-		return nil
-	})
-
-	return files, err
+		seen[path] = true
+		files = append(files, DownloadedFile{Path: path, ID: fields[0], Index: index})
+	}
+	return files
 }
 
-func diffFiles(before, after map[string]struct{}) []string {
-	var files []string
-	for path := range after {
-		if _, exists := before[path]; !exists {
-			files = append(files, path)
-		}
+// relativePath returns path relative to dir, or path itself if it lies
+// outside of dir.
+func relativePath(dir, path string) string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return path
 	}
-	sort.Strings(files)
-	return files
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
 }
 
 func (d *Downloader) prepareCover(ctx context.Context, cover string) (string, func(), error) {
@@ -216,32 +506,62 @@ func (d *Downloader) prepareCover(ctx context.Context, cover string) (string, fu
 		return cover, func() {}, nil
 	}
 
+	data, err := d.fetchCover(ctx, cover)
+	if err != nil {
+		return "", func() {}, err
+	}
+
+	tmp, err := os.CreateTemp("", "iturtle-cover-*")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("create temp cover: %w", err)
+	}
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return "", func() {}, fmt.Errorf("write cover: %w", err)
+	}
+
+	return tmp.Name(), func() { _ = os.Remove(tmp.Name()) }, nil
+}
+
+// fetchCover downloads a cover image, serving it from the cache when possible.
+func (d *Downloader) fetchCover(ctx context.Context, cover string) ([]byte, error) {
+	cached, ok := d.cache.Get(cover)
+	if ok && d.cache.Fresh(cached) && cached.Status == http.StatusOK {
+		return cached.Body, nil
+	}
+	if d.cache.Offline() {
+		return nil, fmt.Errorf("download cover: %w", cache.ErrOffline)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cover, nil)
 	if err != nil {
-		return "", func() {}, fmt.Errorf("create cover request: %w", err)
+		return nil, fmt.Errorf("create cover request: %w", err)
 	}
+	cache.SetValidators(req, cached)
 
 	resp, err := d.httpClient.Do(req)
 	if err != nil {
-		return "", func() {}, fmt.Errorf("download cover: %w", err)
+		return nil, fmt.Errorf("download cover: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_ = d.cache.Revalidated(cached, resp.Header)
+		return cached.Body, nil
+	}
 	if resp.StatusCode >= 300 {
-		return "", func() {}, fmt.Errorf("download cover: unexpected status %d", resp.StatusCode)
+		return nil, fmt.Errorf("download cover: unexpected status %d", resp.StatusCode)
 	}
 
-	tmp, err := os.CreateTemp("", "iturtle-cover-*")
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", func() {}, fmt.Errorf("create temp cover: %w", err)
-	}
-	defer tmp.Close()
-
-	if _, err := io.Copy(tmp, resp.Body); err != nil {
-		return "", func() {}, fmt.Errorf("write cover: %w", err)
+		return nil, fmt.Errorf("read cover: %w", err)
 	}
+	// Failing to cache only costs another download next time
+	_ = d.cache.Put(cover, resp.StatusCode, resp.Header, data)
 
-	return tmp.Name(), func() { _ = os.Remove(tmp.Name()) }, nil
+	return data, nil
 }
 
 func (d *Downloader) applyMetadata(ctx context.Context, ffmpegCmd, filePath, coverPath string, meta Metadata) error {
@@ -256,6 +576,58 @@ func (d *Downloader) applyMetadata(ctx context.Context, ffmpegCmd, filePath, cov
 	return os.Rename(tmpPath, filePath)
 }
 
+// probeOutput is what verifyFile reads of ffprobe's JSON output.
+type probeOutput struct {
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// verifyFile reads a file back with ffprobe: it has to be audio with a
+// length, and if tags were embedded, its title, artist and album have to
+// read back as written. This catches files that ffmpeg wrote badly or left
+// untagged, which a size check lets through.
+func (d *Downloader) verifyFile(ctx context.Context, ffprobeCmd, path string, tags *Metadata) error {
+	if err := checkFile(path); err != nil {
+		return err
+	}
+	output, err := d.runner.Run(ctx, ffprobeCmd, "-v", "error", "-show_entries", "format=duration:format_tags", "-of", "json", path)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	var probe probeOutput
+	// Warnings ffprobe prints about the file may come before the JSON
+	start := strings.IndexByte(output, '{')
+	if start < 0 {
+		return fmt.Errorf("verify: no ffprobe output for %s", filepath.Base(path))
+	}
+	if err := json.NewDecoder(strings.NewReader(output[start:])).Decode(&probe); err != nil {
+		return fmt.Errorf("verify: read ffprobe output: %w", err)
+	}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err != nil || seconds <= 0 {
+		return fmt.Errorf("verify: %s has no audio length", filepath.Base(path))
+	}
+	if tags == nil {
+		return nil
+	}
+	// Formats differ in the case of tag names, e.g. TITLE in FLAC files
+	read := map[string]string{}
+	for key, value := range probe.Format.Tags {
+		read[strings.ToLower(key)] = strings.TrimSpace(value)
+	}
+	for _, tag := range []struct{ key, want string }{
+		{"title", tags.Title},
+		{"artist", tags.Artist},
+		{"album", tags.Album},
+	} {
+		if want := strings.TrimSpace(tag.want); want != "" && read[tag.key] != want {
+			return fmt.Errorf("verify: %s tag of %s is %q, want %q", tag.key, filepath.Base(path), read[tag.key], want)
+		}
+	}
+	return nil
+}
+
 func buildFFmpegArgs(input, output string, meta Metadata, coverPath string) []string {
 	args := []string{"-y", "-i", input}
 	hasCover := strings.TrimSpace(coverPath) != ""
@@ -304,6 +676,7 @@ func appendMetadata(args []string, meta Metadata) []string {
 	add("date", meta.Year)
 	add("genre", meta.Genre)
 	add("track", meta.Track)
+	add("disc", meta.Disc)
 	add("comment", meta.Comment)
 	return args
 }
@@ -317,18 +690,24 @@ func isURL(value string) bool {
 }
 
 // getTrackMetadata determines the metadata for a specific file based on playlist metadata.
-// It tries to match by playlist index in the filename, falling back to position-based matching.
-func (d *Downloader) getTrackMetadata(pm *PlaylistMetadata, filename string, fileIndex int) Metadata {
-	// Try to extract playlist index from filename (format: "N - title.ext")
-	trackIndex := extractPlaylistIndex(filename)
+// It matches by the playlist index yt-dlp reported, then by the index in the
+// filename, falling back to position-based matching.
+func (d *Downloader) getTrackMetadata(pm *PlaylistMetadata, file DownloadedFile, fileIndex int) Metadata {
+	trackIndex := file.Index
+	if trackIndex <= 0 {
+		// Try to extract playlist index from filename (format: "N - title.ext")
+		trackIndex = extractPlaylistIndex(file.Path)
+	}
 	if trackIndex <= 0 {
 		// Fall back to file index (1-based)
 		trackIndex = fileIndex + 1
 	}
 
-	// Find matching track metadata
+	// Find matching track metadata, by position when the tracks have one
 	var trackMeta TrackMetadata
-	if trackIndex > 0 && trackIndex <= len(pm.Tracks) {
+	if track, ok := pm.track(trackIndex); ok {
+		trackMeta = track
+	} else if trackIndex > 0 && trackIndex <= len(pm.Tracks) {
 		trackMeta = pm.Tracks[trackIndex-1]
 	} else if len(pm.Tracks) > fileIndex {
 		trackMeta = pm.Tracks[fileIndex]
@@ -1,7 +1,9 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,7 +11,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
 )
 
 func TestIsURL(t *testing.T) {
@@ -51,6 +58,78 @@ func TestPrepareCoverWithURL(t *testing.T) {
 	}
 }
 
+func TestPrepareCoverUsesCache(t *testing.T) {
+	requests := 0
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requests++
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				return &http.Response{StatusCode: http.StatusNotModified, Body: http.NoBody, Header: http.Header{}}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("image-bytes")),
+				Header:     http.Header{"Etag": []string{`"v1"`}},
+			}, nil
+		}),
+	}
+
+	dir := t.TempDir()
+	c, err := cache.New(dir)
+	if err != nil {
+		t.Fatalf("cache.New failed: %v", err)
+	}
+	dl := New(nil, client)
+	dl.SetCache(c)
+
+	for i := 0; i < 2; i++ {
+		path, cleanup, err := dl.prepareCover(context.Background(), "https://example.com/cover.jpg")
+		if err != nil {
+			t.Fatalf("prepareCover returned error: %v", err)
+		}
+		data, _ := os.ReadFile(path)
+		cleanup()
+		if string(data) != "image-bytes" {
+			t.Fatalf("unexpected cover content: %s", string(data))
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the second cover to come from the cache, got %d requests", requests)
+	}
+
+	// An expired entry is revalidated and served on 304
+	expired, err := cache.New(dir, cache.WithTTL(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("cache.New failed: %v", err)
+	}
+	dl.SetCache(expired)
+	time.Sleep(time.Millisecond)
+	path, cleanup, err := dl.prepareCover(context.Background(), "https://example.com/cover.jpg")
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("prepareCover returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "image-bytes" {
+		t.Fatalf("unexpected revalidated cover content: %s", string(data))
+	}
+	if requests != 2 {
+		t.Errorf("expected one revalidation request, got %d requests", requests)
+	}
+
+	// Offline mode never touches the network
+	offline, err := cache.New(dir, cache.WithOffline(true))
+	if err != nil {
+		t.Fatalf("cache.New failed: %v", err)
+	}
+	dl.SetCache(offline)
+	if _, _, err := dl.prepareCover(context.Background(), "https://example.com/other.jpg"); !errors.Is(err, cache.ErrOffline) {
+		t.Errorf("expected ErrOffline for an uncached cover, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected no requests in offline mode, got %d", requests)
+	}
+}
+
 func TestBuildFFmpegArgsWithCoverAndMetadata(t *testing.T) {
 	meta := Metadata{
 		Title:       "Song",
@@ -99,11 +178,12 @@ func TestDownloadFlowCreatesAndTagsFiles(t *testing.T) {
 		},
 	}
 
-	files, err := dl.Download(context.Background(), cfg)
+	result, err := dl.Download(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
 
+	files := result.Files
 	if len(files) != 2 {
 		t.Fatalf("expected 2 files, got %d (%v)", len(files), files)
 	}
@@ -128,6 +208,61 @@ func TestDownloadFlowCreatesAndTagsFiles(t *testing.T) {
 	}
 }
 
+func TestDownloadTagsOnlyReportedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	// A file from an earlier run, and one the download overwrites
+	for _, name := range []string{"old.mp3", "track1.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner := &fakeRunner{audioFormat: "mp3"}
+	dl := New(runner, nil)
+
+	result, err := dl.Download(context.Background(), Config{
+		URL:         "https://example.com/playlist",
+		OutputDir:   tempDir,
+		AudioFormat: "mp3",
+		Metadata:    Metadata{Artist: "Tester"},
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	files := result.Files
+	if strings.Join(files, ",") != "track1.mp3,track2.mp3" {
+		t.Errorf("unexpected files: %v", files)
+	}
+	for _, call := range runner.calls {
+		if call.name == "ffmpeg" && strings.Contains(strings.Join(call.args, " "), "old.mp3") {
+			t.Errorf("expected the earlier file to be left alone, got %v", call.args)
+		}
+	}
+}
+
+func TestParseDownloadedFiles(t *testing.T) {
+	outDir := filepath.Join("music", "album")
+	list := printedFile("abc", 2, filepath.Join(outDir, "2 - Two.mp3")) +
+		printedFile("abc", 2, filepath.Join(outDir, "2 - Two.mp3")) +
+		printedFile("def", 3, filepath.Join(outDir, "sub", "3 - A | B.mp3")) +
+		printedFile("ghi", 0, filepath.Join(t.TempDir(), "elsewhere.mp3")) +
+		"jkl|4|NA\n"
+
+	files := parseDownloadedFiles(list, outDir)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %+v", files)
+	}
+	if files[0] != (DownloadedFile{Path: "2 - Two.mp3", ID: "abc", Index: 2}) {
+		t.Errorf("unexpected first file %+v", files[0])
+	}
+	if files[1].Path != filepath.Join("sub", "3 - A | B.mp3") || files[1].Index != 3 {
+		t.Errorf("unexpected second file %+v", files[1])
+	}
+	if !filepath.IsAbs(files[2].Path) {
+		t.Errorf("expected a file outside the output directory to keep its path, got %q", files[2].Path)
+	}
+}
+
 func TestDownloadRequiresURL(t *testing.T) {
 	dl := New(&fakeRunner{}, nil)
 	_, err := dl.Download(context.Background(), Config{OutputDir: t.TempDir()})
@@ -136,6 +271,93 @@ func TestDownloadRequiresURL(t *testing.T) {
 	}
 }
 
+func TestDownloadTrackSources(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &trackRunner{}
+	dl := New(runner, nil)
+
+	cfg := Config{
+		OutputDir:   tempDir,
+		AudioFormat: "mp3",
+		PlaylistMetadata: &PlaylistMetadata{
+			AlbumInfo: AlbumMetadata{Title: "Album", Artist: "Artist", TotalTracks: 3},
+			Tracks: []TrackMetadata{
+				{Position: 1, Title: "First"},
+				{Position: 2, Title: "Second"},
+				{Position: 3, Title: "Third"},
+			},
+		},
+		TrackSources: []TrackSource{
+			{Position: 1, URL: "https://www.youtube.com/watch?v=one"},
+			{Position: 2, URL: "https://www.youtube.com/watch?v=broken"},
+			{Position: 3, URL: "https://www.youtube.com/watch?v=three"},
+		},
+	}
+
+	result, err := dl.Download(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	// The broken track is skipped, the others keep their album positions
+	if len(result.Failed) != 1 || result.Failed[0].Index != 2 || !strings.HasSuffix(result.Failed[0].URL, "=broken") {
+		t.Errorf("expected track 2 to be reported as failed, got %+v", result.Failed)
+	}
+	files := result.Files
+	if len(files) != 2 || !strings.HasPrefix(files[0], "01 - ") || !strings.HasPrefix(files[1], "03 - ") {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	if !strings.Contains(runner.tagged["03 - three.mp3"], "title=Third") {
+		t.Errorf("expected track 3 to be tagged as Third, got %q", runner.tagged["03 - three.mp3"])
+	}
+	for _, call := range runner.calls {
+		if call.name == "yt-dlp" && !strings.Contains(strings.Join(call.args, " "), "--no-playlist") {
+			t.Errorf("expected single-video downloads, got %v", call.args)
+		}
+	}
+}
+
+// trackRunner fakes per-track yt-dlp downloads by filling in the output
+// template, and records the ffmpeg arguments per output file.
+type trackRunner struct {
+	calls  []cmdCall
+	tagged map[string]string
+}
+
+func (f *trackRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	f.calls = append(f.calls, cmdCall{name: name, args: append([]string{}, args...)})
+	if f.tagged == nil {
+		f.tagged = map[string]string{}
+	}
+
+	switch name {
+	case "yt-dlp":
+		url := args[len(args)-1]
+		id := url[strings.LastIndex(url, "=")+1:]
+		if id == "broken" {
+			return "", errors.New("video unavailable")
+		}
+		var template string
+		for i := 0; i < len(args)-1; i++ {
+			if args[i] == "-o" {
+				template = args[i+1]
+			}
+		}
+		path := strings.NewReplacer("%(title)s", id, "%(ext)s", "mp3").Replace(template)
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return "ok", reportFiles(args, printedFile(id, 0, path))
+	case "ffmpeg":
+		input := args[2]
+		f.tagged[filepath.Base(input)] = strings.Join(args, " ")
+		return "ok", writeTagged(args)
+	case "ffprobe":
+		return probeFile(args)
+	}
+	return "", fmt.Errorf("unexpected command: %s", name)
+}
+
 type fakeRunner struct {
 	audioFormat string
 	calls       []cmdCall
@@ -158,34 +380,93 @@ func (f *fakeRunner) Run(ctx context.Context, name string, args ...string) (stri
 		if err := os.MkdirAll(outDir, 0o755); err != nil {
 			return "", err
 		}
+		var output strings.Builder
 		for i := 1; i <= 2; i++ {
 			path := filepath.Join(outDir, fmt.Sprintf("track%d.%s", i, f.audioFormat))
 			if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
 				return "", err
 			}
+			output.WriteString(printedFile(fmt.Sprintf("vid%d", i), 0, path))
 		}
-		return "ok", nil
+		return "ok", reportFiles(args, output.String())
 	case "ffmpeg":
 		if len(args) == 0 {
 			return "", errors.New("ffmpeg missing args")
 		}
-		output := args[len(args)-1]
-		if err := os.WriteFile(output, []byte("tagged"), 0o644); err != nil {
+		if err := writeTagged(args); err != nil {
 			return "", err
 		}
 		return "ok", nil
+	case "ffprobe":
+		return probeFile(args)
 	default:
 		return "", fmt.Errorf("unexpected command: %s", name)
 	}
 }
 
+// printedFile is the line yt-dlp writes for a file through filePrintTemplate.
+func printedFile(id string, index int, path string) string {
+	return fmt.Sprintf("%s|%d|%s\n", id, index, path)
+}
+
+// reportFiles appends lines to the file list given with --print-to-file.
+func reportFiles(args []string, lines string) error {
+	for i := 0; i+2 < len(args); i++ {
+		if args[i] == "--print-to-file" {
+			f, err := os.OpenFile(args[i+2], os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.WriteString(lines)
+			return err
+		}
+	}
+	return errors.New("missing --print-to-file argument for yt-dlp")
+}
+
+// writeTagged does what ffmpeg does when it tags a file: it writes the
+// output, the last argument. The file holds "tagged" and then the -metadata
+// values, one per line, for probeFile to read back.
+func writeTagged(args []string) error {
+	content := "tagged"
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-metadata" {
+			content += "\n" + args[i+1]
+		}
+	}
+	return os.WriteFile(args[len(args)-1], []byte(content), 0o644)
+}
+
+// probeFile answers ffprobe as verifyFile runs it: every file is audio of
+// three minutes, with the tags writeTagged wrote into it.
+func probeFile(args []string) (string, error) {
+	data, err := os.ReadFile(args[len(args)-1])
+	if err != nil {
+		return "", err
+	}
+	var probe probeOutput
+	probe.Format.Duration = "180.000000"
+	probe.Format.Tags = map[string]string{}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			probe.Format.Tags[key] = value
+		}
+	}
+	output, err := json.Marshal(probe)
+	return string(output), err
+}
+
+// extractOutputDir returns the directory of yt-dlp's -o template. Without
+// one it returns a path under os.DevNull, so that a fake runner fails to
+// write there instead of writing into the package directory.
 func extractOutputDir(args []string) string {
 	for i := 0; i < len(args)-1; i++ {
 		if args[i] == "-o" {
 			return filepath.Dir(args[i+1])
 		}
 	}
-	return ""
+	return filepath.Join(os.DevNull, "missing -o")
 }
 
 type roundTripperFunc func(*http.Request) (*http.Response, error)
@@ -313,13 +594,13 @@ func TestDownloadWithPlaylistMetadata(t *testing.T) {
 		},
 	}
 
-	files, err := dl.Download(context.Background(), cfg)
+	result, err := dl.Download(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
 
-	if len(files) != 2 {
-		t.Fatalf("expected 2 files, got %d", len(files))
+	if len(result.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(result.Files))
 	}
 
 	// Verify ffmpeg was called for each file with metadata
@@ -340,6 +621,51 @@ func TestDownloadWithPlaylistMetadata(t *testing.T) {
 	}
 }
 
+func TestDownloadFlagsOverridePlaylistMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	coverPath := filepath.Join(tempDir, "cover.jpg")
+	if err := os.WriteFile(coverPath, []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner := &fakeRunnerWithIndex{audioFormat: "mp3"}
+	dl := New(runner, nil)
+
+	cfg := Config{
+		URL:         "https://example.com/playlist",
+		OutputDir:   filepath.Join(tempDir, "out"),
+		AudioFormat: "mp3",
+		Cover:       coverPath,
+		Metadata:    Metadata{Genre: "Indie Pop", Composer: "Reggie Youngblood"},
+		PlaylistMetadata: &PlaylistMetadata{
+			AlbumInfo: AlbumMetadata{
+				Title:    "Test Album",
+				Genre:    "Rock",
+				CoverURL: "https://example.invalid/cover.jpg",
+			},
+			Tracks: []TrackMetadata{
+				{Position: 1, Title: "First Track"},
+				{Position: 2, Title: "Second Track"},
+			},
+		},
+	}
+
+	if _, err := dl.Download(context.Background(), cfg); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	for _, call := range runner.calls {
+		if call.name != "ffmpeg" {
+			continue
+		}
+		argsStr := strings.Join(call.args, " ")
+		for _, want := range []string{"genre=Indie Pop", "composer=Reggie Youngblood", "album=Test Album", coverPath} {
+			if !strings.Contains(argsStr, want) {
+				t.Errorf("expected ffmpeg args to contain %q, got %s", want, argsStr)
+			}
+		}
+	}
+}
+
 // fakeRunnerWithIndex creates files with playlist index prefix
 type fakeRunnerWithIndex struct {
 	audioFormat string
@@ -359,22 +685,25 @@ func (f *fakeRunnerWithIndex) Run(ctx context.Context, name string, args ...stri
 			return "", err
 		}
 		// Create files with playlist index prefix
+		var output strings.Builder
 		for i := 1; i <= 2; i++ {
 			path := filepath.Join(outDir, fmt.Sprintf("%d - track%d.%s", i, i, f.audioFormat))
 			if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
 				return "", err
 			}
+			output.WriteString(printedFile(fmt.Sprintf("vid%d", i), i, path))
 		}
-		return "ok", nil
+		return "ok", reportFiles(args, output.String())
 	case "ffmpeg":
 		if len(args) == 0 {
 			return "", errors.New("ffmpeg missing args")
 		}
-		output := args[len(args)-1]
-		if err := os.WriteFile(output, []byte("tagged"), 0o644); err != nil {
+		if err := writeTagged(args); err != nil {
 			return "", err
 		}
 		return "ok", nil
+	case "ffprobe":
+		return probeFile(args)
 	default:
 		return "", fmt.Errorf("unexpected command: %s", name)
 	}
@@ -394,3 +723,177 @@ func TestBuildYtDlpArgsHighestQuality(t *testing.T) {
 		t.Errorf("expected playlist_index in output template, args: %s", argsStr)
 	}
 }
+
+func TestProbePlaylist(t *testing.T) {
+	runner := &probeRunner{
+		output: "WARNING: [youtube] some warning\n" +
+			"1\tvid1\t185\tFirst Song\n" +
+			"2\tvid2\t201.5\tSecond - Song\n" +
+			"3\tvid3\tNA\tPrivate video\n",
+	}
+	dl := New(runner, nil)
+
+	entries, err := dl.ProbePlaylist(context.Background(), "", "https://example.com/playlist")
+	if err != nil {
+		t.Fatalf("ProbePlaylist failed: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d (%v)", len(entries), entries)
+	}
+	if entries[1].Index != 2 || entries[1].ID != "vid2" || entries[1].Title != "Second - Song" {
+		t.Errorf("unexpected entry: %+v", entries[1])
+	}
+	if entries[1].Duration != 201500*time.Millisecond {
+		t.Errorf("expected 201.5s duration, got %s", entries[1].Duration)
+	}
+	if entries[2].Duration != 0 {
+		t.Errorf("expected unknown duration to be zero, got %s", entries[2].Duration)
+	}
+
+	if runner.name != "yt-dlp" || !strings.Contains(strings.Join(runner.args, " "), "--flat-playlist") {
+		t.Errorf("expected flat playlist yt-dlp call, got %s %v", runner.name, runner.args)
+	}
+}
+
+func TestTotalDuration(t *testing.T) {
+	entries := []PlaylistEntry{{Duration: time.Minute}, {Duration: 2 * time.Minute}}
+	if got := TotalDuration(entries); got != 3*time.Minute {
+		t.Errorf("expected 3m, got %s", got)
+	}
+
+	entries = append(entries, PlaylistEntry{})
+	if got := TotalDuration(entries); got != 0 {
+		t.Errorf("expected 0 when a duration is unknown, got %s", got)
+	}
+}
+
+// probeRunner returns canned yt-dlp output and records the call
+type probeRunner struct {
+	output string
+	name   string
+	args   []string
+}
+
+func (p *probeRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	p.name = name
+	p.args = append([]string{}, args...)
+	return p.output, nil
+}
+
+func TestDownloadTagsInParallel(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &parallelRunner{failing: "track3.mp3"}
+	dl := New(runner, nil)
+	dl.SetJobs(3)
+
+	result, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: tempDir,
+		Metadata:  Metadata{Album: "Album"},
+	})
+
+	// The failing file does not stop the others from being tagged
+	if err == nil || !strings.Contains(err.Error(), "track3.mp3") {
+		t.Fatalf("expected an error naming the failed file, got %v", err)
+	}
+	if len(result.Files) != 5 || len(result.Unfinished) != 1 || result.Unfinished[0] != "track3.mp3" {
+		t.Errorf("unexpected result: files %v, unfinished %v", result.Files, result.Unfinished)
+	}
+	if got := runner.tagged.Load(); got != 5 {
+		t.Errorf("expected 5 tagged files, got %d", got)
+	}
+	if peak := runner.peak.Load(); peak < 2 || peak > 3 {
+		t.Errorf("expected 2 to 3 ffmpeg processes at once, got %d", peak)
+	}
+}
+
+// parallelRunner downloads six files and tags them slowly, recording how
+// many ffmpeg processes run at once. Tagging the failing file fails.
+type parallelRunner struct {
+	failing   string
+	running   atomic.Int32
+	peak      atomic.Int32
+	tagged    atomic.Int32
+	fetching  atomic.Int32
+	fetchPeak atomic.Int32
+}
+
+// raise sets peak to n if n is higher.
+func raise(peak *atomic.Int32, n int32) {
+	for {
+		p := peak.Load()
+		if n <= p || peak.CompareAndSwap(p, n) {
+			return
+		}
+	}
+}
+
+func (f *parallelRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	switch name {
+	case "yt-dlp":
+		raise(&f.fetchPeak, f.fetching.Add(1))
+		defer f.fetching.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+		outDir := extractOutputDir(args)
+		var lines strings.Builder
+		for i := 1; i <= 6; i++ {
+			path := filepath.Join(outDir, fmt.Sprintf("track%d.mp3", i))
+			if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+				return "", err
+			}
+			lines.WriteString(printedFile(fmt.Sprintf("vid%d", i), i, path))
+		}
+		return "ok", reportFiles(args, lines.String())
+	case "ffmpeg":
+		raise(&f.peak, f.running.Add(1))
+		defer f.running.Add(-1)
+		time.Sleep(20 * time.Millisecond)
+		input := args[2]
+		if filepath.Base(input) == f.failing {
+			return "", errors.New("invalid data found when processing input")
+		}
+		f.tagged.Add(1)
+		return "ok", writeTagged(args)
+	case "ffprobe":
+		return probeFile(args)
+	}
+	return "", fmt.Errorf("unexpected command: %s", name)
+}
+
+func TestWithOutputSharesLimits(t *testing.T) {
+	runner := &parallelRunner{}
+	dl := New(runner, nil)
+	dl.SetJobs(2)
+	dl.SetDownloads(1)
+
+	var wg sync.WaitGroup
+	var bufs [3]bytes.Buffer
+	for i := range bufs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			album := dl.WithOutput(&bufs[i])
+			if _, err := album.Download(context.Background(), Config{
+				URL:       "https://www.youtube.com/playlist?list=PL1",
+				OutputDir: t.TempDir(),
+				Metadata:  Metadata{Album: "Album"},
+			}); err != nil {
+				t.Errorf("Download %d failed: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := runner.fetchPeak.Load(); peak != 1 {
+		t.Errorf("expected one yt-dlp download at a time, got %d", peak)
+	}
+	if peak := runner.peak.Load(); peak > 2 {
+		t.Errorf("expected at most 2 ffmpeg processes over all downloads, got %d", peak)
+	}
+	for i := range bufs {
+		if out := bufs[i].String(); !strings.Contains(out, "Successfully processed 6 file(s)") || strings.Contains(out, "\r") {
+			t.Errorf("expected the report of download %d in its buffer without animations, got %q", i, out)
+		}
+	}
+}
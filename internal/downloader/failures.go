@@ -0,0 +1,285 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FailureCategory groups the reasons yt-dlp gives for skipping an item.
+type FailureCategory string
+
+const (
+	FailurePrivate     FailureCategory = "private"
+	FailureRegion      FailureCategory = "region"
+	FailureAge         FailureCategory = "age"
+	FailureCopyright   FailureCategory = "copyright"
+	FailureUnavailable FailureCategory = "unavailable"
+	FailureNetwork     FailureCategory = "network"
+	FailureOther       FailureCategory = "other"
+)
+
+// FailedItem is a video that yt-dlp could not download.
+type FailedItem struct {
+	ID       string          `json:"id,omitempty"`  // Video ID
+	URL      string          `json:"url,omitempty"` // Video URL, for per-track downloads
+	Index    int             `json:"index"`         // Playlist index or track position (0 if unknown)
+	Category FailureCategory `json:"category"`
+	Reason   string          `json:"reason"` // yt-dlp's error message
+}
+
+// RetryURL returns the URL to download the item again from, or "" if the
+// item cannot be retried.
+func (f FailedItem) RetryURL() string {
+	if f.URL != "" {
+		return f.URL
+	}
+	if f.ID != "" {
+		return videoURL(f.ID)
+	}
+	return ""
+}
+
+var (
+	errorLine     = regexp.MustCompile(`^ERROR: (?:\[([\w:]+)\] ([\w-]+): )?(.*)$`)
+	extractorLine = regexp.MustCompile(`^\[([\w:]+)\] ([\w-]+): `)
+)
+
+// failureReasons maps phrases of yt-dlp's error messages to categories, most
+// specific first.
+var failureReasons = []struct {
+	category FailureCategory
+	phrases  []string
+}{
+	{FailurePrivate, []string{"private video", "video is private"}},
+	{FailureRegion, []string{"in your country", "geo restrict", "geo-restrict", "not available from your location"}},
+	{FailureAge, []string{"confirm your age", "age-restricted", "age restricted", "inappropriate for some users"}},
+	{FailureCopyright, []string{"copyright"}},
+	{FailureNetwork, []string{"http error", "timed out", "connection", "unable to download", "temporary failure", "network"}},
+	{FailureUnavailable, []string{"unavailable", "removed", "not available", "terminated", "does not exist"}},
+}
+
+// categorize assigns a yt-dlp error message to a category.
+func categorize(reason string) FailureCategory {
+	lower := strings.ToLower(reason)
+	for _, r := range failureReasons {
+		for _, phrase := range r.phrases {
+			if strings.Contains(lower, phrase) {
+				return r.category
+			}
+		}
+	}
+	return FailureOther
+}
+
+// parseFailures reads the items yt-dlp skipped from its output. The video
+// of an error line without an ID is the one last named by an extractor.
+// Errors that cannot be tied to a video are left out, as is every error
+// after the first for the same video. The items have no index: the
+// "Downloading item" lines count places in yt-dlp's download queue, which
+// are not playlist indexes once items are selected or skipped, so
+// indexFailures looks them up.
+func parseFailures(output string) []FailedItem {
+	var failures []FailedItem
+	seen := map[string]bool{}
+	var lastID string
+
+	for _, line := range strings.FieldsFunc(output, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if itemLine.MatchString(line) {
+			lastID = ""
+			continue
+		}
+		if m := errorLine.FindStringSubmatch(line); m != nil {
+			if isPlaylistExtractor(m[1]) {
+				// The playlist itself failed, which fails the whole download
+				continue
+			}
+			id := m[2]
+			if id == "" {
+				id = lastID
+			}
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			reason := strings.TrimSpace(m[3])
+			failures = append(failures, FailedItem{ID: id, Category: categorize(reason), Reason: reason})
+			continue
+		}
+		if m := extractorLine.FindStringSubmatch(line); m != nil && !isPlaylistExtractor(m[1]) {
+			lastID = m[2]
+		}
+	}
+	return failures
+}
+
+// indexFailures sets the playlist index of the failed items of the playlist
+// at url. yt-dlp prints nothing for a video it could not extract, so the
+// indexes are looked up by video ID in a flat listing of the playlist, which
+// prints %(playlist_index)s for every entry. Items the listing does not have
+// keep no index.
+func (d *Downloader) indexFailures(ctx context.Context, ytCmd, url string, failed []FailedItem) {
+	if len(failed) == 0 || ctx.Err() != nil {
+		return
+	}
+	entries, err := d.ProbePlaylist(ctx, ytCmd, url)
+	if err != nil {
+		d.progress.PrintWarning(fmt.Sprintf("Could not look up the playlist index of failed items: %v", err))
+		return
+	}
+	for i := range failed {
+		for _, e := range entries {
+			if e.ID == failed[i].ID {
+				failed[i].Index = e.Index
+				break
+			}
+		}
+	}
+}
+
+// isPlaylistExtractor reports whether a yt-dlp extractor name is one that
+// names playlists rather than videos, such as "youtube:tab".
+func isPlaylistExtractor(name string) bool {
+	return strings.HasSuffix(name, ":tab") || strings.Contains(name, "playlist")
+}
+
+// FailedAlbum is an album with items that failed to download, together with
+// what it takes to download and tag them into the album later.
+type FailedAlbum struct {
+	URL              string            `json:"url,omitempty"`
+	OutputDir        string            `json:"output_dir"`
+	AudioFormat      string            `json:"format"`
+	Cover            string            `json:"cover,omitempty"`
+	Metadata         Metadata          `json:"metadata"`
+	PlaylistMetadata *PlaylistMetadata `json:"playlist_metadata,omitempty"`
+	NameTemplate     string            `json:"name_template,omitempty"` // Template the album's files were named with
+	ASCIINames       bool              `json:"ascii_names,omitempty"`
+	Failed           []FailedItem      `json:"failed"`
+}
+
+// NewFailedAlbum records the failed items of a download made with cfg. The
+// output directory is stored as an absolute path so that a retry can be run
+// from anywhere.
+func NewFailedAlbum(cfg Config, failed []FailedItem) FailedAlbum {
+	outputDir := cfg.OutputDir
+	if abs, err := filepath.Abs(outputDir); err == nil {
+		outputDir = abs
+	}
+	fa := FailedAlbum{
+		URL:              cfg.URL,
+		OutputDir:        outputDir,
+		AudioFormat:      cfg.AudioFormat,
+		Cover:            cfg.Cover,
+		Metadata:         cfg.Metadata,
+		PlaylistMetadata: cfg.PlaylistMetadata,
+		Failed:           failed,
+	}
+	if cfg.Naming != nil && cfg.Naming.Template != nil {
+		fa.NameTemplate = cfg.Naming.Template.String()
+		fa.ASCIINames = cfg.Naming.ASCII
+	}
+	return fa
+}
+
+// RetryConfig returns a download of only the failed items, each numbered by
+// its index so that it is tagged as that track of the album and named like
+// the rest of it. Items that cannot be retried are left out.
+func (fa FailedAlbum) RetryConfig() Config {
+	cfg := Config{
+		OutputDir:        fa.OutputDir,
+		AudioFormat:      fa.AudioFormat,
+		Cover:            fa.Cover,
+		Metadata:         fa.Metadata,
+		PlaylistMetadata: fa.PlaylistMetadata,
+	}
+	// A template that no longer parses, e.g. after editing the file by hand,
+	// leaves yt-dlp's names
+	if tmpl, err := ParseNameTemplate(fa.NameTemplate); fa.NameTemplate != "" && err == nil {
+		cfg.Naming = &Naming{Template: tmpl, ASCII: fa.ASCIINames}
+	}
+	for _, item := range fa.Failed {
+		if url := item.RetryURL(); url != "" {
+			cfg.TrackSources = append(cfg.TrackSources, TrackSource{Position: item.Index, URL: url})
+		}
+	}
+	return cfg
+}
+
+// failureReport is the layout of a failure file.
+type failureReport struct {
+	Albums []FailedAlbum `json:"albums"`
+}
+
+// SaveFailures writes albums with failed items to a failure file, replacing
+// it.
+func SaveFailures(path string, albums []FailedAlbum) error {
+	data, err := json.MarshalIndent(failureReport{Albums: albums}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode failures: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write failures: %w", err)
+	}
+	return nil
+}
+
+// MergeFailures adds albums to the failure file at path, creating it if
+// needed, and returns every album now in it. What earlier runs recorded for
+// other albums is kept. An album with the same output directory as one in
+// the file takes its place, keeping the earlier items it did not fail again;
+// items are the same when they have the same video.
+func MergeFailures(path string, albums []FailedAlbum) ([]FailedAlbum, error) {
+	merged, err := LoadFailures(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, album := range albums {
+		i := slices.IndexFunc(merged, func(fa FailedAlbum) bool { return fa.OutputDir == album.OutputDir })
+		if i < 0 {
+			merged = append(merged, album)
+			continue
+		}
+		failed := slices.Clone(album.Failed)
+		for _, item := range merged[i].Failed {
+			if !slices.ContainsFunc(failed, func(f FailedItem) bool { return f.key() == item.key() }) {
+				failed = append(failed, item)
+			}
+		}
+		slices.SortStableFunc(failed, func(a, b FailedItem) int { return a.Index - b.Index })
+		album.Failed = failed
+		merged[i] = album
+	}
+	return merged, SaveFailures(path, merged)
+}
+
+// key identifies the video of an item: its ID, its URL, or else its index.
+func (f FailedItem) key() string {
+	switch {
+	case f.ID != "":
+		return f.ID
+	case f.URL != "":
+		return f.URL
+	}
+	return "#" + strconv.Itoa(f.Index)
+}
+
+// LoadFailures reads a failure file written by SaveFailures.
+func LoadFailures(path string) ([]FailedAlbum, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read failures: %w", err)
+	}
+	var report failureReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse failures %s: %w", path, err)
+	}
+	return report.Albums, nil
+}
@@ -0,0 +1,238 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const failedPlaylistOutput = `[youtube:tab] PLxyz: Downloading webpage
+[download] Downloading playlist: Partie Traumatic
+[download] Downloading item 1 of 3
+[youtube] aaaaaaaaaaa: Downloading webpage
+[download]  100% of    3.45MiB in 00:00:02 at 1.50MiB/s
+[download] Downloading item 2 of 3
+[youtube] bbbbbbbbbbb: Downloading webpage
+ERROR: [youtube] bbbbbbbbbbb: Private video. Sign in if you've been granted access to this video
+[download] Downloading item 3 of 3
+[youtube] ccccccccccc: Downloading webpage
+ERROR: unable to download video data: HTTP Error 403: Forbidden
+[download] Finished downloading playlist: Partie Traumatic
+`
+
+func TestParseFailures(t *testing.T) {
+	// Indexes are looked up in the playlist, not taken from the queue
+	failures := parseFailures(failedPlaylistOutput)
+	want := []FailedItem{
+		{ID: "bbbbbbbbbbb", Category: FailurePrivate, Reason: "Private video. Sign in if you've been granted access to this video"},
+		{ID: "ccccccccccc", Category: FailureNetwork, Reason: "unable to download video data: HTTP Error 403: Forbidden"},
+	}
+	if len(failures) != len(want) {
+		t.Fatalf("expected %d failures, got %+v", len(want), failures)
+	}
+	for i := range want {
+		if failures[i] != want[i] {
+			t.Errorf("failure %d: got %+v, want %+v", i+1, failures[i], want[i])
+		}
+	}
+
+	if got := parseFailures("ERROR: [youtube:tab] PLxyz: This playlist does not exist\n"); len(got) != 0 {
+		t.Errorf("expected a failed playlist not to count as a failed item, got %+v", got)
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	tests := map[string]FailureCategory{
+		"Video unavailable. The uploader has not made this video available in your country":                FailureRegion,
+		"Sign in to confirm your age. This video may be inappropriate for some users.":                     FailureAge,
+		"Video unavailable. This video contains content from SME, who has blocked it on copyright grounds": FailureCopyright,
+		"Video unavailable. This video has been removed by the uploader":                                   FailureUnavailable,
+		"Read timed out.":      FailureNetwork,
+		"Something else broke": FailureOther,
+	}
+	for reason, want := range tests {
+		if got := categorize(reason); got != want {
+			t.Errorf("categorize(%q) = %s, want %s", reason, got, want)
+		}
+	}
+}
+
+func TestDownloadKeepsPartialPlaylist(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &partialRunner{}
+	dl := New(runner, nil)
+
+	result, err := dl.Download(context.Background(), Config{
+		URL:         "https://example.com/playlist",
+		OutputDir:   tempDir,
+		AudioFormat: "mp3",
+		PlaylistMetadata: &PlaylistMetadata{
+			Tracks: []TrackMetadata{{Position: 1, Title: "One"}, {Position: 2, Title: "Two"}, {Position: 3, Title: "Three"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected the download to succeed with some items missing, got %v", err)
+	}
+	if len(result.Files) != 1 || len(result.Failed) != 2 {
+		t.Fatalf("expected 1 file and 2 failures, got %+v", result)
+	}
+	if result.Failed[0].Index != 2 || result.Failed[1].Index != 3 {
+		t.Errorf("expected the failures at playlist index 2 and 3, got %+v", result.Failed)
+	}
+	if runner.tagged != 1 {
+		t.Errorf("expected the downloaded file to be tagged, got %d ffmpeg calls", runner.tagged)
+	}
+}
+
+func TestDownloadIndexesFailuresOfSelection(t *testing.T) {
+	// With -items 3-5 yt-dlp counts the selected videos as items 1 to 3
+	runner := &partialRunner{first: 3}
+	dl := New(runner, nil)
+	items, _ := ParseItems("3-5")
+	cfg := Config{
+		URL:         "https://example.com/playlist",
+		OutputDir:   t.TempDir(),
+		AudioFormat: "mp3",
+		Items:       items,
+	}
+	result, err := dl.Download(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected the download to succeed with some items missing, got %v", err)
+	}
+	if len(result.Failed) != 2 || result.Failed[0].Index != 4 || result.Failed[1].Index != 5 {
+		t.Fatalf("expected the failures at playlist index 4 and 5, got %+v", result.Failed)
+	}
+
+	// A retry tags them as those tracks
+	retry := NewFailedAlbum(cfg, result.Failed).RetryConfig()
+	if len(retry.TrackSources) != 2 || retry.TrackSources[0].Position != 4 || retry.TrackSources[1].Position != 5 {
+		t.Errorf("expected a retry of tracks 4 and 5, got %+v", retry.TrackSources)
+	}
+}
+
+// partialRunner downloads the first item of a playlist and fails the rest,
+// exiting with an error as yt-dlp does with --ignore-errors. The playlist
+// lists the three videos at first, or else 1, 2 and 3.
+type partialRunner struct {
+	first  int
+	tagged int
+}
+
+func (p *partialRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	switch {
+	case name == "yt-dlp" && slices.Contains(args, "--flat-playlist"):
+		first := max(p.first, 1)
+		var listing strings.Builder
+		for i, id := range []string{"aaaaaaaaaaa", "bbbbbbbbbbb", "ccccccccccc"} {
+			fmt.Fprintf(&listing, "%d\t%s\t200\tTrack\n", first+i, id)
+		}
+		return listing.String(), nil
+	case name == "yt-dlp":
+		path := filepath.Join(extractOutputDir(args), "1 - One.mp3")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		if err := reportFiles(args, printedFile("aaaaaaaaaaa", max(p.first, 1), path)); err != nil {
+			return "", err
+		}
+		return failedPlaylistOutput, errors.New("yt-dlp failed: exit status 1")
+	case name == "ffmpeg":
+		p.tagged++
+		return "ok", writeTagged(args)
+	case name == "ffprobe":
+		return probeFile(args)
+	}
+	return "", errors.New("unexpected command: " + name)
+}
+
+func TestFailuresRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.json")
+	tmpl, err := ParseNameTemplate("{album}/{track:02} {title}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	album := NewFailedAlbum(Config{
+		URL:              "https://example.com/playlist",
+		OutputDir:        "music",
+		AudioFormat:      "mp3",
+		Metadata:         Metadata{Genre: "Indie Pop"},
+		PlaylistMetadata: &PlaylistMetadata{AlbumInfo: AlbumMetadata{Title: "Partie Traumatic"}},
+		Naming:           &Naming{Template: tmpl, ASCII: true},
+	}, []FailedItem{
+		{ID: "bbbbbbbbbbb", Index: 2, Category: FailurePrivate, Reason: "Private video"},
+		{URL: "https://www.youtube.com/watch?v=ccc", Index: 3, Category: FailureOther, Reason: "failed"},
+		{Index: 4, Category: FailureOther, Reason: "no way to retry"},
+	})
+	if !filepath.IsAbs(album.OutputDir) {
+		t.Errorf("expected an absolute output directory, got %q", album.OutputDir)
+	}
+
+	if err := SaveFailures(path, []FailedAlbum{album}); err != nil {
+		t.Fatalf("SaveFailures failed: %v", err)
+	}
+	albums, err := LoadFailures(path)
+	if err != nil {
+		t.Fatalf("LoadFailures failed: %v", err)
+	}
+	if len(albums) != 1 || albums[0].PlaylistMetadata.AlbumInfo.Title != "Partie Traumatic" || albums[0].Metadata.Genre != "Indie Pop" {
+		t.Fatalf("unexpected albums after round trip: %+v", albums)
+	}
+
+	cfg := albums[0].RetryConfig()
+	if cfg.URL != "" || cfg.OutputDir != album.OutputDir {
+		t.Errorf("expected a retry of single tracks into the album directory, got %+v", cfg)
+	}
+	if cfg.Naming == nil || cfg.Naming.Template.String() != "{album}/{track:02} {title}" || !cfg.Naming.ASCII {
+		t.Errorf("expected the retry to name files like the album, got %+v", cfg.Naming)
+	}
+	want := []TrackSource{
+		{Position: 2, URL: "https://www.youtube.com/watch?v=bbbbbbbbbbb"},
+		{Position: 3, URL: "https://www.youtube.com/watch?v=ccc"},
+	}
+	if len(cfg.TrackSources) != len(want) || cfg.TrackSources[0] != want[0] || cfg.TrackSources[1] != want[1] {
+		t.Errorf("got track sources %+v, want %+v", cfg.TrackSources, want)
+	}
+
+	if _, err := LoadFailures(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "read failures") {
+		t.Errorf("expected an error for a missing file, got %v", err)
+	}
+}
+
+func TestMergeFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.json")
+	first := FailedAlbum{OutputDir: "/music/First", Failed: []FailedItem{{ID: "aaa", Index: 3}, {ID: "bbb", Index: 5}}}
+	other := FailedAlbum{OutputDir: "/music/Other", Failed: []FailedItem{{ID: "ccc", Index: 1}}}
+	if err := SaveFailures(path, []FailedAlbum{first, other}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run fails track 5 again and track 2 for the first time
+	again := FailedAlbum{OutputDir: "/music/First", AudioFormat: "flac", Failed: []FailedItem{{ID: "bbb", Index: 5, Reason: "again"}, {ID: "ddd", Index: 2}}}
+	albums, err := MergeFailures(path, []FailedAlbum{again})
+	if err != nil {
+		t.Fatalf("MergeFailures failed: %v", err)
+	}
+	loaded, err := LoadFailures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(albums) != 2 || len(loaded) != 2 || loaded[1].OutputDir != "/music/Other" {
+		t.Fatalf("expected the other album to be kept, got %+v", loaded)
+	}
+	var keys []string
+	for _, item := range loaded[0].Failed {
+		keys = append(keys, item.ID+":"+item.Reason)
+	}
+	if loaded[0].AudioFormat != "flac" || strings.Join(keys, ",") != "ddd:,aaa:,bbb:again" {
+		t.Errorf("expected the earlier and new items of the album in track order, got %+v", loaded[0])
+	}
+
+	if _, err := MergeFailures(filepath.Join(t.TempDir(), "new.json"), []FailedAlbum{other}); err != nil {
+		t.Errorf("expected a missing file to be created, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package downloader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ItemRange is a span of playlist items or track numbers, both ends
+// included. Last is 0 for a span that runs to the end of the playlist.
+type ItemRange struct {
+	First int
+	Last  int
+}
+
+// Items selects the items of a playlist, or the tracks of an album, by
+// number. Playlist items keep their playlist index, so a selection does not
+// change how files are numbered and matched to tracks. A nil Items selects
+// everything; Download refuses an empty one.
+type Items []ItemRange
+
+// ParseItems parses a selection such as "1-5,8" or "10-": numbers and
+// ranges separated by commas. An empty spec selects everything.
+func ParseItems(spec string) (Items, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	items := Items{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		r := ItemRange{}
+		var err error
+		if r.First, err = strconv.Atoi(strings.TrimSpace(first)); err != nil || r.First < 1 {
+			return nil, fmt.Errorf("invalid item %q: use numbers from 1 and ranges like 3-7", part)
+		}
+		r.Last = r.First
+		if isRange {
+			r.Last = 0
+			if last = strings.TrimSpace(last); last != "" {
+				if r.Last, err = strconv.Atoi(last); err != nil || r.Last < r.First {
+					return nil, fmt.Errorf("invalid range %q: use numbers from 1 and ranges like 3-7", part)
+				}
+			}
+		}
+		items = append(items, r)
+	}
+	return items.normalize(), nil
+}
+
+// ItemsOf returns the selection of the given numbers.
+func ItemsOf(numbers []int) Items {
+	items := Items{}
+	for _, n := range numbers {
+		if n > 0 {
+			items = append(items, ItemRange{First: n, Last: n})
+		}
+	}
+	return items.normalize()
+}
+
+// Contains reports whether n is selected.
+func (s Items) Contains(n int) bool {
+	if s == nil {
+		return true
+	}
+	for _, r := range s {
+		if n >= r.First && n <= r.end() {
+			return true
+		}
+	}
+	return false
+}
+
+// Without returns the selection of s minus skip.
+func (s Items) Without(skip Items) Items {
+	if skip == nil {
+		return s
+	}
+	if s == nil {
+		s = Items{{First: 1}}
+	}
+	out := Items{}
+	for _, r := range s {
+		remaining := Items{r}
+		for _, k := range skip {
+			var next Items
+			for _, p := range remaining {
+				if k.end() < p.First || k.First > p.end() {
+					next = append(next, p)
+					continue
+				}
+				if p.First < k.First {
+					next = append(next, ItemRange{First: p.First, Last: k.First - 1})
+				}
+				if k.Last != 0 && (p.Last == 0 || p.Last > k.Last) {
+					next = append(next, ItemRange{First: k.Last + 1, Last: p.Last})
+				}
+			}
+			remaining = next
+		}
+		out = append(out, remaining...)
+	}
+	return out.normalize()
+}
+
+// String returns the selection in the form of yt-dlp's --playlist-items,
+// e.g. "1-5,8,10:".
+func (s Items) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		switch r.Last {
+		case r.First:
+			parts[i] = strconv.Itoa(r.First)
+		case 0:
+			parts[i] = fmt.Sprintf("%d:", r.First)
+		default:
+			parts[i] = fmt.Sprintf("%d-%d", r.First, r.Last)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// end returns the last number of r, with open ranges running to the largest
+// int.
+func (r ItemRange) end() int {
+	if r.Last == 0 {
+		return math.MaxInt
+	}
+	return r.Last
+}
+
+// normalize sorts the ranges and merges those that overlap or touch.
+func (s Items) normalize() Items {
+	sort.Slice(s, func(i, j int) bool { return s[i].First < s[j].First })
+	out := Items{}
+	for _, r := range s {
+		n := len(out)
+		switch {
+		case n > 0 && out[n-1].Last == 0:
+			// Already runs to the end
+		case n > 0 && r.First <= out[n-1].Last+1:
+			if r.Last == 0 || r.Last > out[n-1].Last {
+				out[n-1].Last = r.Last
+			}
+		default:
+			out = append(out, r)
+		}
+	}
+	return out
+}
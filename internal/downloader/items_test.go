@@ -0,0 +1,115 @@
+package downloader
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseItems(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"1-5,8", "1-5,8"},
+		{" 8, 1-3 ,2-4", "1-4,8"},
+		{"10-", "10:"},
+		{"3,4,5,9-,12", "3-5,9:"},
+	}
+	for _, tt := range tests {
+		items, err := ParseItems(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseItems(%q) failed: %v", tt.spec, err)
+		}
+		if got := items.String(); got != tt.want {
+			t.Errorf("ParseItems(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+
+	if items, err := ParseItems(""); err != nil || items != nil || !items.Contains(40) {
+		t.Errorf("expected an empty spec to select everything, got %v, %v", items, err)
+	}
+	for _, invalid := range []string{"0", "a-3", "5-2", "1,,2", "-3"} {
+		if _, err := ParseItems(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestItemsWithout(t *testing.T) {
+	skip, _ := ParseItems("1,4-5,20-")
+	if got := Items(nil).Without(skip).String(); got != "2-3,6-19" {
+		t.Errorf("expected the skipped items to be left out of all, got %q", got)
+	}
+	items, _ := ParseItems("3-8")
+	if got := items.Without(skip).String(); got != "3,6-8" {
+		t.Errorf("expected 3,6-8, got %q", got)
+	}
+	if got := ItemsOf([]int{7, 2, 3, 0}).String(); got != "2-3,7" {
+		t.Errorf("expected 2-3,7, got %q", got)
+	}
+	if got := items.Without(items); got == nil || len(got) != 0 || got.Contains(3) {
+		t.Errorf("expected an empty selection, got %v", got)
+	}
+}
+
+func TestDownloadSelectsItems(t *testing.T) {
+	runner := &fakeRunner{audioFormat: "mp3"}
+	dl := New(runner, nil)
+	items, _ := ParseItems("2-3")
+
+	if _, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: t.TempDir(),
+		Items:     items,
+	}); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	args := strings.Join(runner.calls[0].args, " ")
+	if !strings.Contains(args, "--playlist-items 2-3 ") {
+		t.Errorf("expected the selection to be passed to yt-dlp, got %v", args)
+	}
+
+	// Per-track downloads leave out the tracks that are not selected
+	tracks := &trackRunner{}
+	dl = New(tracks, nil)
+	result, err := dl.Download(context.Background(), Config{
+		OutputDir: t.TempDir(),
+		PlaylistMetadata: &PlaylistMetadata{
+			AlbumInfo: AlbumMetadata{Title: "Album", TotalTracks: 3},
+			Tracks:    []TrackMetadata{{Position: 1, Title: "First"}, {Position: 2, Title: "Second"}, {Position: 3, Title: "Third"}},
+		},
+		TrackSources: []TrackSource{
+			{Position: 1, URL: "https://www.youtube.com/watch?v=one"},
+			{Position: 2, URL: "https://www.youtube.com/watch?v=two"},
+			{Position: 3, URL: "https://www.youtube.com/watch?v=three"},
+		},
+		Items: Items{{First: 2, Last: 2}},
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0] != "02 - two.mp3" {
+		t.Fatalf("expected only track 2, got %v", result.Files)
+	}
+	if tags := tracks.tagged["02 - two.mp3"]; !strings.Contains(tags, "track=2/3") {
+		t.Errorf("expected track 2 to keep its number and total, got %q", tags)
+	}
+}
+
+func TestDownloadRefusesEmptySelection(t *testing.T) {
+	runner := &fakeRunner{audioFormat: "mp3"}
+	dl := New(runner, nil)
+
+	_, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: t.TempDir(),
+		Items:     Items{},
+	})
+	if err == nil {
+		t.Fatal("expected an empty selection to fail")
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("expected yt-dlp not to run, got %v", runner.calls)
+	}
+}
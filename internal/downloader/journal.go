@@ -0,0 +1,283 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStage is how far a file got in a download.
+type FileStage string
+
+const (
+	StageDownloaded FileStage = "downloaded" // yt-dlp wrote the file
+	StageTagged     FileStage = "tagged"     // Metadata and cover were embedded
+	StageVerified   FileStage = "verified"   // ffprobe read the file and its tags back, and it was moved into place
+)
+
+// stageOrder ranks the stages so that a file never moves backwards.
+var stageOrder = map[FileStage]int{StageDownloaded: 1, StageTagged: 2, StageVerified: 3}
+
+// journalSaveInterval is the least time between two writes of the journal
+// for the progress of files. Writing the whole journal after every step of
+// every file would cost more the longer the batch; what a crash loses is
+// at most this much progress, which a resumed run redoes.
+var journalSaveInterval = 2 * time.Second
+
+// JournalFile is a file of an album and the last stage it reached.
+type JournalFile struct {
+	Path  string    `json:"path"` // Relative to the output directory
+	ID    string    `json:"id,omitempty"`
+	Index int       `json:"index,omitempty"`
+	Stage FileStage `json:"stage"`
+}
+
+// AlbumJournal records the progress of one album so that an interrupted run
+// can resume it. A nil AlbumJournal records nothing.
+type AlbumJournal struct {
+	Done  bool          `json:"done"`
+	Files []JournalFile `json:"files,omitempty"`
+
+	journal *Journal
+}
+
+// Journal records the progress of every album of a batch run. Progress of
+// files is written every journalSaveInterval and at the end of each album's
+// download; finished albums are written right away.
+type Journal struct {
+	Albums map[string]*AlbumJournal `json:"albums"`
+
+	path  string
+	mu    sync.Mutex
+	dirty bool      // Changes not written yet
+	saved time.Time // Last write
+}
+
+// NewJournal returns an empty journal that is written to path.
+func NewJournal(path string) *Journal {
+	return &Journal{Albums: map[string]*AlbumJournal{}, path: path}
+}
+
+// LoadJournal reads the journal at path. A missing journal is an empty one.
+func LoadJournal(path string) (*Journal, error) {
+	j := NewJournal(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("parse journal %s: %w", path, err)
+	}
+	if j.Albums == nil {
+		j.Albums = map[string]*AlbumJournal{}
+	}
+	for _, album := range j.Albums {
+		album.journal = j
+	}
+	return j, nil
+}
+
+// Path returns where the journal is written.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Album returns the journal of the album with key, creating it if needed.
+// A nil Journal returns nil.
+func (j *Journal) Album(key string) *AlbumJournal {
+	return j.Find(key)
+}
+
+// Find returns the journal of the album with the first of keys the journal
+// has, for an album whose key may have changed since the earlier run. With
+// none of them, a new one is created under the first key. A nil Journal
+// returns nil.
+func (j *Journal) Find(keys ...string) *AlbumJournal {
+	if j == nil || len(keys) == 0 {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, key := range keys {
+		if album, ok := j.Albums[key]; ok {
+			return album
+		}
+	}
+	album := &AlbumJournal{journal: j}
+	j.Albums[keys[0]] = album
+	return album
+}
+
+// Remove deletes the journal file.
+func (j *Journal) Remove() error {
+	err := os.Remove(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// save writes the journal; the caller holds j.mu.
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode journal: %w", err)
+	}
+	// Written to a temporary file first so that a crash leaves the old one
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	j.dirty = false
+	j.saved = time.Now()
+	return nil
+}
+
+// changed notes a change and writes the journal if the last write is
+// journalSaveInterval old; the caller holds j.mu.
+func (j *Journal) changed() error {
+	j.dirty = true
+	if time.Since(j.saved) < journalSaveInterval {
+		return nil
+	}
+	return j.save()
+}
+
+// flush writes the changes that are not written yet.
+func (a *AlbumJournal) flush() error {
+	if a == nil {
+		return nil
+	}
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+	if !a.journal.dirty {
+		return nil
+	}
+	return a.journal.save()
+}
+
+// Finish marks the album as done, so that a resumed run skips it.
+func (a *AlbumJournal) Finish() error {
+	if a == nil {
+		return nil
+	}
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+	a.Done = true
+	return a.journal.save()
+}
+
+// record notes that a file reached stage.
+func (a *AlbumJournal) record(file DownloadedFile, stage FileStage) error {
+	if a == nil {
+		return nil
+	}
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+	for i := range a.Files {
+		if a.Files[i].Path == file.Path {
+			if stageOrder[stage] > stageOrder[a.Files[i].Stage] {
+				a.Files[i].Stage = stage
+			}
+			return a.journal.changed()
+		}
+	}
+	a.Files = append(a.Files, JournalFile{Path: file.Path, ID: file.ID, Index: file.Index, Stage: stage})
+	return a.journal.changed()
+}
+
+// rename notes that a file was moved to path, relative to the output
+// directory.
+func (a *AlbumJournal) rename(from, to string) error {
+	if a == nil || from == to {
+		return nil
+	}
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+	for i := range a.Files {
+		if a.Files[i].Path == from {
+			a.Files[i].Path = to
+			return a.journal.changed()
+		}
+	}
+	return nil
+}
+
+// resumable returns the files an earlier run got that are still usable, in
+// the order they were recorded. Unfinished files are looked for in the
+// staging directory first. Files that went missing or are empty are left
+// out, so they are downloaded again.
+func (a *AlbumJournal) resumable(outputDir string) []JournalFile {
+	if a == nil {
+		return nil
+	}
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+	var files []JournalFile
+	for _, f := range a.Files {
+		paths := []string{filepath.Join(outputDir, f.Path)}
+		if f.Stage != StageVerified {
+			paths = append([]string{filepath.Join(outputDir, StagingDir, f.Path)}, paths...)
+		}
+		for _, path := range paths {
+			// A leftover of tagging that was cut short
+			_ = os.Remove(path + ".tagged")
+			if checkFile(path) == nil {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// checkFile checks that a file is in place and not empty. It is how files
+// that reached StageVerified are looked for again; verifyFile reads a file
+// back before it gets there.
+func checkFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 {
+		return fmt.Errorf("%s is empty or not a file", path)
+	}
+	return nil
+}
+
+// writeDownloadArchive writes the videos of files in the format of yt-dlp's
+// --download-archive, so that yt-dlp skips them. The entries name the
+// youtube extractor; videos of other sites are downloaded again.
+func writeDownloadArchive(files []JournalFile) (string, func(), error) {
+	var lines strings.Builder
+	for _, f := range files {
+		if f.ID != "" {
+			fmt.Fprintf(&lines, "youtube %s\n", f.ID)
+		}
+	}
+	tmp, err := os.CreateTemp("", "iturtle-archive-*.txt")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("create download archive: %w", err)
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+	_, err = tmp.WriteString(lines.String())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("write download archive: %w", err)
+	}
+	return tmp.Name(), cleanup, nil
+}
@@ -0,0 +1,215 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadResumesFromJournal(t *testing.T) {
+	tempDir := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "albums.journal.json")
+
+	// An earlier run finished the first file and stopped before tagging the
+	// second, which is still staged; the third was lost
+	if err := os.MkdirAll(filepath.Join(tempDir, StagingDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"1 - one.mp3", filepath.Join(StagingDir, "2 - two.mp3")} {
+		if err := os.WriteFile(filepath.Join(tempDir, path), []byte("audio"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	journal := NewJournal(journalPath)
+	album := journal.Album("1:" + tempDir)
+	for _, step := range []struct {
+		file  DownloadedFile
+		stage FileStage
+	}{
+		{DownloadedFile{Path: "1 - one.mp3", ID: "one", Index: 1}, StageVerified},
+		{DownloadedFile{Path: "2 - two.mp3", ID: "two", Index: 2}, StageDownloaded},
+		{DownloadedFile{Path: "3 - three.mp3", ID: "three", Index: 3}, StageDownloaded},
+	} {
+		if err := album.record(step.file, step.stage); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+	if err := album.flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	journal, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("LoadJournal failed: %v", err)
+	}
+	runner := &resumeRunner{files: map[string]string{"three": "3 - three.mp3", "four": "4 - four.mp3"}}
+	dl := New(runner, nil)
+	result, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: tempDir,
+		Metadata:  Metadata{Album: "Album"},
+		Journal:   journal.Album("1:" + tempDir),
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	if got := strings.Fields(runner.archive); len(got) != 4 || got[1] != "one" || got[3] != "two" {
+		t.Errorf("expected yt-dlp to skip the files still on disk, got archive %q", runner.archive)
+	}
+	if strings.Join(runner.tagged, ",") != "2 - two.mp3,3 - three.mp3,4 - four.mp3" {
+		t.Errorf("expected the unfinished and new files to be tagged, got %v", runner.tagged)
+	}
+	if len(result.Files) != 3 {
+		t.Errorf("expected 3 files, got %v", result.Files)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "2 - two.mp3")); err != nil {
+		t.Errorf("expected the staged file to be moved into place: %v", err)
+	}
+
+	journal, err = LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("LoadJournal failed: %v", err)
+	}
+	files := journal.Album("1:" + tempDir).Files
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files in the journal, got %+v", files)
+	}
+	for _, f := range files {
+		if f.Stage != StageVerified {
+			t.Errorf("expected %s to be placed, got %s", f.Path, f.Stage)
+		}
+	}
+}
+
+func TestJournalBatchesWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "albums.journal.json")
+	album := NewJournal(path).Album("1:music")
+	stages := func() string {
+		journal, err := LoadJournal(path)
+		if err != nil {
+			t.Fatalf("LoadJournal failed: %v", err)
+		}
+		var out []string
+		for _, f := range journal.Album("1:music").Files {
+			out = append(out, string(f.Stage))
+		}
+		return strings.Join(out, ",")
+	}
+
+	// The first change is written, the ones right after it wait
+	file := DownloadedFile{Path: "1 - one.mp3", ID: "one", Index: 1}
+	for _, stage := range []FileStage{StageDownloaded, StageTagged, StageVerified} {
+		if err := album.record(file, stage); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+	if got := stages(); got != "downloaded" {
+		t.Errorf("expected only the first change on disk, got %q", got)
+	}
+	if err := album.flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if got := stages(); got != "verified" {
+		t.Errorf("expected the flushed stage on disk, got %q", got)
+	}
+
+	// Past the interval a change is written right away
+	defer func(interval time.Duration) { journalSaveInterval = interval }(journalSaveInterval)
+	journalSaveInterval = 0
+	if err := album.record(DownloadedFile{Path: "2 - two.mp3", ID: "two", Index: 2}, StageDownloaded); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	if got := stages(); got != "verified,downloaded" {
+		t.Errorf("expected the second file on disk, got %q", got)
+	}
+}
+
+func TestJournalFinish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "albums.journal.json")
+	if err := NewJournal(path).Album("1:music").Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	journal, err := LoadJournal(path)
+	if err != nil {
+		t.Fatalf("LoadJournal failed: %v", err)
+	}
+	if !journal.Album("1:music").Done || journal.Album("2:music").Done {
+		t.Errorf("expected only the first album to be done: %+v", journal.Albums)
+	}
+
+	if err := journal.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed, got %v", err)
+	}
+	var none *Journal
+	if none.Album("1:music").Finish() != nil {
+		t.Errorf("expected a nil journal to record nothing")
+	}
+}
+
+func TestJournalFind(t *testing.T) {
+	journal := NewJournal(filepath.Join(t.TempDir(), "albums.journal.json"))
+	if err := journal.Album("music|url=a").Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !journal.Find("music|url=a|id=b", "music|url=a").Done {
+		t.Error("expected the album under its earlier key")
+	}
+	if journal.Find("music|url=c", "music|url=d").Done {
+		t.Error("expected a new album for unknown keys")
+	}
+	if _, ok := journal.Albums["music|url=c"]; !ok || len(journal.Albums) != 2 {
+		t.Errorf("expected the new album under the first key, got %v", journal.Albums)
+	}
+}
+
+// resumeRunner downloads the files of a playlist that are not in the
+// download archive, and records the archive and the tagged files.
+type resumeRunner struct {
+	files   map[string]string // Video ID → file name
+	archive string
+	tagged  []string
+}
+
+func (f *resumeRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	switch name {
+	case "yt-dlp":
+		var skip []byte
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == "--download-archive" {
+				var err error
+				if skip, err = os.ReadFile(args[i+1]); err != nil {
+					return "", err
+				}
+			}
+		}
+		f.archive = string(skip)
+		outDir := extractOutputDir(args)
+		var lines strings.Builder
+		for _, id := range []string{"three", "four"} {
+			if strings.Contains(f.archive, " "+id+"\n") {
+				continue
+			}
+			path := filepath.Join(outDir, f.files[id])
+			if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+				return "", err
+			}
+			lines.WriteString(printedFile(id, 0, path))
+		}
+		return "ok", reportFiles(args, lines.String())
+	case "ffmpeg":
+		f.tagged = append(f.tagged, filepath.Base(args[2]))
+		return "ok", writeTagged(args)
+	case "ffprobe":
+		return probeFile(args)
+	}
+	return "", nil
+}
@@ -12,6 +12,7 @@ type Metadata struct {
 	Year        string
 	Genre       string
 	Track       string
+	Disc        string
 	Comment     string
 }
 
@@ -58,8 +59,29 @@ type Config struct {
 	AudioFormat      string
 	YtDLPPath        string
 	FFmpegPath       string
-	Metadata         Metadata
+	FFprobePath      string            // Reads tagged files back; "ffprobe" if empty
+	Metadata         Metadata          // Tags for every file; set fields override PlaylistMetadata
 	PlaylistMetadata *PlaylistMetadata // Optional per-track metadata for playlists
+	TrackSources     []TrackSource     // Optional per-track videos, downloaded instead of URL
+	Items            Items             // Playlist items or track numbers to download; nil for all
+	Naming           *Naming           // Optional renaming of files from their tags, after tagging
+	Journal          *AlbumJournal     // Optional record of progress, to resume an interrupted download
+}
+
+// track returns the track at a position, if one has it.
+func (pm *PlaylistMetadata) track(position int) (TrackMetadata, bool) {
+	for _, t := range pm.Tracks {
+		if t.Position > 0 && t.Position == position {
+			return t, true
+		}
+	}
+	return TrackMetadata{}, false
+}
+
+// TrackSource is the video to download for one track of an album.
+type TrackSource struct {
+	Position int    // 1-based track position, matching PlaylistMetadata.Tracks
+	URL      string // Video URL
 }
 
 // MergeTrackMetadata creates a Metadata struct by merging album-level and track-level data.
@@ -109,6 +131,32 @@ func MergeTrackMetadata(album AlbumMetadata, track TrackMetadata, position int)
 		meta.Track = formatTrackNumber(trackNum, 0)
 	}
 
+	// Disc number, only for releases that say which disc a track is on
+	if track.DiscNumber > 0 {
+		meta.Disc = formatTrackNumber(track.DiscNumber, track.TotalDiscs)
+	}
+
+	return meta
+}
+
+// OverrideMetadata returns meta with every field that is set in top taking
+// its place.
+func OverrideMetadata(meta, top Metadata) Metadata {
+	set := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	set(&meta.Title, top.Title)
+	set(&meta.Artist, top.Artist)
+	set(&meta.Album, top.Album)
+	set(&meta.AlbumArtist, top.AlbumArtist)
+	set(&meta.Composer, top.Composer)
+	set(&meta.Year, top.Year)
+	set(&meta.Genre, top.Genre)
+	set(&meta.Track, top.Track)
+	set(&meta.Disc, top.Disc)
+	set(&meta.Comment, top.Comment)
 	return meta
 }
 
@@ -0,0 +1,347 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxNameBytes limits each directory and file name a template produces. It
+// leaves room within the common limit of 255 bytes for the extension and a
+// collision suffix.
+const maxNameBytes = 200
+
+// Naming renames files after tagging, from their tags.
+type Naming struct {
+	Template  *NameTemplate
+	ASCII     bool // Transliterate names to ASCII, e.g. "Sigur Rós" to "Sigur Ros"
+	Overwrite bool // Replace existing files instead of numbering the new ones
+}
+
+// NameTemplate is a path such as "{albumartist}/{album} ({year})/{track:02}
+// {title}", relative to the output directory and without extension. Fields
+// are written in braces; a width after a colon pads numbers with zeros.
+// "{{" and "}}" stand for literal braces.
+type NameTemplate struct {
+	source   string
+	segments [][]namePart // One per directory level, the file name last
+}
+
+// namePart is literal text, or a field when field is set.
+type namePart struct {
+	text  string
+	field string
+	width int
+}
+
+// nameFields are the fields a template can use.
+var nameFields = map[string]bool{
+	"title": true, "artist": true, "album": true, "albumartist": true, "year": true,
+	"genre": true, "composer": true, "track": true, "disc": true, "id": true,
+}
+
+// ParseNameTemplate parses a template. Paths that are absolute or leave the
+// output directory are rejected.
+func ParseNameTemplate(template string) (*NameTemplate, error) {
+	template = strings.TrimSpace(template)
+	if template == "" {
+		return nil, errors.New("template is empty")
+	}
+	t := &NameTemplate{source: template}
+	for _, segment := range strings.Split(filepath.ToSlash(template), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid template %q: every level needs a name and must stay inside the output directory", template)
+		}
+		parts, err := parseNameSegment(segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", template, err)
+		}
+		t.segments = append(t.segments, parts)
+	}
+	return t, nil
+}
+
+// parseNameSegment splits one level of a template into text and fields.
+func parseNameSegment(segment string) ([]namePart, error) {
+	var parts []namePart
+	var text strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(segment) && segment[i+1] == c:
+			text.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(segment[i:], '}')
+			if end < 0 {
+				return nil, errors.New("unclosed {")
+			}
+			name, format, _ := strings.Cut(segment[i+1:i+end], ":")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !nameFields[name] {
+				return nil, fmt.Errorf("unknown field {%s}", name)
+			}
+			part := namePart{field: name}
+			if format != "" {
+				width, err := strconv.Atoi(format)
+				if err != nil || width < 1 || width > 9 {
+					return nil, fmt.Errorf("invalid width in {%s:%s}", name, format)
+				}
+				part.width = width
+			}
+			if text.Len() > 0 {
+				parts = append(parts, namePart{text: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, part)
+			i += end
+		case c == '}':
+			return nil, errors.New("unmatched }")
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if text.Len() > 0 {
+		parts = append(parts, namePart{text: text.String()})
+	}
+	return parts, nil
+}
+
+// String returns the template as it was written.
+func (t *NameTemplate) String() string {
+	return t.source
+}
+
+// Render returns the path of a file with the given tags, relative to the
+// output directory and with ext appended. Every level is made safe to use
+// as a file name.
+func (t *NameTemplate) Render(file DownloadedFile, ext string, ascii bool) string {
+	values := nameValues(file)
+	levels := make([]string, len(t.segments))
+	for i, parts := range t.segments {
+		var name strings.Builder
+		for _, part := range parts {
+			if part.field == "" {
+				name.WriteString(part.text)
+				continue
+			}
+			value := values[part.field]
+			if n, err := strconv.Atoi(value); err == nil && part.width > 0 {
+				value = fmt.Sprintf("%0*d", part.width, n)
+			}
+			// A value is never a path of its own
+			name.WriteString(strings.NewReplacer("/", "_", "\\", "_").Replace(value))
+		}
+		levels[i] = SanitizeName(tidyName(name.String()), ascii)
+	}
+	return filepath.Join(levels...) + ext
+}
+
+// nameValues returns the values of the fields for a file. Names that a
+// path level cannot do without fall back to placeholders; numbers are those
+// before a total, e.g. 3 for "3/12".
+func nameValues(file DownloadedFile) map[string]string {
+	tags := file.Tags
+	number := func(s string) string {
+		n, _, _ := strings.Cut(strings.TrimSpace(s), "/")
+		return n
+	}
+	or := func(value, fallback string) string {
+		if strings.TrimSpace(value) == "" {
+			return fallback
+		}
+		return value
+	}
+	title := strings.TrimSuffix(filepath.Base(file.Path), filepath.Ext(file.Path))
+	artist := or(tags.Artist, "Unknown Artist")
+	track := number(tags.Track)
+	if track == "" && file.Index > 0 {
+		track = strconv.Itoa(file.Index)
+	}
+	return map[string]string{
+		"title":       or(tags.Title, title),
+		"artist":      artist,
+		"album":       or(tags.Album, "Unknown Album"),
+		"albumartist": or(tags.AlbumArtist, artist),
+		"year":        tags.Year,
+		"genre":       tags.Genre,
+		"composer":    tags.Composer,
+		"track":       track,
+		"disc":        or(number(tags.Disc), "1"),
+		"id":          file.ID,
+	}
+}
+
+// tidyName removes what empty fields leave behind: empty brackets, doubled
+// spaces and separators at either end, as in "Album ()" for a missing year.
+func tidyName(name string) string {
+	for _, empty := range []string{"()", "[]", "{}"} {
+		name = strings.ReplaceAll(name, empty, "")
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.Trim(name, " -_.,")
+}
+
+// windowsReserved are names Windows does not allow for files, with any
+// extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeName makes name safe as a file or directory name on Linux, macOS
+// and Windows: reserved and control characters become "_", trailing dots
+// and spaces go, reserved device names get a "_" and the name is cut to
+// maxNameBytes. With ascii, letters are transliterated to ASCII first.
+func SanitizeName(name string, ascii bool) string {
+	if ascii {
+		name = transliterate(name)
+	}
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case strings.ContainsRune(`<>:"/\|?*`, r), unicode.IsControl(r), r == utf8.RuneError:
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name = b.String()
+
+	if len(name) > maxNameBytes {
+		cut := maxNameBytes
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" {
+		return "_"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+	return name
+}
+
+// transliterations spell letters that do not decompose to ASCII.
+var transliterations = map[rune]string{
+	'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'ß': "ss", 'Ø': "O", 'ø': "o",
+	'Đ': "D", 'đ': "d", 'Ð': "D", 'ð': "d", 'Þ': "Th", 'þ': "th", 'Ł': "L", 'ł': "l",
+	'ı': "i", 'Ħ': "H", 'ħ': "h", 'ŀ': "l", 'Ŀ': "L",
+	'‘': "'", '’': "'", '‚': "'", '“': `"`, '”': `"`, '„': `"`,
+	'–': "-", '—': "-", '…': "...", '×': "x", '¡': "!", '¿': "?",
+}
+
+// accents maps accented Latin letters to their base letter. Each string
+// lists the accented forms of the letter before it.
+var accents = map[byte]string{
+	'A': "ÀÁÂÃÄÅĀĂĄǍ", 'a': "àáâãäåāăąǎª",
+	'C': "ÇĆĈĊČ", 'c': "çćĉċč",
+	'D': "Ď", 'd': "ď",
+	'E': "ÈÉÊËĒĔĖĘĚ", 'e': "èéêëēĕėęě",
+	'G': "ĜĞĠĢ", 'g': "ĝğġģ",
+	'H': "Ĥ", 'h': "ĥ",
+	'I': "ÌÍÎÏĨĪĬĮİǏ", 'i': "ìíîïĩīĭįǐ",
+	'J': "Ĵ", 'j': "ĵ",
+	'K': "Ķ", 'k': "ķ",
+	'L': "ĹĻĽ", 'l': "ĺļľ",
+	'N': "ÑŃŅŇ", 'n': "ñńņň",
+	'O': "ÒÓÔÕÖŌŎŐǑ", 'o': "òóôõöōŏőǒº",
+	'R': "ŔŖŘ", 'r': "ŕŗř",
+	'S': "ŚŜŞŠȘ", 's': "śŝşšș",
+	'T': "ŢŤȚ", 't': "ţťț",
+	'U': "ÙÚÛÜŨŪŬŮŰŲǓ", 'u': "ùúûüũūŭůűųǔ",
+	'W': "Ŵ", 'w': "ŵ",
+	'Y': "ÝŶŸ", 'y': "ýÿŷ",
+	'Z': "ŹŻŽ", 'z': "źżž",
+}
+
+// asciiLetters is accents the other way round.
+var asciiLetters = func() map[rune]string {
+	m := map[rune]string{}
+	for base, forms := range accents {
+		for _, r := range forms {
+			m[r] = string(base)
+		}
+	}
+	for r, s := range transliterations {
+		m[r] = s
+	}
+	return m
+}()
+
+// transliterate spells name in ASCII. Characters without an ASCII spelling
+// become "_".
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case asciiLetters[r] != "":
+			b.WriteString(asciiLetters[r])
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case unicode.Is(unicode.Mn, r):
+			// A combining accent of the letter before it
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// placeFile moves a finished file from src to the path naming gives it in
+// the output directory, and returns that path relative to outputDir. A name
+// that another file already has gets " (2)", " (3)", ... unless naming says
+// to overwrite; claimed holds the names given during this download, which
+// are never overwritten.
+func (n *Naming) placeFile(src, outputDir string, file DownloadedFile, claimed map[string]bool) (string, error) {
+	ext := filepath.Ext(file.Path)
+	name := n.Template.Render(file, ext, n.ASCII)
+	rel := name
+	for i := 2; ; i++ {
+		dst := filepath.Join(outputDir, rel)
+		key := strings.ToLower(rel)
+		_, err := os.Lstat(dst)
+		free := errors.Is(err, os.ErrNotExist) || (n.Overwrite && err == nil) || sameFile(src, dst)
+		if !claimed[key] && free {
+			claimed[key] = true
+			if err := moveFile(src, dst); err != nil {
+				return "", err
+			}
+			return rel, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("check %s: %w", rel, err)
+		}
+		rel = numberedName(name, i)
+	}
+}
+
+// numberedName returns name with " (n)" before its extension, e.g.
+// "01 Song (2).mp3", for a name that is already taken.
+func numberedName(name string, n int) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// sameFile reports whether a and b are the same file, e.g. a file that is
+// already in place under its new name.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNameTemplateRender(t *testing.T) {
+	tmpl, err := ParseNameTemplate("{albumartist}/{album} ({year})/{disc}-{track:02} {title}")
+	if err != nil {
+		t.Fatalf("ParseNameTemplate failed: %v", err)
+	}
+
+	file := DownloadedFile{Path: "3 - video.mp3", Index: 3, Tags: Metadata{
+		Title: "What? Why: Now", Artist: "AC/DC", Album: "Back in Black", Year: "1980", Track: "3/10", Disc: "1/1",
+	}}
+	want := filepath.Join("AC_DC", "Back in Black (1980)", "1-03 What_ Why_ Now.mp3")
+	if got := tmpl.Render(file, ".mp3", false); got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	// Missing fields leave no empty brackets, and names that need a value
+	// get a placeholder
+	file = DownloadedFile{Path: "7 - video.mp3", Index: 7, Tags: Metadata{Title: "Song"}}
+	want = filepath.Join("Unknown Artist", "Unknown Album", "1-07 Song.mp3")
+	if got := tmpl.Render(file, ".mp3", false); got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	for _, invalid := range []string{"", "/{title}", "{album}/../{title}", "{nope}", "{track:x}", "{title", "title}"} {
+		if _, err := ParseNameTemplate(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+	if tmpl, err := ParseNameTemplate("{{{title}}}"); err != nil || tmpl.Render(file, "", false) != "{Song}" {
+		t.Errorf("expected escaped braces to be kept, got %v", err)
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name  string
+		ascii bool
+		want  string
+	}{
+		{"Live at the Roxy...", false, "Live at the Roxy"},
+		{"a<b>c|d*e\x01", false, "a_b_c_d_e_"},
+		{"con", false, "_con"},
+		{"Nul.txt", false, "_Nul.txt"},
+		{"Sigur Rós – Ágætis byrjun", true, "Sigur Ros - Agaetis byrjun"},
+		{"Mötley Crüe", false, "Mötley Crüe"},
+		{"Straße 東京", true, "Strasse __"},
+		{"...", false, "_"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeName(tt.name, tt.ascii); got != tt.want {
+			t.Errorf("SanitizeName(%q, %v) = %q, want %q", tt.name, tt.ascii, got, tt.want)
+		}
+	}
+
+	long := SanitizeName(strings.Repeat("é", 150), false)
+	if len(long) > maxNameBytes || !strings.HasPrefix(long, "é") || strings.ContainsRune(long, '�') {
+		t.Errorf("expected the name to be cut at a character within %d bytes, got %d bytes", maxNameBytes, len(long))
+	}
+}
+
+func TestDownloadRenamesFromTags(t *testing.T) {
+	tempDir := t.TempDir()
+	// A file from another run already has the name of the first track
+	existing := filepath.Join(tempDir, "Artist", "Album", "01 Same.mp3")
+	if err := os.MkdirAll(filepath.Dir(existing), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("older"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := ParseNameTemplate("{artist}/{album}/{track:02} {title}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &trackRunner{}
+	dl := New(runner, nil)
+	journal := NewJournal(filepath.Join(t.TempDir(), "albums.journal.json"))
+	result, err := dl.Download(context.Background(), Config{
+		OutputDir: tempDir,
+		PlaylistMetadata: &PlaylistMetadata{
+			AlbumInfo: AlbumMetadata{Title: "Album", Artist: "Artist", TotalTracks: 2},
+			Tracks:    []TrackMetadata{{Position: 1, Title: "Same"}, {Position: 2, Title: "Same"}},
+		},
+		TrackSources: []TrackSource{
+			{Position: 1, URL: "https://www.youtube.com/watch?v=one"},
+			{Position: 2, URL: "https://www.youtube.com/watch?v=two"},
+		},
+		Naming:  &Naming{Template: tmpl},
+		Journal: journal.Album("1:" + tempDir),
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	want := []string{
+		filepath.Join("Artist", "Album", "01 Same (2).mp3"),
+		filepath.Join("Artist", "Album", "02 Same.mp3"),
+	}
+	if strings.Join(result.Files, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, result.Files)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "older" {
+		t.Errorf("expected the existing file to be kept, got %q", data)
+	}
+	for _, f := range want {
+		if data, err := os.ReadFile(filepath.Join(tempDir, f)); err != nil || !strings.HasPrefix(string(data), "tagged") {
+			t.Errorf("expected the tagged file at %s: %v", f, err)
+		}
+	}
+	if files := journal.Album("1:" + tempDir).Files; len(files) != 2 || files[0].Path != want[0] || files[0].Stage != StageVerified {
+		t.Errorf("expected the journal to have the new names, got %+v", files)
+	}
+}
@@ -0,0 +1,296 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// StagingDir is the directory, inside the output directory, that yt-dlp
+// downloads into. Files are tagged there and only then moved into place, so
+// a file in the output directory is always finished.
+const StagingDir = ".iturtle-partial"
+
+// pipeline finishes files while the download goes on: each file yt-dlp
+// reports is matched to its track, tagged, verified and moved from the
+// staging directory into the output directory by one of d.jobs workers.
+type pipeline struct {
+	d          *Downloader
+	ctx        context.Context
+	cfg        Config
+	ffmpegCmd  string
+	ffprobeCmd string
+	coverPath  string
+	tag        bool // Whether there are tags or a cover to embed
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	files  []DownloadedFile     // In the order they arrived
+	stages map[string]FileStage // Stage an earlier run got a file to
+	queue  []int                // Files waiting for a worker, by arrival
+	errs   map[int]error
+	closed bool
+	wg     sync.WaitGroup
+
+	placing sync.Mutex      // Held while a file is renamed into place
+	claimed map[string]bool // Names given by cfg.Naming in this download
+}
+
+// startPipeline starts the workers of a pipeline for the download of cfg.
+func (d *Downloader) startPipeline(ctx context.Context, cfg Config, ffmpegCmd, ffprobeCmd, coverPath string, tag bool) *pipeline {
+	p := &pipeline{
+		d:          d,
+		ctx:        ctx,
+		cfg:        cfg,
+		ffmpegCmd:  ffmpegCmd,
+		ffprobeCmd: ffprobeCmd,
+		coverPath:  coverPath,
+		tag:        tag,
+		stages:     map[string]FileStage{},
+		errs:       map[int]error{},
+		claimed:    map[string]bool{},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for range d.jobs {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// add queues a file yt-dlp has just written. It never blocks, so yt-dlp's
+// output keeps being read while the workers are busy.
+func (p *pipeline) add(file DownloadedFile) {
+	p.d.record(p.cfg.Journal, file, StageDownloaded)
+	p.enqueue(file, "")
+}
+
+// resume queues a file an earlier run got to stage.
+func (p *pipeline) resume(file DownloadedFile, stage FileStage) {
+	p.enqueue(file, stage)
+}
+
+func (p *pipeline) enqueue(file DownloadedFile, stage FileStage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stages[file.Path] = stage
+	p.queue = append(p.queue, len(p.files))
+	p.files = append(p.files, file)
+	p.cond.Signal()
+}
+
+// work finishes queued files until the pipeline is closed and drained.
+func (p *pipeline) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if len(p.queue) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		i := p.queue[0]
+		p.queue = p.queue[1:]
+		file := p.files[i]
+		stage := p.stages[file.Path]
+		p.mu.Unlock()
+
+		file, err := p.finish(i, file, stage)
+
+		p.mu.Lock()
+		p.files[i] = file
+		if err != nil {
+			p.errs[i] = err
+		}
+		p.mu.Unlock()
+
+		p.d.progress.ClearLine()
+		if err != nil {
+			p.d.progress.PrintError(fmt.Sprintf("Could not finish %s: %v", file.Path, err))
+			continue
+		}
+		p.d.progress.PrintFile(file.Path)
+	}
+}
+
+// finish tags, verifies and moves the i-th file into place, renamed from
+// its tags if cfg.Naming says so, skipping what an earlier run already did.
+// It returns the file with its tags and final path.
+func (p *pipeline) finish(i int, file DownloadedFile, stage FileStage) (DownloadedFile, error) {
+	final := filepath.Join(p.cfg.OutputDir, file.Path)
+	path := filepath.Join(p.cfg.OutputDir, StagingDir, file.Path)
+	if _, err := os.Stat(path); err != nil {
+		// Moved into place before the journal said so
+		path = final
+	}
+
+	if p.tag {
+		file.Tags = p.cfg.Metadata
+		if p.cfg.PlaylistMetadata != nil {
+			file.Tags = OverrideMetadata(p.d.getTrackMetadata(p.cfg.PlaylistMetadata, file, i), p.cfg.Metadata)
+		}
+		if stageOrder[stage] < stageOrder[StageTagged] {
+			select {
+			case p.d.tagSlots <- struct{}{}:
+			case <-p.ctx.Done():
+				return file, p.ctx.Err()
+			}
+			err := p.d.applyMetadata(p.ctx, p.ffmpegCmd, path, p.coverPath, file.Tags)
+			<-p.d.tagSlots
+			if err != nil {
+				return file, fmt.Errorf("tag: %w", err)
+			}
+			p.d.record(p.cfg.Journal, file, StageTagged)
+		}
+	}
+
+	if stageOrder[stage] < stageOrder[StageVerified] {
+		var tags *Metadata
+		if p.tag {
+			tags = &file.Tags
+		}
+		if err := p.d.verifyFile(p.ctx, p.ffprobeCmd, path, tags); err != nil {
+			return file, err
+		}
+	} else if err := checkFile(path); err != nil {
+		return file, err
+	}
+	if naming := p.cfg.Naming; naming != nil && naming.Template != nil {
+		p.placing.Lock()
+		rel, err := naming.placeFile(path, p.cfg.OutputDir, file, p.claimed)
+		p.placing.Unlock()
+		if err != nil {
+			return file, err
+		}
+		if err := p.cfg.Journal.rename(file.Path, rel); err != nil {
+			p.d.progress.PrintWarning(fmt.Sprintf("Could not update the journal: %v", err))
+		}
+		file.Path = rel
+	} else if path != final {
+		if err := moveFile(path, final); err != nil {
+			return file, err
+		}
+	}
+	p.d.record(p.cfg.Journal, file, StageVerified)
+	return file, nil
+}
+
+// wait closes the pipeline once every file has been added and returns the
+// finished and unfinished files in the order they arrived, along with the
+// errors of the unfinished ones.
+func (p *pipeline) wait() (done, unfinished []DownloadedFile, errs []error) {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+
+	failed := make([]int, 0, len(p.errs))
+	for i := range p.errs {
+		failed = append(failed, i)
+	}
+	sort.Ints(failed)
+	for _, i := range failed {
+		errs = append(errs, fmt.Errorf("%s: %w", p.files[i].Path, p.errs[i]))
+	}
+	for i, file := range p.files {
+		if p.errs[i] != nil {
+			unfinished = append(unfinished, file)
+			continue
+		}
+		done = append(done, file)
+	}
+	return done, unfinished, errs
+}
+
+// moveFile moves a file to dst, copying it when a rename is not possible,
+// e.g. across file systems.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("move into place: %w", err)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("move into place: %w", err)
+	}
+	defer in.Close()
+	tmp := dst + ".partial"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("move into place: %w", err)
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("move into place: %w", err)
+	}
+	return os.Remove(src)
+}
+
+// fileWatcher reads the file list yt-dlp appends to while it runs, so that
+// files can be finished before the download is.
+type fileWatcher struct {
+	path   string
+	dir    string // Output directory the files are relative to
+	offset int64  // Length of the list read so far
+	seen   map[string]bool
+	onFile func(DownloadedFile)
+	files  []DownloadedFile
+}
+
+// poll reads the lines added to the list since the last poll and hands over
+// the new files. Unless yt-dlp is done, a line it is still writing is left
+// for the next poll.
+func (w *fileWatcher) poll(done bool) error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	if info.Size() <= w.offset {
+		return nil
+	}
+	f, err := os.Open(w.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(w.offset, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if done {
+		end = len(data)
+	}
+	w.offset += int64(end)
+	for _, file := range parseDownloadedFiles(string(data[:end]), w.dir) {
+		if w.seen[file.Path] {
+			continue
+		}
+		w.seen[file.Path] = true
+		w.files = append(w.files, file)
+		if w.onFile != nil {
+			w.onFile(file)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadFinishesFilesWhileDownloading(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &pipelineRunner{}
+	dl := New(runner, nil)
+
+	result, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: tempDir,
+		PlaylistMetadata: &PlaylistMetadata{
+			AlbumInfo: AlbumMetadata{Title: "Album"},
+			Tracks:    []TrackMetadata{{Position: 1, Title: "One"}, {Position: 2, Title: "Two"}, {Position: 3, Title: "Three"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	// Every file was in place, tagged, before yt-dlp wrote the next one
+	if strings.Join(runner.events, ",") != "download 1,placed 1,download 2,placed 2,download 3,placed 3" {
+		t.Errorf("expected each file to be finished while the next downloads, got %v", runner.events)
+	}
+	if strings.Join(result.Files, ",") != "1 - One.mp3,2 - Two.mp3,3 - Three.mp3" {
+		t.Errorf("unexpected files: %v", result.Files)
+	}
+	if result.Downloaded[1].Tags.Title != "Two" || result.Downloaded[1].Tags.Track != "2" {
+		t.Errorf("unexpected tags of the second file: %+v", result.Downloaded[1].Tags)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, StagingDir)); !os.IsNotExist(err) {
+		t.Errorf("expected the empty staging directory to be removed, got %v", err)
+	}
+}
+
+func TestDownloadLeavesUnfinishedFilesStaged(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &parallelRunner{failing: "track2.mp3"}
+	dl := New(runner, nil)
+
+	result, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: tempDir,
+		Metadata:  Metadata{Album: "Album"},
+	})
+	if err == nil || len(result.Unfinished) != 1 {
+		t.Fatalf("expected one unfinished file, got %v (%v)", result.Unfinished, err)
+	}
+
+	// Only finished files reach the output directory
+	if _, err := os.Stat(filepath.Join(tempDir, "track2.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected the untagged file to stay out of the output directory, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, StagingDir, "track2.mp3")); err != nil {
+		t.Errorf("expected the untagged file to stay staged: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "track1.mp3")); err != nil {
+		t.Errorf("expected the tagged file in place: %v", err)
+	}
+}
+
+func TestDownloadLeavesUnverifiedFilesStaged(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &untaggedRunner{untagged: "track2.mp3"}
+	dl := New(runner, nil)
+
+	result, err := dl.Download(context.Background(), Config{
+		URL:       "https://www.youtube.com/playlist?list=PL1",
+		OutputDir: tempDir,
+		Metadata:  Metadata{Album: "Album"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "album tag") || len(result.Unfinished) != 1 || result.Unfinished[0] != "track2.mp3" {
+		t.Fatalf("expected the file ffmpeg left untagged to be unfinished, got %v (%v)", result.Unfinished, err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, StagingDir, "track2.mp3")); err != nil {
+		t.Errorf("expected the untagged file to stay staged: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "track1.mp3")); err != nil {
+		t.Errorf("expected the verified file in place: %v", err)
+	}
+}
+
+// untaggedRunner is a parallelRunner whose ffmpeg succeeds on the untagged
+// file without writing any tags, as a broken ffmpeg run could.
+type untaggedRunner struct {
+	parallelRunner
+	untagged string
+}
+
+func (f *untaggedRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	if name == "ffmpeg" && filepath.Base(args[2]) == f.untagged {
+		return "ok", os.WriteFile(args[len(args)-1], []byte("audio"), 0o644)
+	}
+	return f.parallelRunner.Run(ctx, name, args...)
+}
+
+func TestVerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "track.mp3")
+	if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tags := &Metadata{Title: "Sigur Rós", Album: "Ágætis byrjun"}
+
+	for _, tc := range []struct {
+		output string
+		tags   *Metadata
+		ok     bool
+	}{
+		{`{"format": {"duration": "204.533000", "tags": {"title": "Sigur Rós", "album": "Ágætis byrjun"}}}`, tags, true},
+		// FLAC files name their tags in capitals, and ffprobe may warn first
+		{"[flac @ 0x1] warning\n" + `{"format": {"duration": "204.5", "tags": {"TITLE": "Sigur Rós", "ALBUM": "Ágætis byrjun"}}}`, tags, true},
+		{`{"format": {"duration": "204.5", "tags": {"title": "Sigur Rós"}}}`, tags, false},
+		{`{"format": {"duration": "204.5"}}`, nil, true},
+		{`{"format": {"duration": "N/A"}}`, nil, false},
+		{"Invalid data found when processing input", nil, false},
+	} {
+		runner := &probeRunner{output: tc.output}
+		err := New(runner, nil).verifyFile(context.Background(), "ffprobe", path, tc.tags)
+		if (err == nil) != tc.ok {
+			t.Errorf("verifyFile with %q: got error %v, want ok %v", tc.output, err, tc.ok)
+		}
+		if runner.name != "ffprobe" || runner.args[len(runner.args)-1] != path {
+			t.Errorf("expected ffprobe to read %s, got %s %v", path, runner.name, runner.args)
+		}
+	}
+
+	if err := New(&probeRunner{}, nil).verifyFile(context.Background(), "ffprobe", filepath.Join(t.TempDir(), "missing.mp3"), nil); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestFileWatcherLeavesIncompleteLines(t *testing.T) {
+	outDir := t.TempDir()
+	list := filepath.Join(t.TempDir(), "files.txt")
+	var got []string
+	w := &fileWatcher{path: list, dir: outDir, seen: map[string]bool{}, onFile: func(f DownloadedFile) {
+		got = append(got, f.Path)
+	}}
+
+	write := func(s string) {
+		f, err := os.OpenFile(list, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(printedFile("a", 1, filepath.Join(outDir, "1 - a.mp3")) + "b|2|" + outDir)
+	if err := w.poll(false); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if strings.Join(got, ",") != "1 - a.mp3" {
+		t.Fatalf("expected only the complete line, got %v", got)
+	}
+	write(string(filepath.Separator) + "2 - b.mp3\n" + printedFile("a", 1, filepath.Join(outDir, "1 - a.mp3")))
+	if err := w.poll(true); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if strings.Join(got, ",") != "1 - a.mp3,2 - b.mp3" || len(w.files) != 2 {
+		t.Errorf("expected the rest of the line and no duplicates, got %v", got)
+	}
+}
+
+// pipelineRunner streams a playlist of three items like yt-dlp, and before
+// writing each next item waits until the previous one is in the output
+// directory, recording the order of events.
+type pipelineRunner struct {
+	events []string
+}
+
+func (f *pipelineRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	switch name {
+	case "ffmpeg":
+		return "ok", writeTagged(args)
+	case "ffprobe":
+		return probeFile(args)
+	}
+	return "", fmt.Errorf("unexpected command: %s", name)
+}
+
+func (f *pipelineRunner) Stream(ctx context.Context, onLine func(string), name string, args ...string) (string, error) {
+	staging := extractOutputDir(args)
+	outDir := filepath.Dir(staging)
+	for i, title := range []string{"One", "Two", "Three"} {
+		name := fmt.Sprintf("%d - %s.mp3", i+1, title)
+		if err := os.WriteFile(filepath.Join(staging, name), []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		f.events = append(f.events, fmt.Sprintf("download %d", i+1))
+		if err := reportFiles(args, printedFile(title, i+1, filepath.Join(staging, name))); err != nil {
+			return "", err
+		}
+		onLine(fmt.Sprintf("[download] Finished item %d", i+1))
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			data, err := os.ReadFile(filepath.Join(outDir, name))
+			if err == nil && strings.HasPrefix(string(data), "tagged") {
+				break
+			}
+			if time.Now().After(deadline) {
+				return "", errors.New("timed out waiting for " + name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		f.events = append(f.events, fmt.Sprintf("placed %d", i+1))
+	}
+	return "ok", nil
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probeTemplate is printed by yt-dlp once per playlist entry. Fields are
+// tab-separated so titles containing spaces or dashes survive intact.
+const probeTemplate = "%(playlist_index|0)s\t%(id)s\t%(duration|0)s\t%(title)s"
+
+// PlaylistEntry describes one video of a playlist without downloading it.
+type PlaylistEntry struct {
+	Index    int           // 1-based playlist index (0 for single videos)
+	ID       string        // Video ID
+	Title    string        // Video title
+	Duration time.Duration // Zero when yt-dlp does not report it
+}
+
+// ProbePlaylist lists the entries of a video or playlist URL using yt-dlp's
+// flat extraction, which only reads the playlist page and is fast.
+func (d *Downloader) ProbePlaylist(ctx context.Context, ytDLPPath, url string) ([]PlaylistEntry, error) {
+	ytCmd := strings.TrimSpace(ytDLPPath)
+	if ytCmd == "" {
+		ytCmd = "yt-dlp"
+	}
+
+	output, err := d.runner.Run(ctx, ytCmd,
+		"--flat-playlist",
+		"--ignore-errors",
+		"--print", probeTemplate,
+		url,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseProbeOutput(output), nil
+}
+
+// parseProbeOutput extracts entries from yt-dlp output, skipping warnings and
+// other lines that do not follow probeTemplate.
+func parseProbeOutput(output string) []PlaylistEntry {
+	var entries []PlaylistEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			index = 0
+		}
+		entry := PlaylistEntry{
+			Index: index,
+			ID:    fields[1],
+			Title: fields[3],
+		}
+		if seconds, err := strconv.ParseFloat(fields[2], 64); err == nil && seconds > 0 {
+			entry.Duration = time.Duration(seconds * float64(time.Second))
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// TotalDuration sums the durations of entries. It returns 0 if any entry has
+// an unknown duration, since a partial sum would be misleading.
+func TotalDuration(entries []PlaylistEntry) time.Duration {
+	var total time.Duration
+	for _, e := range entries {
+		if e.Duration <= 0 {
+			return 0
+		}
+		total += e.Duration
+	}
+	return total
+}
@@ -3,16 +3,24 @@ package downloader
 import (
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
-// ProgressPrinter handles turtle-themed progress output
+// ProgressPrinter handles turtle-themed progress output. It is safe for use
+// by several goroutines; each message is written whole.
 type ProgressPrinter struct {
+	mu         sync.Mutex
 	writer     io.Writer
 	turtlePos  int
 	lastUpdate time.Time
 	turtles    []string
+	lastWidth  int  // Length of the last progress message, to blank out its rest
+	static     bool // Leave out progress animations, for output that is not a terminal
 }
 
 // NewProgressPrinter creates a new turtle progress printer
@@ -32,11 +40,20 @@ func NewProgressPrinter(w io.Writer) *ProgressPrinter {
 
 // PrintStart prints the start of a download operation
 func (p *ProgressPrinter) PrintStart(operation string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	fmt.Fprintf(p.writer, "\n🐢 %s...\n", operation)
 }
 
 // PrintProgress prints an animated progress indicator
 func (p *ProgressPrinter) PrintProgress(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.static {
+		return
+	}
 	// Only update animation every 200ms to avoid flickering
 	if time.Since(p.lastUpdate) < 200*time.Millisecond {
 		return
@@ -59,12 +76,17 @@ func (p *ProgressPrinter) PrintProgress(message string) {
 		animation = "   🐢 "
 	}
 
-	// Print with carriage return to overwrite
-	fmt.Fprintf(p.writer, "\r%s %s", animation, message)
+	// Print with carriage return to overwrite, padded to cover a longer
+	// previous message
+	fmt.Fprintf(p.writer, "\r%s %-*s", animation, p.lastWidth, message)
+	p.lastWidth = utf8.RuneCountInString(message)
 }
 
 // PrintComplete prints a completion message
 func (p *ProgressPrinter) PrintComplete(message string, count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if count == 1 {
 		fmt.Fprintf(p.writer, "\n✅ %s (1 file)\n", message)
 	} else {
@@ -74,6 +96,9 @@ func (p *ProgressPrinter) PrintComplete(message string, count int) {
 
 // PrintFile prints a completed file with turtle
 func (p *ProgressPrinter) PrintFile(filename string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Truncate long filenames
 	display := filename
 	if len(display) > 60 {
@@ -84,21 +109,168 @@ func (p *ProgressPrinter) PrintFile(filename string) {
 
 // PrintError prints an error message
 func (p *ProgressPrinter) PrintError(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	fmt.Fprintf(p.writer, "\n❌ %s\n", message)
 }
 
 // PrintWarning prints a warning message
 func (p *ProgressPrinter) PrintWarning(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	fmt.Fprintf(p.writer, "\n⚠️  %s\n", message)
 }
 
 // PrintSection prints a section header
 func (p *ProgressPrinter) PrintSection(title string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	border := strings.Repeat("─", len(title)+4)
 	fmt.Fprintf(p.writer, "\n┌%s┐\n│  %s  │\n└%s┘\n", border, title, border)
 }
 
+// PrintFailure prints an item that could not be downloaded
+func (p *ProgressPrinter) PrintFailure(item FailedItem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	label := item.ID
+	if label == "" {
+		label = item.URL
+	}
+	if item.Index > 0 {
+		label = fmt.Sprintf("#%02d %s", item.Index, label)
+	}
+	fmt.Fprintf(p.writer, "   ✗ %s [%s] %s\n", label, item.Category, item.Reason)
+}
+
 // ClearLine clears the current line
 func (p *ProgressPrinter) ClearLine() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.static {
+		return
+	}
 	fmt.Fprintf(p.writer, "\r%s\r", strings.Repeat(" ", 80))
 }
+
+// DownloadProgress is the state of a yt-dlp download as read from its output.
+type DownloadProgress struct {
+	Item    int     // Playlist item being downloaded, 1-based (0 if unknown)
+	Items   int     // Number of items in the playlist (0 if unknown)
+	Percent float64 // Downloaded share of the current item (-1 if unknown)
+	Speed   string  // Download speed, e.g. "1.23MiB/s" ("" if unknown)
+	ETA     string  // Time left for the current item, e.g. "00:42" ("" if unknown)
+}
+
+// String formats the progress as "item 3/12 · 45.3% · 1.23MiB/s · ETA 00:42",
+// leaving out what is unknown.
+func (p DownloadProgress) String() string {
+	var parts []string
+	if p.Item > 0 && p.Items > 0 {
+		parts = append(parts, fmt.Sprintf("item %d/%d", p.Item, p.Items))
+	} else if p.Item > 0 {
+		parts = append(parts, fmt.Sprintf("item %d", p.Item))
+	}
+	if p.Percent >= 0 {
+		parts = append(parts, fmt.Sprintf("%.1f%%", p.Percent))
+	}
+	if p.Speed != "" {
+		parts = append(parts, p.Speed)
+	}
+	if p.ETA != "" {
+		parts = append(parts, "ETA "+p.ETA)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// progressPrefix marks the lines printed through progressTemplate.
+const progressPrefix = "[iturtle-progress] "
+
+// progressTemplate makes yt-dlp print every progress update as one line of
+// "percent|speed|eta|playlist index|playlist size". Missing values are "NA".
+const progressTemplate = "download:" + progressPrefix +
+	"%(progress._percent_str)s|%(progress._speed_str)s|%(progress._eta_str)s|%(info.playlist_index)s|%(info.n_entries)s"
+
+var (
+	itemLine     = regexp.MustCompile(`^\[download\] Downloading (?:item|video) (\d+) of (\d+)`)
+	downloadLine = regexp.MustCompile(`^\[download\]\s+(\d+(?:\.\d+)?)%`)
+	speedField   = regexp.MustCompile(`\bat\s+(\S+)`)
+	etaField     = regexp.MustCompile(`\bETA\s+(\S+)`)
+)
+
+// progressParser follows yt-dlp's output, both the lines of
+// progressTemplate and the default "[download]" lines.
+type progressParser struct {
+	state DownloadProgress
+}
+
+func newProgressParser() *progressParser {
+	return &progressParser{state: DownloadProgress{Percent: -1}}
+}
+
+// Parse reads one line of output and reports whether the progress changed.
+func (p *progressParser) Parse(line string) bool {
+	line = strings.TrimSpace(line)
+
+	if m := itemLine.FindStringSubmatch(line); m != nil {
+		p.state.Item, _ = strconv.Atoi(m[1])
+		p.state.Items, _ = strconv.Atoi(m[2])
+		p.state.Percent, p.state.Speed, p.state.ETA = -1, "", ""
+		return true
+	}
+
+	if rest, ok := strings.CutPrefix(line, strings.TrimSpace(progressPrefix)); ok {
+		fields := strings.Split(rest, "|")
+		if len(fields) != 5 {
+			return false
+		}
+		p.state.Percent = parsePercent(fields[0])
+		p.state.Speed = knownValue(fields[1])
+		p.state.ETA = knownValue(fields[2])
+		if n, err := strconv.Atoi(strings.TrimSpace(fields[3])); err == nil {
+			p.state.Item = n
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(fields[4])); err == nil {
+			p.state.Items = n
+		}
+		return true
+	}
+
+	if m := downloadLine.FindStringSubmatch(line); m != nil {
+		p.state.Percent = parsePercent(m[1])
+		p.state.Speed, p.state.ETA = "", ""
+		if f := speedField.FindStringSubmatch(line); f != nil {
+			p.state.Speed = knownValue(f[1])
+		}
+		if f := etaField.FindStringSubmatch(line); f != nil {
+			p.state.ETA = knownValue(f[1])
+		}
+		return true
+	}
+	return false
+}
+
+// parsePercent parses "45.3%" or " 45.3", returning -1 if it is not a number.
+func parsePercent(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return -1
+	}
+	return f
+}
+
+// knownValue returns s trimmed, or "" for yt-dlp's placeholders of unknown
+// values.
+func knownValue(s string) string {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "NA", "Unknown", "Unknown B/s", "N/A":
+		return ""
+	}
+	return s
+}
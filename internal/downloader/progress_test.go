@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressParser(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  DownloadProgress
+	}{
+		{
+			lines: []string{
+				"[download] Downloading item 3 of 12",
+				"[download]  45.3% of ~  3.45MiB at  1.23MiB/s ETA 00:02 (frag 3/10)",
+			},
+			want: DownloadProgress{Item: 3, Items: 12, Percent: 45.3, Speed: "1.23MiB/s", ETA: "00:02"},
+		},
+		{
+			lines: []string{"[download]   0.0% of    3.45MiB at  Unknown B/s ETA Unknown"},
+			want:  DownloadProgress{Percent: 0},
+		},
+		{
+			lines: []string{"[download] 100% of    3.45MiB in 00:00:02 at 1.50MiB/s"},
+			want:  DownloadProgress{Percent: 100, Speed: "1.50MiB/s"},
+		},
+		{
+			lines: []string{progressPrefix + "  7.5%|  2.00MiB/s|00:41|2|9"},
+			want:  DownloadProgress{Item: 2, Items: 9, Percent: 7.5, Speed: "2.00MiB/s", ETA: "00:41"},
+		},
+		{
+			lines: []string{progressPrefix + " 12.0%|NA|NA|NA|NA"},
+			want:  DownloadProgress{Percent: 12},
+		},
+		{
+			lines: []string{
+				"[download] Downloading item 1 of 2",
+				"[download]  50.0% of 1.00MiB at 1.00MiB/s ETA 00:01",
+				"[download] Downloading item 2 of 2",
+			},
+			want: DownloadProgress{Item: 2, Items: 2, Percent: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		p := newProgressParser()
+		for _, line := range tt.lines {
+			p.Parse(line)
+		}
+		if p.state != tt.want {
+			t.Errorf("after %q: got %+v, want %+v", tt.lines, p.state, tt.want)
+		}
+	}
+
+	if newProgressParser().Parse("[youtube] abc: Downloading webpage") {
+		t.Error("expected unrelated lines to leave the progress alone")
+	}
+}
+
+func TestDownloadProgressString(t *testing.T) {
+	p := DownloadProgress{Item: 3, Items: 12, Percent: 45.3, Speed: "1.23MiB/s", ETA: "00:02"}
+	if got, want := p.String(), "item 3/12 · 45.3% · 1.23MiB/s · ETA 00:02"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := (DownloadProgress{Percent: -1}).String(); got != "" {
+		t.Errorf("expected nothing for unknown progress, got %q", got)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{onLine: func(line string) { lines = append(lines, line) }}
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\r\nthird"))
+	w.Flush()
+
+	if got := strings.Join(lines, "|"); got != "first|second|third" {
+		t.Errorf("got lines %q", got)
+	}
+	if w.output.String() != "first\nsecond\r\nthird" {
+		t.Errorf("expected the output to be kept as written, got %q", w.output.String())
+	}
+}
+
+func TestDownloadStreamsProgress(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &streamRunner{fakeRunner: fakeRunner{audioFormat: "mp3"}}
+	var out bytes.Buffer
+
+	dl := New(runner, nil)
+	dl.progress = NewProgressPrinter(&out)
+	dl.progress.lastUpdate = time.Time{}
+
+	_, err := dl.Download(context.Background(), Config{
+		URL:         "https://example.com/playlist",
+		OutputDir:   tempDir,
+		AudioFormat: "mp3",
+		YtDLPPath:   "yt-dlp",
+	})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	if !runner.streamed {
+		t.Fatal("expected yt-dlp to be run through Stream")
+	}
+	if !strings.Contains(out.String(), "item 1/2 · 25.0% · 1.00MiB/s · ETA 00:03") {
+		t.Errorf("expected progress in output, got:\n%s", out.String())
+	}
+	if !strings.Contains(strings.Join(runner.calls[0].args, " "), "--progress-template") {
+		t.Errorf("expected a progress template, args: %v", runner.calls[0].args)
+	}
+}
+
+// streamRunner is a fakeRunner that also streams canned yt-dlp progress.
+type streamRunner struct {
+	fakeRunner
+	streamed bool
+}
+
+func (s *streamRunner) Stream(ctx context.Context, onLine func(string), name string, args ...string) (string, error) {
+	s.streamed = true
+	onLine(progressPrefix + " 25.0%|1.00MiB/s|00:03|1|2")
+	return s.Run(ctx, name, args...)
+}
+
+func TestExecRunnerStream(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	var lines []string
+	output, err := ExecRunner{}.Stream(context.Background(), func(line string) {
+		lines = append(lines, line)
+	}, filepath.Join("/bin", "sh"), "-c", "printf 'one\\rtwo\\n'; printf 'three' >&2")
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if got := strings.Join(lines, "|"); got != "one|two|three" {
+		t.Errorf("got lines %q", got)
+	}
+	if output != "one\rtwo\nthree" {
+		t.Errorf("got output %q", output)
+	}
+}
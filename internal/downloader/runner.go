@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os/exec"
@@ -11,6 +12,14 @@ type Runner interface {
 	Run(ctx context.Context, name string, args ...string) (string, error)
 }
 
+// StreamRunner is a Runner that can also hand over the output of a command
+// line by line while it runs. Lines end at "\n" or "\r" and come from stdout
+// and stderr alike; onLine is never called concurrently.
+type StreamRunner interface {
+	Runner
+	Stream(ctx context.Context, onLine func(line string), name string, args ...string) (string, error)
+}
+
 // ExecRunner executes commands using the local shell utilities (yt-dlp, ffmpeg).
 type ExecRunner struct{}
 
@@ -22,3 +31,47 @@ func (ExecRunner) Run(ctx context.Context, name string, args ...string) (string,
 	}
 	return string(output), nil
 }
+
+func (ExecRunner) Stream(ctx context.Context, onLine func(line string), name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	w := &lineWriter{onLine: onLine}
+	// The same writer for both makes exec serialize the writes
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err := cmd.Run()
+	w.Flush()
+
+	output := w.output.String()
+	if err != nil {
+		return output, fmt.Errorf("%s failed: %w (output: %s)", name, err, output)
+	}
+	return output, nil
+}
+
+// lineWriter collects everything written to it and calls onLine for every
+// complete non-empty line.
+type lineWriter struct {
+	onLine  func(string)
+	output  bytes.Buffer
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.output.Write(p)
+	for _, b := range p {
+		if b == '\n' || b == '\r' {
+			w.Flush()
+			continue
+		}
+		w.pending = append(w.pending, b)
+	}
+	return len(p), nil
+}
+
+// Flush hands over the last line if it did not end with a newline.
+func (w *lineWriter) Flush() {
+	if len(w.pending) > 0 && w.onLine != nil {
+		w.onLine(string(w.pending))
+	}
+	w.pending = w.pending[:0]
+}
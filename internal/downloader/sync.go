@@ -0,0 +1,281 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveFile is the name of the sync archive in a playlist's output
+// directory.
+const ArchiveFile = ".iturtle-archive.json"
+
+// RemovedDir is the directory, inside the output directory, that files of
+// entries that left a playlist are moved to with RemovedMove, keeping their
+// path relative to the output directory.
+const RemovedDir = "removed"
+
+// RemovedPolicy says what a sync does with the files of entries that are no
+// longer in the playlist.
+type RemovedPolicy string
+
+const (
+	RemovedKeep   RemovedPolicy = "keep"   // Leave the file where it is
+	RemovedDelete RemovedPolicy = "delete" // Delete the file
+	RemovedMove   RemovedPolicy = "move"   // Move the file into RemovedDir
+)
+
+// ParseRemovedPolicy parses "keep", "delete" or "move". An empty value means
+// RemovedKeep.
+func ParseRemovedPolicy(value string) (RemovedPolicy, error) {
+	switch policy := RemovedPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "":
+		return RemovedKeep, nil
+	case RemovedKeep, RemovedDelete, RemovedMove:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid removed policy %q: use keep, delete or move", value)
+}
+
+// ArchiveEntry is a playlist entry that a sync has downloaded.
+type ArchiveEntry struct {
+	ID      string   `json:"id"`
+	Path    string   `json:"path"`  // Relative to the output directory
+	Track   int      `json:"track"` // Track number the file was tagged with
+	Title   string   `json:"title,omitempty"`
+	Tags    Metadata `json:"tags"`
+	Removed bool     `json:"removed,omitempty"` // Left the playlist; the file was kept
+}
+
+// Archive records the entries of a playlist that have been downloaded, so
+// that a sync only fetches what is new.
+type Archive struct {
+	URL     string         `json:"url"`
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// LoadArchive reads the archive of the playlist in outputDir. A missing
+// archive is an empty one.
+func LoadArchive(outputDir string) (*Archive, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, ArchiveFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Archive{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	var archive Archive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("parse archive %s: %w", filepath.Join(outputDir, ArchiveFile), err)
+	}
+	return &archive, nil
+}
+
+// Save writes the archive into outputDir, replacing the previous one.
+func (a *Archive) Save(outputDir string) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode archive: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, ArchiveFile), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	return nil
+}
+
+// entry returns the archived entry of a video, or nil.
+func (a *Archive) entry(id string) *ArchiveEntry {
+	for i := range a.Entries {
+		if a.Entries[i].ID == id {
+			return &a.Entries[i]
+		}
+	}
+	return nil
+}
+
+// lastTrack returns the highest track number in the archive, including
+// entries that left the playlist so that numbers are never reused.
+func (a *Archive) lastTrack() int {
+	last := 0
+	for _, e := range a.Entries {
+		last = max(last, e.Track)
+	}
+	return last
+}
+
+// Sync brings the output directory up to date with the playlist at cfg.URL.
+// Entries not yet in the archive are downloaded and tagged as the tracks
+// following the archived ones, in playlist order; with cfg.Items only the
+// selected playlist items. Archived entries that left the playlist are
+// handled according to removed. Entries that fail to download are not
+// archived, so the next sync tries them again.
+func (d *Downloader) Sync(ctx context.Context, cfg Config, removed RemovedPolicy) (*Result, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, errors.New("url is required")
+	}
+	if cfg.OutputDir == "" {
+		cfg.OutputDir = "."
+	}
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create output dir: %w", err)
+	}
+
+	archive, err := LoadArchive(cfg.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	archive.URL = cfg.URL
+
+	d.progress.PrintSection("Syncing playlist")
+	d.progress.PrintStart(fmt.Sprintf("Listing %s", cfg.URL))
+	entries, err := d.ProbePlaylist(ctx, cfg.YtDLPPath, cfg.URL)
+	if err != nil {
+		d.progress.PrintError("Listing the playlist failed")
+		return nil, fmt.Errorf("list playlist: %w", err)
+	}
+	if len(entries) == 0 {
+		// An empty listing is more likely a broken page than an empty
+		// playlist; removing every file over it would be wrong
+		return nil, errors.New("playlist has no entries")
+	}
+
+	// New entries continue the track numbers of the archive
+	present := map[string]bool{}
+	var sources []TrackSource
+	var tracks []TrackMetadata
+	ids := map[int]string{}
+	next := archive.lastTrack() + 1
+	for _, e := range entries {
+		if e.ID == "" || present[e.ID] {
+			continue
+		}
+		present[e.ID] = true
+		if archived := archive.entry(e.ID); archived != nil {
+			// Back in the playlist after being kept
+			archived.Removed = false
+			continue
+		}
+		if !cfg.Items.Contains(e.Index) {
+			// Left out by the selection; still present, so not removed
+			continue
+		}
+		sources = append(sources, TrackSource{Position: next, URL: videoURL(e.ID)})
+		tracks = append(tracks, TrackMetadata{Position: next, Title: e.Title})
+		ids[next] = e.ID
+		next++
+	}
+
+	gone := d.removeEntries(archive, present, cfg.OutputDir, removed)
+	d.progress.PrintComplete(fmt.Sprintf("%d new, %d gone", len(sources), gone), len(entries))
+
+	if len(sources) == 0 {
+		if err := archive.Save(cfg.OutputDir); err != nil {
+			return nil, err
+		}
+		d.progress.PrintSection("Complete")
+		fmt.Fprintf(d.progress.writer, "🎵 Already up to date 🎵\n\n")
+		return &Result{}, nil
+	}
+
+	// Only the new entries are downloaded, each tagged with its track number
+	// and title on top of the album tags
+	pm := &PlaylistMetadata{Tracks: tracks}
+	if cfg.PlaylistMetadata != nil {
+		pm.AlbumInfo = cfg.PlaylistMetadata.AlbumInfo
+	}
+	// The playlist grows, so a total would be wrong on the next sync
+	pm.AlbumInfo.TotalTracks = 0
+	dcfg := cfg
+	dcfg.URL = ""
+	dcfg.TrackSources = sources
+	dcfg.PlaylistMetadata = pm
+	dcfg.Items = nil
+	// The archive already tells what an earlier sync got
+	dcfg.Journal = nil
+
+	result, err := d.Download(ctx, dcfg)
+	if result != nil {
+		for _, file := range result.Downloaded {
+			track, _ := pm.track(file.Index)
+			archive.Entries = append(archive.Entries, ArchiveEntry{
+				ID:    ids[file.Index],
+				Path:  file.Path,
+				Track: file.Index,
+				Title: track.Title,
+				Tags:  file.Tags,
+			})
+		}
+	}
+	// What arrived is archived even if the download failed as a whole
+	if saveErr := archive.Save(cfg.OutputDir); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return result, err
+}
+
+// removeEntries handles the archived entries that are not present in the
+// playlist any more and returns how many were handled. Deleted and moved
+// entries are dropped from the archive; kept ones are marked as removed, and
+// are deleted or moved by a later sync with that policy.
+func (d *Downloader) removeEntries(archive *Archive, present map[string]bool, outputDir string, policy RemovedPolicy) int {
+	gone := 0
+	kept := archive.Entries[:0]
+	for _, e := range archive.Entries {
+		if present[e.ID] || (e.Removed && policy == RemovedKeep) {
+			kept = append(kept, e)
+			continue
+		}
+		var err error
+		switch policy {
+		case RemovedDelete:
+			err = os.Remove(filepath.Join(outputDir, e.Path))
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		case RemovedMove:
+			// The file keeps its path below RemovedDir, numbered if a file
+			// removed earlier already has it
+			dst := filepath.Join(outputDir, RemovedDir, e.Path)
+			for i := 2; ; i++ {
+				if _, statErr := os.Lstat(dst); errors.Is(statErr, os.ErrNotExist) {
+					break
+				}
+				dst = filepath.Join(outputDir, RemovedDir, numberedName(e.Path, i))
+			}
+			if err = os.MkdirAll(filepath.Dir(dst), 0o755); err == nil {
+				err = os.Rename(filepath.Join(outputDir, e.Path), dst)
+			}
+		default:
+			d.progress.PrintFile(fmt.Sprintf("%s (left the playlist, kept)", e.Path))
+			e.Removed = true
+			kept = append(kept, e)
+			gone++
+			continue
+		}
+		if err != nil {
+			// The entry stays archived so the next sync tries again
+			d.progress.PrintWarning(fmt.Sprintf("Could not %s %s: %v", policy, e.Path, err))
+			kept = append(kept, e)
+			continue
+		}
+		d.progress.PrintFile(fmt.Sprintf("%s (left the playlist, %s)", e.Path, pastTense[policy]))
+		gone++
+	}
+	archive.Entries = kept
+	return gone
+}
+
+// pastTense describes what was done to the file of a removed entry.
+var pastTense = map[RemovedPolicy]string{
+	RemovedDelete: "deleted",
+	RemovedMove:   "moved to " + RemovedDir,
+}
+
+// videoURL returns the watch URL of a YouTube video.
+func videoURL(id string) string {
+	return "https://www.youtube.com/watch?v=" + id
+}
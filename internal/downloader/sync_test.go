@@ -0,0 +1,168 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncDownloadsOnlyNewEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &syncRunner{entries: []string{"one", "two"}}
+	dl := New(runner, nil)
+	cfg := Config{
+		URL:         "https://www.youtube.com/playlist?list=PL1",
+		OutputDir:   tempDir,
+		AudioFormat: "mp3",
+		Metadata:    Metadata{Album: "Mix"},
+	}
+
+	result, err := dl.Sync(context.Background(), cfg, RemovedMove)
+	if err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if len(result.Files) != 2 {
+		t.Fatalf("expected 2 files, got %v", result.Files)
+	}
+
+	// "one" leaves the playlist, "three" is added
+	runner.entries = []string{"two", "three"}
+	runner.downloads = nil
+	result, err = dl.Sync(context.Background(), cfg, RemovedMove)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if len(runner.downloads) != 1 || runner.downloads[0] != "three" {
+		t.Errorf("expected only the new entry to be downloaded, got %v", runner.downloads)
+	}
+	if len(result.Files) != 1 || result.Files[0] != "03 - three.mp3" {
+		t.Errorf("expected the new entry to continue the numbering, got %v", result.Files)
+	}
+	tags := runner.tagged["03 - three.mp3"]
+	if !strings.Contains(tags, "track=3") || !strings.Contains(tags, "title=Title three") || !strings.Contains(tags, "album=Mix") {
+		t.Errorf("unexpected tags for the new entry: %q", tags)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, RemovedDir, "01 - one.mp3")); err != nil {
+		t.Errorf("expected the removed entry to be moved: %v", err)
+	}
+
+	archive, err := LoadArchive(tempDir)
+	if err != nil {
+		t.Fatalf("LoadArchive failed: %v", err)
+	}
+	if archive.URL != cfg.URL || len(archive.Entries) != 2 {
+		t.Fatalf("unexpected archive: %+v", archive)
+	}
+	last := archive.Entries[1]
+	if last.ID != "three" || last.Track != 3 || last.Path != "03 - three.mp3" || last.Tags.Album != "Mix" {
+		t.Errorf("unexpected archive entry: %+v", last)
+	}
+}
+
+func TestSyncKeepsRemovedEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &syncRunner{entries: []string{"one", "two"}}
+	dl := New(runner, nil)
+	cfg := Config{URL: "https://www.youtube.com/playlist?list=PL1", OutputDir: tempDir}
+
+	if _, err := dl.Sync(context.Background(), cfg, RemovedKeep); err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	runner.entries = []string{"two"}
+	runner.downloads = nil
+	result, err := dl.Sync(context.Background(), cfg, RemovedKeep)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if len(runner.downloads) != 0 || len(result.Files) != 0 {
+		t.Errorf("expected nothing to download, got %v", runner.downloads)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "01 - one.mp3")); err != nil {
+		t.Errorf("expected the removed entry to be kept: %v", err)
+	}
+
+	archive, err := LoadArchive(tempDir)
+	if err != nil {
+		t.Fatalf("LoadArchive failed: %v", err)
+	}
+	if len(archive.Entries) != 2 || !archive.Entries[0].Removed || archive.Entries[1].Removed {
+		t.Errorf("expected only the first entry to be marked removed: %+v", archive.Entries)
+	}
+
+	// A later sync can still delete it
+	if _, err := dl.Sync(context.Background(), cfg, RemovedDelete); err != nil {
+		t.Fatalf("third sync failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "01 - one.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected the removed entry to be deleted, got %v", err)
+	}
+}
+
+func TestSyncMoveKeepsEarlierRemovedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	runner := &syncRunner{entries: []string{"one", "two"}}
+	dl := New(runner, nil)
+	cfg := Config{URL: "https://www.youtube.com/playlist?list=PL1", OutputDir: tempDir}
+
+	if _, err := dl.Sync(context.Background(), cfg, RemovedMove); err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	// A file of the same name was removed before
+	earlier := filepath.Join(tempDir, RemovedDir, "01 - one.mp3")
+	if err := os.MkdirAll(filepath.Dir(earlier), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(earlier, []byte("earlier"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner.entries = []string{"two"}
+	if _, err := dl.Sync(context.Background(), cfg, RemovedMove); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if data, _ := os.ReadFile(earlier); string(data) != "earlier" {
+		t.Errorf("expected the earlier removed file to be kept, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, RemovedDir, "01 - one (2).mp3")); err != nil {
+		t.Errorf("expected the removed entry to be numbered: %v", err)
+	}
+}
+
+func TestParseRemovedPolicy(t *testing.T) {
+	for value, want := range map[string]RemovedPolicy{"": RemovedKeep, "keep": RemovedKeep, "Move": RemovedMove, "delete": RemovedDelete} {
+		got, err := ParseRemovedPolicy(value)
+		if err != nil || got != want {
+			t.Errorf("ParseRemovedPolicy(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseRemovedPolicy("trash"); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
+
+// syncRunner lists entries as a playlist and downloads them like
+// trackRunner, recording which videos were downloaded.
+type syncRunner struct {
+	trackRunner
+	entries   []string
+	downloads []string
+}
+
+func (f *syncRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	if name == "yt-dlp" && strings.Contains(strings.Join(args, " "), "--flat-playlist") {
+		var output strings.Builder
+		for i, id := range f.entries {
+			fmt.Fprintf(&output, "%d\t%s\t60\tTitle %s\n", i+1, id, id)
+		}
+		return output.String(), nil
+	}
+	if name == "yt-dlp" {
+		url := args[len(args)-1]
+		f.downloads = append(f.downloads, url[strings.LastIndex(url, "=")+1:])
+	}
+	return f.trackRunner.Run(ctx, name, args...)
+}
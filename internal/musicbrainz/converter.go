@@ -1,6 +1,8 @@
 package musicbrainz
 
 import (
+	"fmt"
+
 	"iturtle-smart-fetcher/internal/downloader"
 )
 
@@ -84,3 +86,34 @@ func ToPlaylistMetadataWithCover(release *Release, coverURL string) *downloader.
 	}
 	return pm
 }
+
+// ToTrackMetadata converts a recording and the release it was taken from into
+// tags for a single downloaded file. Without a release only the title, artist
+// and year of first release are known.
+func ToTrackMetadata(recording *Recording, release *Release) downloader.Metadata {
+	if recording == nil {
+		return downloader.Metadata{}
+	}
+
+	meta := downloader.Metadata{
+		Title:  recording.Title,
+		Artist: GetArtistName(recording.ArtistCredit),
+		Year:   ExtractYear(recording.FirstReleaseDate),
+	}
+	if release == nil {
+		return meta
+	}
+
+	meta.Album = release.Title
+	meta.AlbumArtist = GetArtistName(release.ArtistCredit)
+	if meta.Artist == "" {
+		meta.Artist = meta.AlbumArtist
+	}
+	if year := ExtractYear(release.Date); year != "" {
+		meta.Year = year
+	}
+	if position, total := RecordingPosition(release, recording.ID); position > 0 {
+		meta.Track = fmt.Sprintf("%d/%d", position, total)
+	}
+	return meta
+}
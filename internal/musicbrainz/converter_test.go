@@ -227,3 +227,44 @@ func TestToPlaylistMetadataDifferentTrackArtist(t *testing.T) {
 		t.Errorf("expected track 2 artist %q, got %q", "Artist B", pm.Tracks[1].Artist)
 	}
 }
+
+func TestToTrackMetadata(t *testing.T) {
+	recording := &Recording{
+		ID:               "rec-2",
+		Title:            "I'm Not Gonna Teach Your Boyfriend How to Dance with You",
+		ArtistCredit:     []ArtistCredit{{Name: "Black Kids"}},
+		FirstReleaseDate: "2007",
+	}
+	release := &Release{
+		Title:        "Partie Traumatic",
+		Date:         "2008-07-07",
+		ArtistCredit: []ArtistCredit{{Name: "Black Kids"}},
+		Media: []Medium{
+			{Tracks: []Track{
+				{Recording: &Recording{ID: "rec-1"}},
+				{Recording: &Recording{ID: "rec-2"}},
+				{Recording: &Recording{ID: "rec-3"}},
+			}},
+		},
+	}
+
+	meta := ToTrackMetadata(recording, release)
+	if meta.Title != recording.Title || meta.Artist != "Black Kids" {
+		t.Errorf("unexpected title/artist: %q / %q", meta.Title, meta.Artist)
+	}
+	if meta.Album != "Partie Traumatic" || meta.AlbumArtist != "Black Kids" {
+		t.Errorf("unexpected album: %q by %q", meta.Album, meta.AlbumArtist)
+	}
+	if meta.Year != "2008" {
+		t.Errorf("expected year 2008, got %q", meta.Year)
+	}
+	if meta.Track != "2/3" {
+		t.Errorf("expected track 2/3, got %q", meta.Track)
+	}
+
+	// Without a release only the recording itself is known
+	meta = ToTrackMetadata(recording, nil)
+	if meta.Album != "" || meta.Year != "2007" || meta.Track != "" {
+		t.Errorf("unexpected metadata without release: %+v", meta)
+	}
+}
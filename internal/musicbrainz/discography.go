@@ -0,0 +1,107 @@
+package musicbrainz
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Release group types that a discography can be filtered by. Album, EP and
+// single are primary types; live and compilation are secondary types, so a
+// live album is listed under "live" and not under "album".
+var ReleaseGroupTypes = []string{"album", "ep", "single", "live", "compilation"}
+
+// mbidPattern matches a MusicBrainz identifier (a UUID).
+var mbidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsMBID reports whether s looks like a MusicBrainz identifier.
+func IsMBID(s string) bool {
+	return mbidPattern.MatchString(strings.TrimSpace(s))
+}
+
+// ParseReleaseGroupTypes parses a comma-separated list such as "album,ep".
+// "all" selects every type.
+func ParseReleaseGroupTypes(spec string) ([]string, error) {
+	var types []string
+	for _, part := range strings.Split(spec, ",") {
+		t := strings.ToLower(strings.TrimSpace(part))
+		switch {
+		case t == "":
+			continue
+		case t == "all":
+			return append([]string(nil), ReleaseGroupTypes...), nil
+		case !slices.Contains(ReleaseGroupTypes, t):
+			return nil, fmt.Errorf("unknown release group type %q (want %s or all)", t, strings.Join(ReleaseGroupTypes, ", "))
+		case !slices.Contains(types, t):
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no release group types given")
+	}
+	return types, nil
+}
+
+// MatchesType reports whether a release group is of the given type.
+func MatchesType(group ReleaseGroup, t string) bool {
+	hasSecondary := func(name string) bool {
+		for _, s := range group.SecondaryTypes {
+			if strings.EqualFold(s, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch t {
+	case "album":
+		// Studio albums only; live albums and compilations have their own types
+		return strings.EqualFold(group.PrimaryType, "Album") && len(group.SecondaryTypes) == 0
+	case "ep", "single":
+		return strings.EqualFold(group.PrimaryType, t) && !hasSecondary("Live") && !hasSecondary("Compilation")
+	case "live":
+		return hasSecondary("Live")
+	case "compilation":
+		return hasSecondary("Compilation")
+	}
+	return false
+}
+
+// FilterReleaseGroups returns the release groups matching any of types,
+// oldest first. Undated release groups are listed last.
+func FilterReleaseGroups(groups []ReleaseGroup, types []string) []ReleaseGroup {
+	var filtered []ReleaseGroup
+	for _, group := range groups {
+		for _, t := range types {
+			if MatchesType(group, t) {
+				filtered = append(filtered, group)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		di, dj := filtered[i].FirstReleaseDate, filtered[j].FirstReleaseDate
+		if di == "" || dj == "" {
+			return di != "" && dj == ""
+		}
+		return di < dj
+	})
+	return filtered
+}
+
+// ReleaseGroupKind describes the type of a release group, e.g. "Album" or
+// "Album + Live".
+func ReleaseGroupKind(group ReleaseGroup) string {
+	parts := []string{}
+	if group.PrimaryType != "" {
+		parts = append(parts, group.PrimaryType)
+	}
+	parts = append(parts, group.SecondaryTypes...)
+	if len(parts) == 0 {
+		return "Other"
+	}
+	return strings.Join(parts, " + ")
+}
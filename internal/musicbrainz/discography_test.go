@@ -0,0 +1,74 @@
+package musicbrainz
+
+import (
+	"testing"
+)
+
+func TestParseReleaseGroupTypes(t *testing.T) {
+	types, err := ParseReleaseGroupTypes("Album, ep,album")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(types) != 2 || types[0] != "album" || types[1] != "ep" {
+		t.Errorf("unexpected types: %v", types)
+	}
+
+	all, err := ParseReleaseGroupTypes("all")
+	if err != nil || len(all) != len(ReleaseGroupTypes) {
+		t.Errorf("expected every type for all, got %v, %v", all, err)
+	}
+
+	for _, spec := range []string{"", "bootleg"} {
+		if _, err := ParseReleaseGroupTypes(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestFilterReleaseGroups(t *testing.T) {
+	groups := []ReleaseGroup{
+		{ID: "live", PrimaryType: "Album", SecondaryTypes: []string{"Live"}, FirstReleaseDate: "2010"},
+		{ID: "second", PrimaryType: "Album", FirstReleaseDate: "2011-03-01"},
+		{ID: "undated", PrimaryType: "Album"},
+		{ID: "first", PrimaryType: "Album", FirstReleaseDate: "2008-07-07"},
+		{ID: "ep", PrimaryType: "EP", FirstReleaseDate: "2007"},
+		{ID: "best-of", PrimaryType: "Album", SecondaryTypes: []string{"Compilation"}, FirstReleaseDate: "2015"},
+	}
+
+	ids := func(groups []ReleaseGroup) []string {
+		var out []string
+		for _, g := range groups {
+			out = append(out, g.ID)
+		}
+		return out
+	}
+
+	albums := ids(FilterReleaseGroups(groups, []string{"album"}))
+	if len(albums) != 3 || albums[0] != "first" || albums[1] != "second" || albums[2] != "undated" {
+		t.Errorf("expected studio albums oldest first, got %v", albums)
+	}
+
+	mixed := ids(FilterReleaseGroups(groups, []string{"ep", "live", "compilation"}))
+	if len(mixed) != 3 || mixed[0] != "ep" || mixed[1] != "live" || mixed[2] != "best-of" {
+		t.Errorf("unexpected EPs, live albums and compilations: %v", mixed)
+	}
+}
+
+func TestReleaseGroupKind(t *testing.T) {
+	group := ReleaseGroup{PrimaryType: "Album", SecondaryTypes: []string{"Live"}}
+	if kind := ReleaseGroupKind(group); kind != "Album + Live" {
+		t.Errorf("expected %q, got %q", "Album + Live", kind)
+	}
+	if kind := ReleaseGroupKind(ReleaseGroup{}); kind != "Other" {
+		t.Errorf("expected %q, got %q", "Other", kind)
+	}
+}
+
+func TestIsMBID(t *testing.T) {
+	if !IsMBID("b10bbbfc-cf9e-42e0-be17-e2c3e1d2600d") {
+		t.Error("expected a UUID to be an MBID")
+	}
+	if IsMBID("Black Kids") {
+		t.Error("expected a name not to be an MBID")
+	}
+}
@@ -0,0 +1,142 @@
+package musicbrainz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EditionPolicy selects one release out of a release group.
+type EditionPolicy string
+
+const (
+	// EditionBest ranks the releases with RankReleases (the default).
+	EditionBest EditionPolicy = "best"
+	// EditionOriginal picks the earliest official release.
+	EditionOriginal EditionPolicy = "original"
+	// EditionDeluxe picks the official release with the most tracks.
+	EditionDeluxe EditionPolicy = "deluxe"
+	// EditionCountry picks the earliest official release from a given country.
+	EditionCountry EditionPolicy = "country"
+)
+
+// Edition is a parsed edition policy.
+type Edition struct {
+	Policy  EditionPolicy
+	Country string // Only used by EditionCountry
+}
+
+// ParseEdition parses "best", "original", "deluxe" or "country:XX".
+// An empty string yields EditionBest.
+func ParseEdition(value string) (Edition, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Edition{Policy: EditionBest}, nil
+	}
+
+	policy, country, _ := strings.Cut(value, ":")
+	switch EditionPolicy(strings.ToLower(policy)) {
+	case EditionBest:
+		return Edition{Policy: EditionBest}, nil
+	case EditionOriginal:
+		return Edition{Policy: EditionOriginal}, nil
+	case EditionDeluxe:
+		return Edition{Policy: EditionDeluxe}, nil
+	case EditionCountry:
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			return Edition{}, fmt.Errorf("edition %q: country code is required (e.g. country:GB)", value)
+		}
+		return Edition{Policy: EditionCountry, Country: country}, nil
+	}
+	return Edition{}, fmt.Errorf("unknown edition %q (expected best, original, deluxe or country:XX)", value)
+}
+
+// String returns the edition in the form accepted by ParseEdition.
+func (e Edition) String() string {
+	if e.Policy == EditionCountry {
+		return string(e.Policy) + ":" + e.Country
+	}
+	if e.Policy == "" {
+		return string(EditionBest)
+	}
+	return string(e.Policy)
+}
+
+// SelectEdition picks a release from the releases of a release group according
+// to the edition policy, and explains the choice. Official releases are
+// preferred whenever the group has any.
+func SelectEdition(releases []Release, edition Edition, target Target, prefs Preferences) (*RankedRelease, error) {
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("release group has no releases")
+	}
+
+	if edition.Policy == "" || edition.Policy == EditionBest {
+		ranked := RankReleases(releases, target, prefs)
+		return &ranked[0], nil
+	}
+
+	candidates := officialReleases(releases)
+	if edition.Policy == EditionCountry {
+		var inCountry []Release
+		for _, r := range candidates {
+			if strings.EqualFold(r.Country, edition.Country) {
+				inCountry = append(inCountry, r)
+			}
+		}
+		if len(inCountry) == 0 {
+			return nil, fmt.Errorf("no %s edition in release group", edition.Country)
+		}
+		candidates = inCountry
+	}
+
+	// Order by date (undated last), keeping the group order on ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := candidates[i].Date, candidates[j].Date
+		if di == "" || dj == "" {
+			return di != "" && dj == ""
+		}
+		return di < dj
+	})
+
+	chosen := candidates[0]
+	var reason string
+	switch edition.Policy {
+	case EditionOriginal:
+		reason = fmt.Sprintf("original edition (%s)", orUnknownDate(chosen.Date))
+	case EditionDeluxe:
+		for _, r := range candidates[1:] {
+			if ReleaseTrackCount(r) > ReleaseTrackCount(chosen) {
+				chosen = r
+			}
+		}
+		reason = fmt.Sprintf("edition with the most tracks (%d)", ReleaseTrackCount(chosen))
+	case EditionCountry:
+		reason = fmt.Sprintf("earliest %s edition (%s)", edition.Country, orUnknownDate(chosen.Date))
+	default:
+		return nil, fmt.Errorf("unknown edition policy %q", edition.Policy)
+	}
+
+	return &RankedRelease{Release: chosen, Reasons: []string{reason}}, nil
+}
+
+// officialReleases returns the official releases, or all releases if none are official.
+func officialReleases(releases []Release) []Release {
+	var official []Release
+	for _, r := range releases {
+		if strings.EqualFold(r.Status, "Official") {
+			official = append(official, r)
+		}
+	}
+	if len(official) == 0 {
+		return append([]Release(nil), releases...)
+	}
+	return official
+}
+
+func orUnknownDate(date string) string {
+	if date == "" {
+		return "undated"
+	}
+	return date
+}
@@ -0,0 +1,92 @@
+package musicbrainz
+
+import (
+	"testing"
+)
+
+func TestParseEdition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Edition
+		wantErr  bool
+	}{
+		{input: "", expected: Edition{Policy: EditionBest}},
+		{input: "best", expected: Edition{Policy: EditionBest}},
+		{input: "Original", expected: Edition{Policy: EditionOriginal}},
+		{input: "deluxe", expected: Edition{Policy: EditionDeluxe}},
+		{input: "country:gb", expected: Edition{Policy: EditionCountry, Country: "GB"}},
+		{input: "country", wantErr: true},
+		{input: "remaster", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			edition, err := ParseEdition(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if edition != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, edition)
+			}
+		})
+	}
+}
+
+func groupReleases() []Release {
+	return []Release{
+		{ID: "reissue", Status: "Official", Date: "2015-03-01", Country: "US", Media: []Medium{{TrackCount: 11}}},
+		{ID: "deluxe", Status: "Official", Date: "2009-01-01", Country: "GB", Media: []Medium{{TrackCount: 11}, {TrackCount: 6}}},
+		{ID: "original", Status: "Official", Date: "2008-07-07", Country: "GB", Media: []Medium{{TrackCount: 11}}},
+		{ID: "bootleg", Status: "Bootleg", Date: "2007-01-01", Country: "US", Media: []Medium{{TrackCount: 20}}},
+	}
+}
+
+func TestSelectEdition(t *testing.T) {
+	tests := []struct {
+		name     string
+		edition  Edition
+		expected string
+	}{
+		{name: "original", edition: Edition{Policy: EditionOriginal}, expected: "original"},
+		{name: "deluxe", edition: Edition{Policy: EditionDeluxe}, expected: "deluxe"},
+		{name: "country", edition: Edition{Policy: EditionCountry, Country: "US"}, expected: "reissue"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chosen, err := SelectEdition(groupReleases(), tc.edition, Target{}, Preferences{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if chosen.Release.ID != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, chosen.Release.ID)
+			}
+			if len(chosen.Reasons) == 0 {
+				t.Errorf("expected a reason for the choice")
+			}
+		})
+	}
+}
+
+func TestSelectEditionBestUsesRanking(t *testing.T) {
+	chosen, err := SelectEdition(groupReleases(), Edition{Policy: EditionBest}, Target{TrackCount: 17}, Preferences{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen.Release.ID != "deluxe" {
+		t.Errorf("expected release matching the playlist track count, got %q", chosen.Release.ID)
+	}
+}
+
+func TestSelectEditionMissingCountry(t *testing.T) {
+	_, err := SelectEdition(groupReleases(), Edition{Policy: EditionCountry, Country: "JP"}, Target{}, Preferences{})
+	if err == nil {
+		t.Fatal("expected error when no release matches the country")
+	}
+}
@@ -8,48 +8,89 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
+	"iturtle-smart-fetcher/internal/ratelimit"
 )
 
 const (
-	// MusicBrainz API base URL
-	apiBaseURL = "https://musicbrainz.org/ws/2"
-	// Cover Art Archive base URL
-	coverArtBaseURL = "https://coverartarchive.org"
-	// User-Agent is required by MusicBrainz API
-	userAgent = "iturtle-smart-fetcher/1.0 (https://github.com/user/iturtle-smart-fetcher)"
+	// DefaultBaseURL is the public MusicBrainz API
+	DefaultBaseURL = "https://musicbrainz.org/ws/2"
+	// DefaultCoverArtBaseURL is the public Cover Art Archive
+	DefaultCoverArtBaseURL = "https://coverartarchive.org"
 	// Rate limit: 1 request per second
 	rateLimitDelay = time.Second
+	// Retries after the first attempt when the server throttles us
+	defaultMaxRetries = 4
+	// Base delay for exponential backoff when no Retry-After is sent
+	defaultBackoff = time.Second
 )
 
 // Client provides access to the MusicBrainz API.
+// It is safe for concurrent use; all requests made through one Client share
+// the per-host rate limits, so a batch run should use one Client throughout.
 type Client struct {
-	httpClient  *http.Client
-	lastRequest time.Time
+	httpClient      *http.Client
+	baseURL         string
+	coverArtBaseURL string
+	userAgent       string
+	rateLimits      map[string]time.Duration // Interval per host; "" means the API host
+	limiters        map[string]*ratelimit.Limiter
+	limitersMu      sync.Mutex
+	cache           *cache.Cache // Optional; nil disables caching
+	maxRetries      int
+	backoff         time.Duration
 }
 
 // NewClient creates a new MusicBrainz API client.
-func NewClient(httpClient *http.Client) *Client {
+// Without options it talks to the public MusicBrainz and Cover Art Archive
+// servers, allowing one request per second to the MusicBrainz API.
+func NewClient(httpClient *http.Client, opts ...Option) *Client {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: 30 * time.Second}
 	}
-	return &Client{
-		httpClient: httpClient,
+	c := &Client{
+		httpClient:      httpClient,
+		baseURL:         DefaultBaseURL,
+		coverArtBaseURL: DefaultCoverArtBaseURL,
+		userAgent:       UserAgent("", ""),
+		rateLimits:      map[string]time.Duration{},
+		limiters:        map[string]*ratelimit.Limiter{},
+		maxRetries:      defaultMaxRetries,
+		backoff:         defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// The API host keeps the MusicBrainz limit unless configured otherwise
+	apiHost := hostOf(c.baseURL)
+	if d, ok := c.rateLimits[""]; ok {
+		delete(c.rateLimits, "")
+		c.rateLimits[apiHost] = d
+	}
+	if _, ok := c.rateLimits[apiHost]; !ok {
+		c.rateLimits[apiHost] = rateLimitDelay
 	}
+	return c
 }
 
 // Release represents a MusicBrainz release (album).
 type Release struct {
-	ID             string  `json:"id"`
-	Title          string  `json:"title"`
-	Status         string  `json:"status"`
-	Date           string  `json:"date"`
-	Country        string  `json:"country"`
-	Barcode        string  `json:"barcode"`
-	ArtistCredit   []ArtistCredit `json:"artist-credit"`
-	LabelInfo      []LabelInfo    `json:"label-info"`
-	Media          []Medium       `json:"media"`
-	ReleaseGroup   *ReleaseGroup  `json:"release-group"`
+	ID              string          `json:"id"`
+	Title           string          `json:"title"`
+	Status          string          `json:"status"`
+	Date            string          `json:"date"`
+	Country         string          `json:"country"`
+	Barcode         string          `json:"barcode"`
+	TrackCount      int             `json:"track-count"` // Only set in search results
+	Score           int             `json:"score"`       // Search relevance (0-100), only set in search results
+	ArtistCredit    []ArtistCredit  `json:"artist-credit"`
+	LabelInfo       []LabelInfo     `json:"label-info"`
+	Media           []Medium        `json:"media"`
+	ReleaseGroup    *ReleaseGroup   `json:"release-group"`
 	CoverArtArchive *CoverArtStatus `json:"cover-art-archive"`
 }
 
@@ -62,9 +103,12 @@ type ArtistCredit struct {
 
 // Artist represents a MusicBrainz artist.
 type Artist struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	SortName string `json:"sort-name"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	SortName       string `json:"sort-name"`
+	Disambiguation string `json:"disambiguation"`
+	Country        string `json:"country"`
+	Score          int    `json:"score"` // Search relevance (0-100), only set in search results
 }
 
 // LabelInfo represents label and catalog information.
@@ -81,9 +125,11 @@ type Label struct {
 
 // Medium represents a disc or other medium in a release.
 type Medium struct {
-	Position int     `json:"position"`
-	Format   string  `json:"format"`
-	Tracks   []Track `json:"tracks"`
+	Position    int     `json:"position"`
+	Format      string  `json:"format"`
+	TrackCount  int     `json:"track-count"`
+	TrackOffset int     `json:"track-offset"` // Tracks before the matched one, only set in recording results
+	Tracks      []Track `json:"tracks"`
 }
 
 // Track represents a single track on a medium.
@@ -98,18 +144,28 @@ type Track struct {
 
 // Recording represents the underlying recording of a track.
 type Recording struct {
-	ID           string         `json:"id"`
-	Title        string         `json:"title"`
-	Length       int            `json:"length"`
-	ISRC         []string       `json:"isrcs"`
-	ArtistCredit []ArtistCredit `json:"artist-credit"`
+	ID               string         `json:"id"`
+	Title            string         `json:"title"`
+	Length           int            `json:"length"`
+	ISRC             []string       `json:"isrcs"`
+	ArtistCredit     []ArtistCredit `json:"artist-credit"`
+	Disambiguation   string         `json:"disambiguation"`
+	Video            bool           `json:"video"`
+	FirstReleaseDate string         `json:"first-release-date"`
+	Releases         []Release      `json:"releases"` // Releases the recording appears on
+	Score            int            `json:"score"`    // Search relevance (0-100), only set in search results
 }
 
 // ReleaseGroup represents a group of releases (e.g., different editions of same album).
 type ReleaseGroup struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	PrimaryType string `json:"primary-type"`
+	ID               string         `json:"id"`
+	Title            string         `json:"title"`
+	PrimaryType      string         `json:"primary-type"`
+	SecondaryTypes   []string       `json:"secondary-types"`
+	FirstReleaseDate string         `json:"first-release-date"`
+	ArtistCredit     []ArtistCredit `json:"artist-credit"`
+	Releases         []Release      `json:"releases"` // Only set when requested with inc=releases
+	Score            int            `json:"score"`    // Search relevance (0-100), only set in search results
 }
 
 // CoverArtStatus indicates whether cover art is available.
@@ -127,6 +183,34 @@ type SearchResult struct {
 	Offset   int       `json:"offset"`
 }
 
+// ReleaseGroupSearchResult contains release group search results from MusicBrainz.
+type ReleaseGroupSearchResult struct {
+	ReleaseGroups []ReleaseGroup `json:"release-groups"`
+	Count         int            `json:"count"`
+	Offset        int            `json:"offset"`
+}
+
+// ArtistSearchResult contains artist search results from MusicBrainz.
+type ArtistSearchResult struct {
+	Artists []Artist `json:"artists"`
+	Count   int      `json:"count"`
+	Offset  int      `json:"offset"`
+}
+
+// ReleaseGroupBrowseResult is one page of an artist's release groups.
+type ReleaseGroupBrowseResult struct {
+	ReleaseGroups []ReleaseGroup `json:"release-groups"`
+	Count         int            `json:"release-group-count"`
+	Offset        int            `json:"release-group-offset"`
+}
+
+// RecordingSearchResult contains recording search results from MusicBrainz.
+type RecordingSearchResult struct {
+	Recordings []Recording `json:"recordings"`
+	Count      int         `json:"count"`
+	Offset     int         `json:"offset"`
+}
+
 // CoverArt represents cover art information from Cover Art Archive.
 type CoverArt struct {
 	Images []CoverArtImage `json:"images"`
@@ -153,46 +237,92 @@ type Thumbnails struct {
 	Size1200 string `json:"1200"`
 }
 
-// rateLimit ensures we don't exceed the MusicBrainz rate limit.
-func (c *Client) rateLimit() {
-	elapsed := time.Since(c.lastRequest)
-	if elapsed < rateLimitDelay {
-		time.Sleep(rateLimitDelay - elapsed)
+// doRequest performs an HTTP request with proper headers. Fresh responses
+// are served from the cache without touching the network; stale ones are
+// revalidated with ETag / If-Modified-Since. Requests are spaced out by the
+// client's rate limiter, and throttled responses (503/429) are retried with
+// exponential backoff, honoring Retry-After.
+func (c *Client) doRequest(ctx context.Context, url string) ([]byte, error) {
+	cached, ok := c.cache.Get(url)
+	if ok && c.cache.Fresh(cached) {
+		return cachedBody(cached)
+	}
+	if c.cache.Offline() {
+		return nil, fmt.Errorf("%s: %w", url, cache.ErrOffline)
+	}
+
+	for attempt := 0; ; attempt++ {
+		lim := c.limiterFor(url)
+		if err := lim.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, body, err := c.get(ctx, url, cached)
+		if err != nil {
+			return nil, err
+		}
+
+		if ratelimit.IsThrottled(resp.StatusCode) && attempt < c.maxRetries {
+			// Hold back every request sharing this client, not just this one
+			lim.Delay(ratelimit.RetryDelay(resp.Header, attempt, c.backoff))
+			continue
+		}
+
+		if resp.StatusCode == http.StatusNotModified && cached != nil {
+			// Failing to update the entry only costs another revalidation
+			_ = c.cache.Revalidated(cached, resp.Header)
+			return cachedBody(cached)
+		}
+
+		// Missing releases and cover art are remembered too
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotFound {
+			_ = c.cache.Put(url, resp.StatusCode, resp.Header, body)
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("API error: status %d: %s", resp.StatusCode, string(body))
+		}
+
+		return body, nil
 	}
-	c.lastRequest = time.Now()
 }
 
-// doRequest performs an HTTP request with proper headers.
-func (c *Client) doRequest(ctx context.Context, url string) ([]byte, error) {
-	c.rateLimit()
+// cachedBody returns the body of a cached response, mapping cached misses
+// back to ErrNotFound.
+func cachedBody(entry *cache.Entry) ([]byte, error) {
+	if entry.Status == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return entry.Body, nil
+}
 
+// get performs a single GET request and reads the whole response body.
+// When cached is set the request is made conditional on it.
+func (c *Client) get(ctx context.Context, url string, cached *cache.Entry) (*http.Response, []byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, nil, fmt.Errorf("create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Accept", "application/json")
+	cache.SetValidators(req, cached)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("execute request: %w", err)
+		return nil, nil, fmt.Errorf("execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, ErrNotFound
-	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error: status %d: %s", resp.StatusCode, string(body))
+		return nil, nil, fmt.Errorf("read response: %w", err)
 	}
 
-	return body, nil
+	return resp, body, nil
 }
 
 // ErrNotFound is returned when a resource is not found.
@@ -201,7 +331,7 @@ var ErrNotFound = fmt.Errorf("not found")
 // GetReleaseByID fetches a release by its MusicBrainz ID.
 func (c *Client) GetReleaseByID(ctx context.Context, mbid string) (*Release, error) {
 	url := fmt.Sprintf("%s/release/%s?inc=artist-credits+labels+recordings+release-groups+isrcs&fmt=json",
-		apiBaseURL, url.PathEscape(mbid))
+		c.baseURL, url.PathEscape(mbid))
 
 	body, err := c.doRequest(ctx, url)
 	if err != nil {
@@ -224,7 +354,7 @@ func (c *Client) SearchReleases(ctx context.Context, query string, limit int) (*
 	}
 
 	searchURL := fmt.Sprintf("%s/release?query=%s&limit=%d&fmt=json",
-		apiBaseURL, url.QueryEscape(query), limit)
+		c.baseURL, url.QueryEscape(query), limit)
 
 	body, err := c.doRequest(ctx, searchURL)
 	if err != nil {
@@ -257,9 +387,174 @@ func (c *Client) AutoSearch(ctx context.Context, query string) (*SearchResult, e
 	return c.SearchReleases(ctx, query, 10)
 }
 
+// SearchReleaseGroups searches for release groups (albums independent of edition).
+// Query format: "artist:Artist Name AND releasegroup:Album Name"
+func (c *Client) SearchReleaseGroups(ctx context.Context, query string, limit int) (*ReleaseGroupSearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	searchURL := fmt.Sprintf("%s/release-group?query=%s&limit=%d&fmt=json",
+		c.baseURL, url.QueryEscape(query), limit)
+
+	body, err := c.doRequest(ctx, searchURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ReleaseGroupSearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse release group search results: %w", err)
+	}
+
+	return &result, nil
+}
+
+// AutoSearchReleaseGroups parses a query string like "Artist - Album" and searches release groups.
+func (c *Client) AutoSearchReleaseGroups(ctx context.Context, query string) (*ReleaseGroupSearchResult, error) {
+	parts := strings.SplitN(query, " - ", 2)
+	if len(parts) == 2 {
+		q := fmt.Sprintf("artist:%q AND releasegroup:%q", strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		return c.SearchReleaseGroups(ctx, q, 10)
+	}
+	return c.SearchReleaseGroups(ctx, query, 10)
+}
+
+// GetReleaseGroup fetches a release group with all of its releases and their media.
+func (c *Client) GetReleaseGroup(ctx context.Context, mbid string) (*ReleaseGroup, error) {
+	url := fmt.Sprintf("%s/release-group/%s?inc=artist-credits+releases+media&fmt=json",
+		c.baseURL, url.PathEscape(mbid))
+
+	body, err := c.doRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var group ReleaseGroup
+	if err := json.Unmarshal(body, &group); err != nil {
+		return nil, fmt.Errorf("parse release group: %w", err)
+	}
+
+	return &group, nil
+}
+
+// SearchRecordings searches for recordings (individual songs).
+// Query format: "artist:Artist Name AND recording:Song Title"
+func (c *Client) SearchRecordings(ctx context.Context, query string, limit int) (*RecordingSearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	searchURL := fmt.Sprintf("%s/recording?query=%s&limit=%d&fmt=json",
+		c.baseURL, url.QueryEscape(query), limit)
+
+	body, err := c.doRequest(ctx, searchURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var result RecordingSearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse recording search results: %w", err)
+	}
+
+	return &result, nil
+}
+
+// AutoSearchRecordings parses a query string like "Artist - Title" and
+// searches for matching recordings.
+func (c *Client) AutoSearchRecordings(ctx context.Context, query string) (*RecordingSearchResult, error) {
+	parts := strings.SplitN(query, " - ", 2)
+	if len(parts) == 2 {
+		q := fmt.Sprintf("artist:%q AND recording:%q", strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		return c.SearchRecordings(ctx, q, 25)
+	}
+
+	return c.SearchRecordings(ctx, query, 25)
+}
+
+// GetRecording fetches a recording with its artist credits and the releases
+// it appears on.
+func (c *Client) GetRecording(ctx context.Context, mbid string) (*Recording, error) {
+	url := fmt.Sprintf("%s/recording/%s?inc=artist-credits+releases+release-groups+media&fmt=json",
+		c.baseURL, url.PathEscape(mbid))
+
+	body, err := c.doRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var recording Recording
+	if err := json.Unmarshal(body, &recording); err != nil {
+		return nil, fmt.Errorf("parse recording: %w", err)
+	}
+
+	return &recording, nil
+}
+
+// SearchArtists searches for artists by name.
+func (c *Client) SearchArtists(ctx context.Context, name string, limit int) (*ArtistSearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	searchURL := fmt.Sprintf("%s/artist?query=%s&limit=%d&fmt=json",
+		c.baseURL, url.QueryEscape(fmt.Sprintf("artist:%q", name)), limit)
+
+	body, err := c.doRequest(ctx, searchURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ArtistSearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse artist search results: %w", err)
+	}
+
+	return &result, nil
+}
+
+// BrowseReleaseGroups fetches one page of the release groups credited to an artist.
+func (c *Client) BrowseReleaseGroups(ctx context.Context, artistID string, offset, limit int) (*ReleaseGroupBrowseResult, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	browseURL := fmt.Sprintf("%s/release-group?artist=%s&inc=artist-credits&limit=%d&offset=%d&fmt=json",
+		c.baseURL, url.QueryEscape(artistID), limit, offset)
+
+	body, err := c.doRequest(ctx, browseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ReleaseGroupBrowseResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse release groups: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ArtistReleaseGroups fetches every release group credited to an artist,
+// following the browse pages.
+func (c *Client) ArtistReleaseGroups(ctx context.Context, artistID string) ([]ReleaseGroup, error) {
+	var groups []ReleaseGroup
+	for {
+		page, err := c.BrowseReleaseGroups(ctx, artistID, len(groups), 100)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page.ReleaseGroups...)
+		if len(page.ReleaseGroups) == 0 || len(groups) >= page.Count {
+			return groups, nil
+		}
+	}
+}
+
 // GetCoverArt fetches cover art information from Cover Art Archive.
 func (c *Client) GetCoverArt(ctx context.Context, releaseID string) (*CoverArt, error) {
-	url := fmt.Sprintf("%s/release/%s", coverArtBaseURL, url.PathEscape(releaseID))
+	url := fmt.Sprintf("%s/release/%s", c.coverArtBaseURL, url.PathEscape(releaseID))
 
 	body, err := c.doRequest(ctx, url)
 	if err != nil {
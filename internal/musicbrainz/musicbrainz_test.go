@@ -3,10 +3,15 @@ package musicbrainz
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
 )
 
 func TestGetArtistName(t *testing.T) {
@@ -274,8 +279,355 @@ func TestAutoSearch(t *testing.T) {
 	}
 }
 
+func TestSearchReleaseGroups(t *testing.T) {
+	var capturedPath, capturedQuery string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			capturedPath = r.URL.Path
+			capturedQuery = r.URL.Query().Get("query")
+			result := ReleaseGroupSearchResult{
+				ReleaseGroups: []ReleaseGroup{
+					{ID: "rg-1", Title: "Partie Traumatic", PrimaryType: "Album", Score: 100},
+				},
+				Count: 1,
+			}
+			body, _ := json.Marshal(result)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(string(body))),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client)
+	result, err := mbClient.AutoSearchReleaseGroups(context.Background(), "Black Kids - Partie Traumatic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasSuffix(capturedPath, "/release-group") {
+		t.Errorf("expected release-group search endpoint, got %q", capturedPath)
+	}
+	if !strings.Contains(capturedQuery, `releasegroup:"Partie Traumatic"`) {
+		t.Errorf("expected release group query, got %q", capturedQuery)
+	}
+	if len(result.ReleaseGroups) != 1 || result.ReleaseGroups[0].ID != "rg-1" {
+		t.Errorf("unexpected release groups: %+v", result.ReleaseGroups)
+	}
+}
+
+func TestGetReleaseGroup(t *testing.T) {
+	var capturedInc string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			capturedInc = r.URL.Query().Get("inc")
+			body := `{"id":"rg-1","title":"Partie Traumatic","first-release-date":"2008-07-07",
+				"releases":[{"id":"rel-1","status":"Official","media":[{"format":"CD","track-count":11}]}]}`
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client)
+	group, err := mbClient.GetReleaseGroup(context.Background(), "rg-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(capturedInc, "releases") {
+		t.Errorf("expected inc=releases, got %q", capturedInc)
+	}
+	if len(group.Releases) != 1 {
+		t.Fatalf("expected 1 release, got %d", len(group.Releases))
+	}
+	if ReleaseTrackCount(group.Releases[0]) != 11 {
+		t.Errorf("expected 11 tracks, got %d", ReleaseTrackCount(group.Releases[0]))
+	}
+}
+
+func TestSearchRecordings(t *testing.T) {
+	var capturedQuery string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			capturedQuery = r.URL.Query().Get("query")
+			if !strings.HasSuffix(r.URL.Path, "/recording") {
+				t.Errorf("unexpected path %q", r.URL.Path)
+			}
+			body := `{"count":1,"recordings":[{"id":"rec-1","title":"Hurricane Jane","length":201000,"score":100,
+				"releases":[{"id":"rel-1","title":"Partie Traumatic","status":"Official","date":"2008-07-07",
+				"release-group":{"primary-type":"Album"},"media":[{"format":"CD","track-offset":3,"track-count":11}]}]}]}`
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client)
+	result, err := mbClient.AutoSearchRecordings(context.Background(), "Black Kids - Hurricane Jane")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if capturedQuery != `artist:"Black Kids" AND recording:"Hurricane Jane"` {
+		t.Errorf("unexpected query %q", capturedQuery)
+	}
+	if len(result.Recordings) != 1 {
+		t.Fatalf("expected 1 recording, got %d", len(result.Recordings))
+	}
+	rec := result.Recordings[0]
+	if rec.Score != 100 || len(rec.Releases) != 1 || rec.Releases[0].ReleaseGroup.PrimaryType != "Album" {
+		t.Errorf("unexpected recording: %+v", rec)
+	}
+	if rec.Releases[0].Media[0].TrackOffset != 3 {
+		t.Errorf("expected track offset 3, got %d", rec.Releases[0].Media[0].TrackOffset)
+	}
+}
+
+func TestGetRecording(t *testing.T) {
+	var capturedInc string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			capturedInc = r.URL.Query().Get("inc")
+			body := `{"id":"rec-1","title":"Hurricane Jane","first-release-date":"2007-10-01",
+				"artist-credit":[{"name":"Black Kids"}],"releases":[{"id":"rel-1","status":"Official"}]}`
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client)
+	rec, err := mbClient.GetRecording(context.Background(), "rec-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(capturedInc, "releases") || !strings.Contains(capturedInc, "release-groups") {
+		t.Errorf("expected releases and release groups in inc, got %q", capturedInc)
+	}
+	if rec.FirstReleaseDate != "2007-10-01" || len(rec.Releases) != 1 {
+		t.Errorf("unexpected recording: %+v", rec)
+	}
+}
+
+func TestSearchArtists(t *testing.T) {
+	var capturedQuery string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			capturedQuery = r.URL.Query().Get("query")
+			body := `{"count":1,"artists":[{"id":"artist-1","name":"Black Kids","disambiguation":"US indie pop band","score":100}]}`
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client)
+	result, err := mbClient.SearchArtists(context.Background(), "Black Kids", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if capturedQuery != `artist:"Black Kids"` {
+		t.Errorf("unexpected query %q", capturedQuery)
+	}
+	if len(result.Artists) != 1 || result.Artists[0].Disambiguation != "US indie pop band" {
+		t.Errorf("unexpected artists: %+v", result.Artists)
+	}
+}
+
+func TestArtistReleaseGroupsPaging(t *testing.T) {
+	var offsets []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			offset := r.URL.Query().Get("offset")
+			offsets = append(offsets, offset)
+			if r.URL.Query().Get("artist") != "artist-1" {
+				t.Errorf("unexpected artist %q", r.URL.Query().Get("artist"))
+			}
+			body := `{"release-group-count":3,"release-group-offset":0,"release-groups":[{"id":"rg-1"},{"id":"rg-2"}]}`
+			if offset == "2" {
+				body = `{"release-group-count":3,"release-group-offset":2,"release-groups":[{"id":"rg-3"}]}`
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client, WithRateLimit("", 0))
+	groups, err := mbClient.ArtistReleaseGroups(context.Background(), "artist-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(groups) != 3 || groups[2].ID != "rg-3" {
+		t.Errorf("expected 3 release groups across pages, got %+v", groups)
+	}
+	if strings.Join(offsets, ",") != "0,2" {
+		t.Errorf("expected offsets 0,2, got %v", offsets)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	requests := 0
+	var conditional string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requests++
+			conditional = r.Header.Get("If-None-Match")
+			if conditional == `"rel-v1"` {
+				return &http.Response{StatusCode: http.StatusNotModified, Body: http.NoBody, Header: http.Header{}}, nil
+			}
+			if strings.Contains(r.URL.Path, "missing") {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("not found")), Header: http.Header{}}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"id":"test-id","title":"Cached Album"}`)),
+				Header:     http.Header{"Etag": []string{`"rel-v1"`}},
+			}, nil
+		}),
+	}
+
+	dir := t.TempDir()
+	c, err := cache.New(dir)
+	if err != nil {
+		t.Fatalf("cache.New failed: %v", err)
+	}
+	mbClient := NewClient(client, WithCache(c), WithRateLimit("", 0))
+
+	for i := 0; i < 2; i++ {
+		release, err := mbClient.GetReleaseByID(context.Background(), "test-id")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if release.Title != "Cached Album" {
+			t.Errorf("unexpected title %q", release.Title)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the second lookup to be served from the cache, got %d requests", requests)
+	}
+
+	// Misses are cached as well
+	for i := 0; i < 2; i++ {
+		if _, err := mbClient.GetReleaseByID(context.Background(), "missing"); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected the cached 404 to be reused, got %d requests", requests)
+	}
+
+	// Stale entries are revalidated with their ETag
+	stale, err := cache.New(dir, cache.WithTTL(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("cache.New failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	mbClient = NewClient(client, WithCache(stale), WithRateLimit("", 0))
+	release, err := mbClient.GetReleaseByID(context.Background(), "test-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conditional != `"rel-v1"` || release.Title != "Cached Album" {
+		t.Errorf("expected a revalidated cached release, got If-None-Match %q and title %q", conditional, release.Title)
+	}
+
+	// Offline mode fails fast for anything not cached
+	offline, err := cache.New(dir, cache.WithOffline(true))
+	if err != nil {
+		t.Fatalf("cache.New failed: %v", err)
+	}
+	mbClient = NewClient(client, WithCache(offline))
+	before := requests
+	if _, err := mbClient.GetReleaseByID(context.Background(), "test-id"); err != nil {
+		t.Errorf("expected cached release offline, got %v", err)
+	}
+	if _, err := mbClient.GetReleaseByID(context.Background(), "uncached"); !errors.Is(err, cache.ErrOffline) {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+	if requests != before {
+		t.Errorf("expected no requests in offline mode, got %d", requests-before)
+	}
+}
+
 type roundTripperFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 	return f(r)
 }
+
+func TestDoRequestRetriesThrottledResponses(t *testing.T) {
+	var calls atomic.Int32
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			status := http.StatusOK
+			header := http.Header{}
+			switch calls.Add(1) {
+			case 1:
+				status = http.StatusServiceUnavailable
+				header.Set("Retry-After", "0")
+			case 2:
+				status = http.StatusTooManyRequests
+			}
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(strings.NewReader(`{"id":"test-id","title":"Test Album"}`)),
+				Header:     header,
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client, WithRateLimit("", 0))
+	mbClient.backoff = time.Millisecond
+
+	release, err := mbClient.GetReleaseByID(context.Background(), "test-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release.Title != "Test Album" {
+		t.Errorf("unexpected title %q", release.Title)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestDoRequestGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(strings.NewReader("slow down")),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	mbClient := NewClient(client, WithRateLimit("", 0))
+	mbClient.backoff = time.Millisecond
+	mbClient.maxRetries = 2
+
+	if _, err := mbClient.GetReleaseByID(context.Background(), "test-id"); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,100 @@
+package musicbrainz
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/cache"
+	"iturtle-smart-fetcher/internal/ratelimit"
+)
+
+// ProjectURL is used as the User-Agent contact when none is configured.
+const ProjectURL = "https://github.com/emmanuelviniciusdev/iTurtle-Smart-Fetcher"
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL points the client at another MusicBrainz server, e.g. a local
+// mirror ("http://localhost:5000/ws/2").
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/"); baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithCoverArtBaseURL points the client at another Cover Art Archive server.
+func WithCoverArtBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/"); baseURL != "" {
+			c.coverArtBaseURL = baseURL
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with every request.
+// MusicBrainz asks for "Application/version ( contact )", see UserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		if userAgent = strings.TrimSpace(userAgent); userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithRateLimit sets the minimum interval between requests to host.
+// An empty host means the MusicBrainz API host; an interval of 0 disables
+// limiting, which is only appropriate for a local mirror.
+func WithRateLimit(host string, interval time.Duration) Option {
+	return func(c *Client) {
+		c.rateLimits[strings.ToLower(strings.TrimSpace(host))] = max(interval, 0)
+	}
+}
+
+// WithCache serves responses from an on-disk cache. Fresh entries skip the
+// network and the rate limiter entirely.
+func WithCache(c *cache.Cache) Option {
+	return func(client *Client) {
+		client.cache = c
+	}
+}
+
+// UserAgent builds a User-Agent following the MusicBrainz etiquette.
+// The contact should be an email address or URL where the operator can be
+// reached; it defaults to the project page.
+func UserAgent(version, contact string) string {
+	if version = strings.TrimSpace(version); version == "" {
+		version = "dev"
+	}
+	if contact = strings.TrimSpace(contact); contact == "" {
+		contact = ProjectURL
+	}
+	return fmt.Sprintf("iturtle-smart-fetcher/%s ( %s )", version, contact)
+}
+
+// limiterFor returns the shared limiter for the host of rawURL.
+func (c *Client) limiterFor(rawURL string) *ratelimit.Limiter {
+	host := hostOf(rawURL)
+
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+
+	lim, ok := c.limiters[host]
+	if !ok {
+		lim = ratelimit.New(c.rateLimits[host])
+		c.limiters[host] = lim
+	}
+	return lim
+}
+
+// hostOf returns the lower-cased host (with port) of rawURL.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,94 @@
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientUsesConfiguredEndpoints(t *testing.T) {
+	var apiPath, coverPath, agent string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/2/release/", func(w http.ResponseWriter, r *http.Request) {
+		apiPath = r.URL.Path
+		agent = r.Header.Get("User-Agent")
+		_ = json.NewEncoder(w).Encode(Release{ID: "test-id", Title: "Mirror Album"})
+	})
+	mux.HandleFunc("/caa/release/", func(w http.ResponseWriter, r *http.Request) {
+		coverPath = r.URL.Path
+		_ = json.NewEncoder(w).Encode(CoverArt{Images: []CoverArtImage{{Front: true, Image: "http://img/front.jpg"}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mbClient := NewClient(server.Client(),
+		WithBaseURL(server.URL+"/ws/2/"),
+		WithCoverArtBaseURL(server.URL+"/caa"),
+		WithUserAgent("tester/1.0 ( test@example.com )"),
+		WithRateLimit("", 0),
+	)
+
+	release, err := mbClient.GetReleaseByID(context.Background(), "test-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release.Title != "Mirror Album" {
+		t.Errorf("unexpected title %q", release.Title)
+	}
+	if apiPath != "/ws/2/release/test-id" {
+		t.Errorf("unexpected API path %q", apiPath)
+	}
+	if agent != "tester/1.0 ( test@example.com )" {
+		t.Errorf("unexpected User-Agent %q", agent)
+	}
+
+	cover, err := mbClient.GetFrontCoverURL(context.Background(), "test-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cover != "http://img/front.jpg" || coverPath != "/caa/release/test-id" {
+		t.Errorf("unexpected cover %q from path %q", cover, coverPath)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	ua := UserAgent("1.2.3", "me@example.com")
+	if ua != "iturtle-smart-fetcher/1.2.3 ( me@example.com )" {
+		t.Errorf("unexpected User-Agent %q", ua)
+	}
+
+	ua = UserAgent("", "")
+	if !strings.Contains(ua, "/dev ") || !strings.Contains(ua, ProjectURL) {
+		t.Errorf("expected defaults in User-Agent, got %q", ua)
+	}
+}
+
+func TestPerHostRateLimits(t *testing.T) {
+	mbClient := NewClient(nil,
+		WithBaseURL("http://localhost:5000/ws/2"),
+		WithRateLimit("coverartarchive.org", 250*time.Millisecond),
+	)
+
+	tests := []struct {
+		url      string
+		expected time.Duration
+	}{
+		{url: "http://localhost:5000/ws/2/release/x", expected: time.Second},
+		{url: "https://coverartarchive.org/release/x", expected: 250 * time.Millisecond},
+		{url: "https://example.com/other", expected: 0},
+	}
+
+	for _, tc := range tests {
+		if got := mbClient.limiterFor(tc.url).Interval(); got != tc.expected {
+			t.Errorf("%s: expected interval %s, got %s", tc.url, tc.expected, got)
+		}
+	}
+
+	if mbClient.limiterFor(tests[0].url) != mbClient.limiterFor("http://localhost:5000/ws/2/artist/y") {
+		t.Error("expected requests to the same host to share a limiter")
+	}
+}
@@ -0,0 +1,287 @@
+package musicbrainz
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPickerLimit is the number of candidates shown when Picker.Limit is unset.
+const defaultPickerLimit = 10
+
+// ErrNoSelection is returned when the user declines to choose a release.
+var ErrNoSelection = errors.New("no release selected")
+
+// ReleaseSummary holds the fields used to tell editions of a release apart.
+type ReleaseSummary struct {
+	ID         string
+	Title      string
+	Artist     string
+	Date       string
+	Country    string
+	Format     string
+	TrackCount int
+	Label      string
+	Barcode    string
+	Status     string
+}
+
+// Summarize extracts the distinguishing fields of a release.
+func Summarize(release Release) ReleaseSummary {
+	s := ReleaseSummary{
+		ID:         release.ID,
+		Title:      release.Title,
+		Artist:     GetArtistName(release.ArtistCredit),
+		Date:       release.Date,
+		Country:    release.Country,
+		Format:     ReleaseFormat(release),
+		TrackCount: ReleaseTrackCount(release),
+		Barcode:    release.Barcode,
+		Status:     release.Status,
+	}
+	for _, li := range release.LabelInfo {
+		if li.Label != nil && li.Label.Name != "" {
+			s.Label = li.Label.Name
+			break
+		}
+	}
+	return s
+}
+
+// ReleaseFormat describes the media of a release, e.g. "CD", "2×CD" or "CD + DVD".
+func ReleaseFormat(release Release) string {
+	var order []string
+	counts := map[string]int{}
+	for _, medium := range release.Media {
+		format := medium.Format
+		if format == "" {
+			format = "(unknown)"
+		}
+		if counts[format] == 0 {
+			order = append(order, format)
+		}
+		counts[format]++
+	}
+
+	parts := make([]string, 0, len(order))
+	for _, format := range order {
+		if counts[format] > 1 {
+			parts = append(parts, fmt.Sprintf("%d×%s", counts[format], format))
+		} else {
+			parts = append(parts, format)
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+// ReleaseTrackCount returns the number of tracks on a release.
+// Search results only carry counts, while lookups carry the full track lists.
+func ReleaseTrackCount(release Release) int {
+	if release.TrackCount > 0 {
+		return release.TrackCount
+	}
+	total := 0
+	for _, medium := range release.Media {
+		if len(medium.Tracks) > 0 {
+			total += len(medium.Tracks)
+		} else {
+			total += medium.TrackCount
+		}
+	}
+	return total
+}
+
+// String renders the summary as a single line for listings.
+func (s ReleaseSummary) String() string {
+	orUnknown := func(v string) string {
+		if v == "" {
+			return "?"
+		}
+		return v
+	}
+
+	fields := []string{
+		orUnknown(s.Date),
+		orUnknown(s.Country),
+		orUnknown(s.Format),
+		fmt.Sprintf("%d tracks", s.TrackCount),
+		orUnknown(s.Label),
+		"barcode " + orUnknown(s.Barcode),
+		orUnknown(s.Status),
+	}
+	return strings.Join(fields, " · ")
+}
+
+// Picker asks the user to choose between candidate releases.
+type Picker struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// Limit caps the number of candidates listed (default 10).
+	Limit int
+}
+
+// NewPicker creates a Picker reading answers from in and writing prompts to out.
+// A single Picker should be reused for consecutive prompts so that buffered
+// input is not lost between them.
+func NewPicker(in io.Reader, out io.Writer) *Picker {
+	return &Picker{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// ChooseRelease lists the candidates and returns the one the user picked.
+// An empty answer selects the first candidate; "0" or "s" returns ErrNoSelection.
+func (p *Picker) ChooseRelease(releases []Release) (*Release, error) {
+	if len(releases) == 0 {
+		return nil, ErrNoSelection
+	}
+
+	limit := p.Limit
+	if limit <= 0 {
+		limit = defaultPickerLimit
+	}
+	if len(releases) < limit {
+		limit = len(releases)
+	}
+
+	fmt.Fprintf(p.out, "\nMusicBrainz candidates:\n")
+	for i, release := range releases[:limit] {
+		s := Summarize(release)
+		fmt.Fprintf(p.out, "  %2d) %s - %s\n", i+1, s.Artist, s.Title)
+		fmt.Fprintf(p.out, "      %s\n", s)
+	}
+
+	for {
+		fmt.Fprintf(p.out, "Choose a release [1-%d, Enter = 1, 0 = skip]: ", limit)
+
+		line, err := p.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if err != nil && answer == "" {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrNoSelection
+			}
+			return nil, fmt.Errorf("read choice: %w", err)
+		}
+
+		switch answer {
+		case "":
+			return &releases[0], nil
+		case "0", "s", "skip":
+			return nil, ErrNoSelection
+		}
+
+		n, convErr := strconv.Atoi(answer)
+		if convErr == nil && n >= 1 && n <= limit {
+			return &releases[n-1], nil
+		}
+		fmt.Fprintf(p.out, "Invalid choice %q\n", answer)
+		if err != nil {
+			return nil, ErrNoSelection
+		}
+	}
+}
+
+// ChooseReleaseGroups lists every release group and returns the ones the user
+// picked, in listing order. Answers are numbers and ranges such as "1,3-5";
+// an empty answer or "a" selects all, "0" or "s" returns ErrNoSelection.
+func (p *Picker) ChooseReleaseGroups(groups []ReleaseGroup) ([]ReleaseGroup, error) {
+	if len(groups) == 0 {
+		return nil, ErrNoSelection
+	}
+
+	fmt.Fprintf(p.out, "\nRelease groups:\n")
+	for i, group := range groups {
+		date := group.FirstReleaseDate
+		if date == "" {
+			date = "?"
+		}
+		fmt.Fprintf(p.out, "  %2d) %s · %s · %s\n", i+1, date, group.Title, ReleaseGroupKind(group))
+	}
+
+	for {
+		fmt.Fprintf(p.out, "Choose release groups [e.g. 1,3-5, Enter = all, 0 = none]: ")
+		line, err := p.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if err != nil && answer == "" {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrNoSelection
+			}
+			return nil, fmt.Errorf("read choice: %w", err)
+		}
+
+		switch answer {
+		case "", "a", "all":
+			return groups, nil
+		case "0", "s", "skip", "none":
+			return nil, ErrNoSelection
+		}
+
+		indexes, parseErr := parseSelection(answer, len(groups))
+		if parseErr == nil {
+			chosen := make([]ReleaseGroup, len(indexes))
+			for i, idx := range indexes {
+				chosen[i] = groups[idx]
+			}
+			return chosen, nil
+		}
+		fmt.Fprintf(p.out, "Invalid choice %q: %v\n", answer, parseErr)
+		if err != nil {
+			return nil, ErrNoSelection
+		}
+	}
+}
+
+// Ask prints prompt and returns the trimmed answer. End of input is an
+// empty answer.
+func (p *Picker) Ask(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	line, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// parseSelection parses "1,3-5" into sorted, de-duplicated 0-based indexes
+// below n.
+func parseSelection(answer string, n int) ([]int, error) {
+	seen := map[int]bool{}
+	var indexes []int
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("%q is not a range", part)
+			}
+		}
+		if first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("%q is out of range 1-%d", part, n)
+		}
+		for i := first; i <= last; i++ {
+			if !seen[i-1] {
+				seen[i-1] = true
+				indexes = append(indexes, i-1)
+			}
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("nothing selected")
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
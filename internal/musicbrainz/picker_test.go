@@ -0,0 +1,190 @@
+package musicbrainz
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	release := Release{
+		ID:      "rel-1",
+		Title:   "Partie Traumatic",
+		Date:    "2008-07-07",
+		Country: "GB",
+		Barcode: "602517712345",
+		Status:  "Official",
+		ArtistCredit: []ArtistCredit{
+			{Name: "Black Kids"},
+		},
+		LabelInfo: []LabelInfo{
+			{CatalogNumber: "CAT-001"},
+			{Label: &Label{Name: "Almost Gold"}},
+		},
+		Media: []Medium{
+			{Format: "CD", Tracks: []Track{{Title: "A"}, {Title: "B"}}},
+			{Format: "CD", TrackCount: 3},
+		},
+	}
+
+	s := Summarize(release)
+
+	if s.Artist != "Black Kids" {
+		t.Errorf("expected artist %q, got %q", "Black Kids", s.Artist)
+	}
+	if s.Format != "2×CD" {
+		t.Errorf("expected format %q, got %q", "2×CD", s.Format)
+	}
+	if s.TrackCount != 5 {
+		t.Errorf("expected 5 tracks, got %d", s.TrackCount)
+	}
+	if s.Label != "Almost Gold" {
+		t.Errorf("expected label %q, got %q", "Almost Gold", s.Label)
+	}
+
+	line := s.String()
+	for _, want := range []string{"2008-07-07", "GB", "2×CD", "5 tracks", "Almost Gold", "602517712345", "Official"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected summary to contain %q, got %q", want, line)
+		}
+	}
+}
+
+func TestReleaseFormatMixedMedia(t *testing.T) {
+	release := Release{
+		Media: []Medium{{Format: "CD"}, {Format: "DVD-Video"}},
+	}
+	if got := ReleaseFormat(release); got != "CD + DVD-Video" {
+		t.Errorf("expected %q, got %q", "CD + DVD-Video", got)
+	}
+}
+
+func TestReleaseTrackCountPrefersSearchCount(t *testing.T) {
+	release := Release{
+		TrackCount: 12,
+		Media:      []Medium{{TrackCount: 10}},
+	}
+	if got := ReleaseTrackCount(release); got != 12 {
+		t.Errorf("expected 12, got %d", got)
+	}
+}
+
+func TestPickerChooseRelease(t *testing.T) {
+	releases := []Release{
+		{ID: "id-1", Title: "Album", Country: "US"},
+		{ID: "id-2", Title: "Album", Country: "GB"},
+		{ID: "id-3", Title: "Album", Country: "JP"},
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		skipped  bool
+	}{
+		{name: "explicit choice", input: "2\n", expected: "id-2"},
+		{name: "default choice", input: "\n", expected: "id-1"},
+		{name: "invalid then valid", input: "9\nabc\n3\n", expected: "id-3"},
+		{name: "skip", input: "0\n", skipped: true},
+		{name: "end of input", input: "", skipped: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			picker := NewPicker(strings.NewReader(tc.input), &out)
+
+			release, err := picker.ChooseRelease(releases)
+			if tc.skipped {
+				if err != ErrNoSelection {
+					t.Fatalf("expected ErrNoSelection, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if release.ID != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, release.ID)
+			}
+			if !strings.Contains(out.String(), "GB") {
+				t.Errorf("expected candidates to be listed, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestPickerLimit(t *testing.T) {
+	releases := []Release{{ID: "id-1"}, {ID: "id-2"}, {ID: "id-3"}}
+
+	var out bytes.Buffer
+	picker := NewPicker(strings.NewReader("3\n2\n"), &out)
+	picker.Limit = 2
+
+	release, err := picker.ChooseRelease(releases)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release.ID != "id-2" {
+		t.Errorf("expected out-of-range choice to be rejected, got %q", release.ID)
+	}
+}
+
+func TestChooseReleaseGroups(t *testing.T) {
+	groups := []ReleaseGroup{
+		{ID: "a", Title: "First", FirstReleaseDate: "2008", PrimaryType: "Album"},
+		{ID: "b", Title: "Second", FirstReleaseDate: "2011", PrimaryType: "Album"},
+		{ID: "c", Title: "Third", PrimaryType: "EP"},
+	}
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "3,1\n", expected: []string{"a", "c"}},
+		{input: "2-3\n", expected: []string{"b", "c"}},
+		{input: "\n", expected: []string{"a", "b", "c"}},
+		{input: "9\n1\n", expected: []string{"a"}},
+	}
+
+	for _, tc := range tests {
+		var out bytes.Buffer
+		chosen, err := NewPicker(strings.NewReader(tc.input), &out).ChooseReleaseGroups(groups)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.input, err)
+		}
+		var ids []string
+		for _, g := range chosen {
+			ids = append(ids, g.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%q: expected %v, got %v", tc.input, tc.expected, ids)
+		}
+	}
+
+	var out bytes.Buffer
+	if _, err := NewPicker(strings.NewReader("0\n"), &out).ChooseReleaseGroups(groups); err != ErrNoSelection {
+		t.Errorf("expected ErrNoSelection, got %v", err)
+	}
+	if !strings.Contains(out.String(), "2011 · Second · Album") {
+		t.Errorf("expected listing of release groups, got %q", out.String())
+	}
+}
+
+func TestPickerAsk(t *testing.T) {
+	var out bytes.Buffer
+	picker := NewPicker(strings.NewReader(" https://youtube.com/playlist?list=PL1 \n"), &out)
+
+	answer, err := picker.Ask("URL: ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answer != "https://youtube.com/playlist?list=PL1" {
+		t.Errorf("unexpected answer %q", answer)
+	}
+
+	// End of input is an empty answer
+	if answer, err := picker.Ask("URL: "); err != nil || answer != "" {
+		t.Errorf("expected empty answer at EOF, got %q, %v", answer, err)
+	}
+}
@@ -0,0 +1,185 @@
+package musicbrainz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Recording score weights. The length of the video is the strongest signal
+// that a recording is the same performance; search relevance and being on an
+// official album separate studio versions from live cuts and covers.
+const (
+	weightRecordingDuration = 40.0
+	weightRecordingSearch   = 30.0
+	weightRecordingAlbum    = 20.0
+	weightRecordingVideo    = 10.0
+)
+
+// RankedRecording is a recording with its score and the reasons behind it.
+type RankedRecording struct {
+	Recording Recording
+	Score     float64
+	Reasons   []string
+}
+
+// RankRecordings scores recordings against the length of the downloaded video
+// and returns them best first. A zero duration is not scored. Recordings with
+// equal scores keep their search order.
+func RankRecordings(recordings []Recording, duration time.Duration) []RankedRecording {
+	ranked := make([]RankedRecording, len(recordings))
+
+	for i, recording := range recordings {
+		r := RankedRecording{Recording: recording}
+		add := func(points float64, reason string, args ...any) {
+			r.Score += points
+			if reason != "" {
+				r.Reasons = append(r.Reasons, fmt.Sprintf(reason, args...))
+			}
+		}
+
+		if duration > 0 && recording.Length > 0 {
+			length := time.Duration(recording.Length) * time.Millisecond
+			diff := absDuration(length - duration)
+			// Full points within 3s, nothing beyond 30s (intros, music video skits)
+			const slack, tolerance = 3 * time.Second, 30 * time.Second
+			switch {
+			case diff <= slack:
+				add(weightRecordingDuration, "length within %s of video", diff.Round(time.Second))
+			case diff < tolerance:
+				add(weightRecordingDuration*(1-float64(diff)/float64(tolerance)), "length %s off video", diff.Round(time.Second))
+			}
+		}
+
+		if recording.Score > 0 {
+			add(weightRecordingSearch*float64(recording.Score)/100, "search score %d", recording.Score)
+		}
+
+		if album := firstAlbum(recording.Releases); album != nil {
+			add(weightRecordingAlbum, "on official album %q", album.Title)
+		}
+
+		if recording.Video {
+			// A music video recording has no release to take album tags from
+			add(-weightRecordingVideo, "")
+		}
+
+		ranked[i] = r
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// SelectRecordingRelease picks the release a recording is best tagged with:
+// the earliest official studio album, then singles and EPs, then other
+// official releases (compilations, live albums, soundtracks), then anything.
+// Preferred countries break ties between releases of the same date.
+func SelectRecordingRelease(recording Recording, prefs Preferences) (*RankedRelease, error) {
+	if len(recording.Releases) == 0 {
+		return nil, fmt.Errorf("recording %s appears on no releases", recording.ID)
+	}
+
+	candidates := append([]Release(nil), recording.Releases...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := releaseClass(candidates[i]), releaseClass(candidates[j])
+		if ci != cj {
+			return ci < cj
+		}
+		di, dj := candidates[i].Date, candidates[j].Date
+		if di != dj {
+			// Undated releases sort last
+			if di == "" || dj == "" {
+				return dj == ""
+			}
+			return di < dj
+		}
+		return countryOrder(prefs.Countries, candidates[i].Country) < countryOrder(prefs.Countries, candidates[j].Country)
+	})
+
+	chosen := candidates[0]
+	var reason string
+	switch releaseClass(chosen) {
+	case classAlbum:
+		reason = fmt.Sprintf("earliest official album (%s)", orUnknownDate(chosen.Date))
+	case classSingle:
+		reason = fmt.Sprintf("earliest official single or EP (%s)", orUnknownDate(chosen.Date))
+	case classOtherOfficial:
+		reason = fmt.Sprintf("earliest official release (%s)", orUnknownDate(chosen.Date))
+	default:
+		reason = fmt.Sprintf("earliest release (%s)", orUnknownDate(chosen.Date))
+	}
+
+	return &RankedRelease{Release: chosen, Reasons: []string{reason}}, nil
+}
+
+// Release classes in order of preference for tagging a single recording.
+const (
+	classAlbum = iota
+	classSingle
+	classOtherOfficial
+	classUnofficial
+)
+
+// releaseClass ranks a release for SelectRecordingRelease.
+func releaseClass(release Release) int {
+	if !strings.EqualFold(release.Status, "Official") {
+		return classUnofficial
+	}
+	group := release.ReleaseGroup
+	if group == nil || len(group.SecondaryTypes) > 0 {
+		return classOtherOfficial
+	}
+	switch strings.ToLower(group.PrimaryType) {
+	case "album":
+		return classAlbum
+	case "single", "ep":
+		return classSingle
+	}
+	return classOtherOfficial
+}
+
+// firstAlbum returns the first official studio album among releases, or nil.
+func firstAlbum(releases []Release) *Release {
+	for i := range releases {
+		if releaseClass(releases[i]) == classAlbum {
+			return &releases[i]
+		}
+	}
+	return nil
+}
+
+// countryOrder returns the position of country in the preferences, with
+// countries not listed after all listed ones.
+func countryOrder(prefs []string, country string) int {
+	if rank := preferenceRank(prefs, country); rank >= 0 {
+		return rank
+	}
+	return len(prefs)
+}
+
+// RecordingPosition finds the recording on release and returns its track
+// number counted across all media, as used for album downloads, and the
+// total number of tracks. Releases taken from recording search results carry
+// no track listing; for single-medium releases the track offset reported by
+// the search is used instead. It returns 0 if the position is unknown.
+func RecordingPosition(release *Release, recordingID string) (int, int) {
+	position, total := 0, 0
+	for _, medium := range release.Media {
+		for _, track := range medium.Tracks {
+			total++
+			if position == 0 && track.Recording != nil && track.Recording.ID == recordingID {
+				position = total
+			}
+		}
+	}
+
+	if total == 0 && len(release.Media) == 1 && release.Media[0].TrackCount > 0 {
+		medium := release.Media[0]
+		return medium.TrackOffset + 1, medium.TrackCount
+	}
+	return position, total
+}
@@ -0,0 +1,126 @@
+package musicbrainz
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func albumRelease(id, date, status, primaryType string, secondary ...string) Release {
+	return Release{
+		ID:     id,
+		Title:  "Release " + id,
+		Date:   date,
+		Status: status,
+		ReleaseGroup: &ReleaseGroup{
+			PrimaryType:    primaryType,
+			SecondaryTypes: secondary,
+		},
+	}
+}
+
+func TestRankRecordingsPrefersMatchingLength(t *testing.T) {
+	recordings := []Recording{
+		{ID: "live", Title: "Song", Length: 260000, Score: 100, Releases: []Release{albumRelease("l", "2009", "Official", "Album", "Live")}},
+		{ID: "studio", Title: "Song", Length: 201000, Score: 95, Releases: []Release{albumRelease("s", "2008", "Official", "Album")}},
+		{ID: "video", Title: "Song", Length: 200000, Score: 90, Video: true},
+	}
+
+	ranked := RankRecordings(recordings, 200*time.Second)
+	if ranked[0].Recording.ID != "studio" {
+		t.Fatalf("expected studio recording first, got %s (%v)", ranked[0].Recording.ID, ranked[0].Reasons)
+	}
+	if !strings.Contains(strings.Join(ranked[0].Reasons, ", "), "official album") {
+		t.Errorf("expected album reason, got %v", ranked[0].Reasons)
+	}
+}
+
+func TestRankRecordingsWithoutDuration(t *testing.T) {
+	recordings := []Recording{
+		{ID: "a", Score: 80},
+		{ID: "b", Score: 100},
+	}
+
+	ranked := RankRecordings(recordings, 0)
+	if ranked[0].Recording.ID != "b" {
+		t.Errorf("expected search score to decide, got %s", ranked[0].Recording.ID)
+	}
+}
+
+func TestSelectRecordingRelease(t *testing.T) {
+	recording := Recording{
+		ID: "rec",
+		Releases: []Release{
+			albumRelease("compilation", "2005", "Official", "Album", "Compilation"),
+			albumRelease("single", "2007", "Official", "Single"),
+			albumRelease("album-us", "2008-07-07", "Official", "Album"),
+			albumRelease("album-reissue", "2012", "Official", "Album"),
+			albumRelease("bootleg", "2001", "Bootleg", "Album"),
+		},
+	}
+
+	chosen, err := SelectRecordingRelease(recording, DefaultPreferences())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen.Release.ID != "album-us" {
+		t.Errorf("expected earliest official album, got %s", chosen.Release.ID)
+	}
+
+	// Without an album, a single beats a compilation
+	recording.Releases = []Release{recording.Releases[0], recording.Releases[1]}
+	chosen, err = SelectRecordingRelease(recording, DefaultPreferences())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen.Release.ID != "single" {
+		t.Errorf("expected single, got %s", chosen.Release.ID)
+	}
+}
+
+func TestSelectRecordingReleasePrefersCountry(t *testing.T) {
+	gb := albumRelease("gb", "2008", "Official", "Album")
+	gb.Country = "GB"
+	us := albumRelease("us", "2008", "Official", "Album")
+	us.Country = "US"
+
+	chosen, err := SelectRecordingRelease(Recording{Releases: []Release{us, gb}}, Preferences{Countries: []string{"GB"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen.Release.ID != "gb" {
+		t.Errorf("expected preferred country to break the tie, got %s", chosen.Release.ID)
+	}
+}
+
+func TestSelectRecordingReleaseNoReleases(t *testing.T) {
+	if _, err := SelectRecordingRelease(Recording{ID: "rec"}, DefaultPreferences()); err == nil {
+		t.Error("expected error for recording without releases")
+	}
+}
+
+func TestRecordingPosition(t *testing.T) {
+	release := &Release{
+		Media: []Medium{
+			{Tracks: []Track{{Recording: &Recording{ID: "a"}}, {Recording: &Recording{ID: "b"}}}},
+			{Tracks: []Track{{Recording: &Recording{ID: "c"}}}},
+		},
+	}
+
+	position, total := RecordingPosition(release, "c")
+	if position != 3 || total != 3 {
+		t.Errorf("expected 3/3, got %d/%d", position, total)
+	}
+	if position, _ := RecordingPosition(release, "missing"); position != 0 {
+		t.Errorf("expected 0 for missing recording, got %d", position)
+	}
+}
+
+func TestRecordingPositionFromSearchOffset(t *testing.T) {
+	release := &Release{Media: []Medium{{TrackOffset: 3, TrackCount: 11}}}
+
+	position, total := RecordingPosition(release, "rec")
+	if position != 4 || total != 11 {
+		t.Errorf("expected 4/11, got %d/%d", position, total)
+	}
+}
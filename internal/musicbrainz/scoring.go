@@ -0,0 +1,199 @@
+package musicbrainz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Score weights. Track count and duration dominate because they tell whether
+// the release actually matches the playlist; the rest break ties between editions.
+const (
+	weightTrackCount = 30.0
+	weightDuration   = 20.0
+	weightStatus     = 15.0
+	weightCountry    = 10.0
+	weightFormat     = 10.0
+	weightDate       = 5.0
+	weightSearch     = 10.0
+)
+
+// DefaultPreferredFormats is used when no media format preference is configured.
+var DefaultPreferredFormats = []string{"Digital Media", "CD"}
+
+// Preferences controls how candidate releases are ranked.
+type Preferences struct {
+	Countries []string // Preferred release countries, most preferred first (e.g. "GB", "US", "XW")
+	Formats   []string // Preferred media formats, most preferred first
+	Lookups   int      // Number of top search results fetched in full to compare durations
+}
+
+// DefaultPreferences returns the preferences used when none are configured.
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Formats: append([]string(nil), DefaultPreferredFormats...),
+		Lookups: 5,
+	}
+}
+
+// Target describes what the downloaded source looks like, so that releases
+// can be compared against it. Zero values mean "unknown" and are not scored.
+type Target struct {
+	TrackCount    int
+	TotalDuration time.Duration
+}
+
+// RankedRelease is a release with its score and the reasons behind it.
+type RankedRelease struct {
+	Release Release
+	Score   float64
+	Reasons []string
+}
+
+// RankReleases scores releases against the target and preferences and returns
+// them best first. Releases with equal scores keep their search order.
+func RankReleases(releases []Release, target Target, prefs Preferences) []RankedRelease {
+	ranked := make([]RankedRelease, len(releases))
+	earliest := earliestDate(releases)
+
+	for i, release := range releases {
+		r := RankedRelease{Release: release}
+		add := func(points float64, reason string, args ...any) {
+			r.Score += points
+			if reason != "" {
+				r.Reasons = append(r.Reasons, fmt.Sprintf(reason, args...))
+			}
+		}
+
+		if target.TrackCount > 0 {
+			count := ReleaseTrackCount(release)
+			diff := absInt(count - target.TrackCount)
+			switch {
+			case diff == 0:
+				add(weightTrackCount, "track count matches playlist (%d)", count)
+			case diff < 3:
+				add(weightTrackCount*(1-float64(diff)/3), "track count close to playlist (%d vs %d)", count, target.TrackCount)
+			}
+		}
+
+		if target.TotalDuration > 0 {
+			if length := releaseLength(release); length > 0 {
+				diff := absDuration(length - target.TotalDuration)
+				// Full points within 2s per track, nothing beyond 10% of the playlist length
+				tolerance := target.TotalDuration / 10
+				slack := time.Duration(max(target.TrackCount, 1)) * 2 * time.Second
+				switch {
+				case diff <= slack:
+					add(weightDuration, "total duration within %s of playlist", diff.Round(time.Second))
+				case diff < tolerance:
+					add(weightDuration*(1-float64(diff)/float64(tolerance)), "total duration %s off playlist", diff.Round(time.Second))
+				}
+			}
+		}
+
+		switch strings.ToLower(release.Status) {
+		case "official":
+			add(weightStatus, "official release")
+		case "promotion":
+			add(weightStatus/3, "")
+		case "bootleg":
+			add(-weightStatus, "")
+		case "pseudo-release":
+			add(-weightStatus/2, "")
+		}
+
+		if rank := preferenceRank(prefs.Countries, release.Country); rank >= 0 {
+			add(weightCountry*preferenceWeight(rank, len(prefs.Countries)), "preferred country %s", release.Country)
+		}
+
+		if format, rank := formatRank(prefs.Formats, release); rank >= 0 {
+			add(weightFormat*preferenceWeight(rank, len(prefs.Formats)), "preferred format %s", format)
+		}
+
+		if release.Date != "" && release.Date == earliest {
+			add(weightDate, "earliest release (%s)", release.Date)
+		}
+
+		if release.Score > 0 {
+			add(weightSearch*float64(release.Score)/100, "search score %d", release.Score)
+		}
+
+		ranked[i] = r
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// releaseLength sums the track lengths of a release, or returns 0 if any is unknown.
+func releaseLength(release Release) time.Duration {
+	var total time.Duration
+	for _, medium := range release.Media {
+		for _, track := range medium.Tracks {
+			if track.Length <= 0 {
+				return 0
+			}
+			total += time.Duration(track.Length) * time.Millisecond
+		}
+	}
+	return total
+}
+
+// earliestDate returns the earliest non-empty date among releases.
+// MusicBrainz dates are YYYY[-MM[-DD]], so they compare lexically; a bare
+// year sorts before any full date in the same year.
+func earliestDate(releases []Release) string {
+	earliest := ""
+	for _, release := range releases {
+		if release.Date != "" && (earliest == "" || release.Date < earliest) {
+			earliest = release.Date
+		}
+	}
+	return earliest
+}
+
+// preferenceRank returns the index of value in prefs (case-insensitive), or -1.
+func preferenceRank(prefs []string, value string) int {
+	if value == "" {
+		return -1
+	}
+	for i, pref := range prefs {
+		if strings.EqualFold(strings.TrimSpace(pref), value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatRank returns the best-ranked preferred format found among the release media.
+func formatRank(prefs []string, release Release) (string, int) {
+	bestFormat, best := "", -1
+	for _, medium := range release.Media {
+		if rank := preferenceRank(prefs, medium.Format); rank >= 0 && (best < 0 || rank < best) {
+			bestFormat, best = medium.Format, rank
+		}
+	}
+	return bestFormat, best
+}
+
+// preferenceWeight scales points so the first preference gets 1 and the last gets 1/n.
+func preferenceWeight(rank, n int) float64 {
+	return float64(n-rank) / float64(n)
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
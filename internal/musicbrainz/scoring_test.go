@@ -0,0 +1,117 @@
+package musicbrainz
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func tracksOf(lengths ...int) []Track {
+	tracks := make([]Track, len(lengths))
+	for i, l := range lengths {
+		tracks[i] = Track{Position: i + 1, Length: l}
+	}
+	return tracks
+}
+
+func TestRankReleasesPrefersMatchingTrackCount(t *testing.T) {
+	releases := []Release{
+		{ID: "deluxe", Status: "Official", Media: []Medium{{Format: "CD", Tracks: tracksOf(1, 1, 1, 1, 1, 1)}}},
+		{ID: "standard", Status: "Official", Media: []Medium{{Format: "CD", Tracks: tracksOf(1, 1, 1)}}},
+	}
+
+	ranked := RankReleases(releases, Target{TrackCount: 3}, DefaultPreferences())
+
+	if ranked[0].Release.ID != "standard" {
+		t.Fatalf("expected release with matching track count first, got %q", ranked[0].Release.ID)
+	}
+	if !containsReason(ranked[0].Reasons, "track count matches") {
+		t.Errorf("expected track count reason, got %v", ranked[0].Reasons)
+	}
+}
+
+func TestRankReleasesPrefersClosestDuration(t *testing.T) {
+	releases := []Release{
+		{ID: "far", Media: []Medium{{Tracks: tracksOf(200000, 200000)}}},
+		{ID: "close", Media: []Medium{{Tracks: tracksOf(180000, 181000)}}},
+	}
+
+	target := Target{TrackCount: 2, TotalDuration: 361 * time.Second}
+	ranked := RankReleases(releases, target, Preferences{})
+
+	if ranked[0].Release.ID != "close" {
+		t.Fatalf("expected closest duration first, got %q", ranked[0].Release.ID)
+	}
+	if !containsReason(ranked[0].Reasons, "total duration within") {
+		t.Errorf("expected duration reason, got %v", ranked[0].Reasons)
+	}
+}
+
+func TestRankReleasesOfficialOverBootleg(t *testing.T) {
+	releases := []Release{
+		{ID: "bootleg", Status: "Bootleg", Score: 100},
+		{ID: "official", Status: "Official", Score: 90},
+	}
+
+	ranked := RankReleases(releases, Target{}, Preferences{})
+
+	if ranked[0].Release.ID != "official" {
+		t.Fatalf("expected official release first, got %q", ranked[0].Release.ID)
+	}
+}
+
+func TestRankReleasesPreferences(t *testing.T) {
+	releases := []Release{
+		{ID: "us-vinyl", Country: "US", Media: []Medium{{Format: "12\" Vinyl"}}},
+		{ID: "gb-cd", Country: "GB", Media: []Medium{{Format: "CD"}}},
+		{ID: "jp-digital", Country: "JP", Media: []Medium{{Format: "Digital Media"}}},
+	}
+
+	prefs := Preferences{Countries: []string{"GB", "US"}, Formats: []string{"CD"}}
+	ranked := RankReleases(releases, Target{}, prefs)
+
+	if ranked[0].Release.ID != "gb-cd" {
+		t.Fatalf("expected preferred country and format first, got %q", ranked[0].Release.ID)
+	}
+	if ranked[1].Release.ID != "us-vinyl" {
+		t.Errorf("expected second preferred country next, got %q", ranked[1].Release.ID)
+	}
+	if !containsReason(ranked[0].Reasons, "preferred country GB") || !containsReason(ranked[0].Reasons, "preferred format CD") {
+		t.Errorf("expected preference reasons, got %v", ranked[0].Reasons)
+	}
+}
+
+func TestRankReleasesPrefersEarliestDate(t *testing.T) {
+	releases := []Release{
+		{ID: "reissue", Date: "2015-03-01"},
+		{ID: "original", Date: "2008-07-07"},
+		{ID: "undated"},
+	}
+
+	ranked := RankReleases(releases, Target{}, Preferences{})
+
+	if ranked[0].Release.ID != "original" {
+		t.Fatalf("expected earliest release first, got %q", ranked[0].Release.ID)
+	}
+}
+
+func TestRankReleasesKeepsSearchOrderOnTies(t *testing.T) {
+	releases := []Release{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	ranked := RankReleases(releases, Target{}, Preferences{})
+
+	for i, id := range []string{"a", "b", "c"} {
+		if ranked[i].Release.ID != id {
+			t.Errorf("position %d: expected %q, got %q", i, id, ranked[i].Release.ID)
+		}
+	}
+}
+
+func containsReason(reasons []string, substr string) bool {
+	for _, r := range reasons {
+		if strings.Contains(r, substr) {
+			return true
+		}
+	}
+	return false
+}
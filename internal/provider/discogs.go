@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"iturtle-smart-fetcher/internal/discogs"
+)
+
+// Discogs looks up releases by Discogs release or master ID, or searches for
+// an "Artist - Album" query when the client has a token. It never searches
+// on its own for the artist and album other providers found: a search match
+// may be another pressing, and would override their label and catalog.
+type Discogs struct {
+	client *discogs.Client
+}
+
+// NewDiscogs creates a Discogs provider using client.
+func NewDiscogs(client *discogs.Client) *Discogs {
+	return &Discogs{client: client}
+}
+
+// Name returns "discogs".
+func (d *Discogs) Name() string {
+	return NameDiscogs
+}
+
+// Fetch looks up the release. Its result ID is the Discogs release ID.
+func (d *Discogs) Fetch(ctx context.Context, req Request) (*Result, error) {
+	switch {
+	case req.DiscogsReleaseID > 0:
+		return d.release(ctx, req.DiscogsReleaseID, 1)
+	case req.DiscogsMasterID > 0:
+		return d.master(ctx, req.DiscogsMasterID, 0.9)
+	}
+
+	artist, album, _ := strings.Cut(req.Query, " - ")
+	if album == "" {
+		artist, album = "", artist
+	}
+	if strings.TrimSpace(album) == "" || !d.client.HasToken() {
+		return nil, ErrNotApplicable
+	}
+
+	results, err := d.client.Search(ctx, strings.TrimSpace(artist), strings.TrimSpace(album), 5)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no releases found for %s - %s", artist, album)
+	}
+
+	best := results[0]
+	fmt.Fprintf(req.stdout(), "🏷️  Discogs: %s (%s %d)\n", best.Title, best.Type, best.ID)
+	if best.Type == "master" {
+		return d.master(ctx, best.ID, 0.6)
+	}
+	return d.release(ctx, best.ID, 0.6)
+}
+
+// release fetches a release by ID.
+func (d *Discogs) release(ctx context.Context, id int, confidence float64) (*Result, error) {
+	release, err := d.client.GetRelease(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch release %d: %w", id, err)
+	}
+	return &Result{
+		Metadata:   discogs.ToPlaylistMetadata(release),
+		Confidence: confidence,
+		ID:         fmt.Sprint(release.ID),
+	}, nil
+}
+
+// master fetches a master and its main release, which carries the label and
+// catalog number. The master alone is used if the main release is missing.
+func (d *Discogs) master(ctx context.Context, id int, confidence float64) (*Result, error) {
+	master, err := d.client.GetMaster(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch master %d: %w", id, err)
+	}
+	if master.MainRelease > 0 {
+		result, err := d.release(ctx, master.MainRelease, confidence)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil || !errors.Is(err, discogs.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return &Result{
+		Metadata:   discogs.MasterToPlaylistMetadata(master),
+		Confidence: confidence,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+// Genres supplies the genre from a local artist → genre map, for artists
+// whose MusicBrainz data carries no genre or the wrong one.
+type Genres struct {
+	genres map[string]string // Keyed by lower-cased artist
+}
+
+// NewGenres creates a Genres provider from an artist → genre map. Artist
+// names are matched case-insensitively.
+func NewGenres(genres map[string]string) *Genres {
+	g := &Genres{genres: map[string]string{}}
+	for artist, genre := range genres {
+		g.genres[strings.ToLower(strings.TrimSpace(artist))] = genre
+	}
+	return g
+}
+
+// Name returns "genres".
+func (g *Genres) Name() string {
+	return NameGenres
+}
+
+// Fetch returns the genre of the request's artist. The artist comes from the
+// request or from a provider earlier in the chain.
+func (g *Genres) Fetch(ctx context.Context, req Request) (*Result, error) {
+	artist := req.Artist
+	if artist == "" {
+		artist, _, _ = strings.Cut(req.Query, " - ")
+	}
+	genre, ok := g.genres[strings.ToLower(strings.TrimSpace(artist))]
+	if !ok {
+		return nil, ErrNotApplicable
+	}
+
+	return &Result{
+		Metadata:   &downloader.PlaylistMetadata{AlbumInfo: downloader.AlbumMetadata{Genre: genre}},
+		Confidence: 1,
+	}, nil
+}
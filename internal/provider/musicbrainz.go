@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"iturtle-smart-fetcher/internal/musicbrainz"
+)
+
+// MusicBrainz looks up releases by ID, release group or "Artist - Album"
+// search. Searches resolve to a release group first and then pick an edition
+// from it by policy; when Picker is set the user chooses from the ranked
+// releases instead.
+type MusicBrainz struct {
+	client *musicbrainz.Client
+	// Prefs ranks search candidates.
+	Prefs musicbrainz.Preferences
+	// Picker, if set, lets the user choose between candidates.
+	Picker *musicbrainz.Picker
+}
+
+// NewMusicBrainz creates a MusicBrainz provider using client.
+func NewMusicBrainz(client *musicbrainz.Client) *MusicBrainz {
+	return &MusicBrainz{
+		client: client,
+		Prefs:  musicbrainz.DefaultPreferences(),
+	}
+}
+
+// Name returns "musicbrainz".
+func (m *MusicBrainz) Name() string {
+	return NameMusicBrainz
+}
+
+// Fetch looks up the release. Its result ID is the release MBID.
+func (m *MusicBrainz) Fetch(ctx context.Context, req Request) (*Result, error) {
+	var release *musicbrainz.Release
+	var err error
+	confidence := 0.8
+
+	if req.ReleaseID != "" {
+		// Fetch by MusicBrainz ID
+		release, err = m.client.GetReleaseByID(ctx, req.ReleaseID)
+		if err != nil {
+			return nil, fmt.Errorf("fetch release by ID: %w", err)
+		}
+		confidence = 1
+	} else if req.ReleaseGroupID != "" {
+		release, err = m.releaseFromGroup(ctx, req.ReleaseGroupID, req)
+		if err != nil {
+			return nil, err
+		}
+		confidence = 0.9
+	} else if req.Query != "" {
+		release, err = m.searchRelease(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, ErrNotApplicable
+	}
+
+	// Try to get cover art
+	coverURL, err := m.client.GetFrontCoverURL(ctx, release.ID)
+	if err != nil {
+		// Cover art is optional, continue without it
+		coverURL = ""
+	}
+
+	return &Result{
+		Metadata:   musicbrainz.ToPlaylistMetadataWithCover(release, coverURL),
+		Confidence: confidence,
+		ID:         release.ID,
+	}, nil
+}
+
+// searchRelease resolves the query to a release group and picks an edition
+// from it. If no release group matches, it falls back to a release search.
+func (m *MusicBrainz) searchRelease(ctx context.Context, req Request) (*musicbrainz.Release, error) {
+	groups, err := m.client.AutoSearchReleaseGroups(ctx, req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("search release groups: %w", err)
+	}
+	if len(groups.ReleaseGroups) > 0 {
+		return m.releaseFromGroup(ctx, groups.ReleaseGroups[0].ID, req)
+	}
+
+	results, err := m.client.AutoSearch(ctx, req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("search releases: %w", err)
+	}
+	if len(results.Releases) == 0 {
+		return nil, fmt.Errorf("no releases found for query: %s", req.Query)
+	}
+
+	return m.chooseRelease(ctx, results.Releases, req)
+}
+
+// releaseFromGroup fetches a release group and picks one of its releases.
+func (m *MusicBrainz) releaseFromGroup(ctx context.Context, groupID string, req Request) (*musicbrainz.Release, error) {
+	group, err := m.client.GetReleaseGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch release group: %w", err)
+	}
+	if len(group.Releases) == 0 {
+		return nil, fmt.Errorf("release group %s has no releases", groupID)
+	}
+
+	fmt.Fprintf(req.stdout(), "📀 Release group: %s - %s (%d releases", musicbrainz.GetArtistName(group.ArtistCredit), group.Title, len(group.Releases))
+	if group.FirstReleaseDate != "" {
+		fmt.Fprintf(req.stdout(), ", first released %s", group.FirstReleaseDate)
+	}
+	fmt.Fprintf(req.stdout(), ")\n")
+
+	return m.chooseRelease(ctx, group.Releases, req)
+}
+
+// chooseRelease picks one of the candidates, either by asking the user or by
+// applying the edition policy, and returns its full details.
+func (m *MusicBrainz) chooseRelease(ctx context.Context, candidates []musicbrainz.Release, req Request) (*musicbrainz.Release, error) {
+	var err error
+	target := musicbrainz.Target{TrackCount: req.TrackCount, TotalDuration: req.Duration}
+	ranking := m.Picker != nil || req.Edition.Policy == "" || req.Edition.Policy == musicbrainz.EditionBest
+
+	// Search results carry no track lengths, so look up the top candidates
+	// in full when there is a playlist duration to compare against.
+	details := map[string]*musicbrainz.Release{}
+	if ranking && target.TotalDuration > 0 {
+		for i := 0; i < len(candidates) && i < m.Prefs.Lookups; i++ {
+			full, err := m.client.GetReleaseByID(ctx, candidates[i].ID)
+			if err != nil {
+				continue
+			}
+			full.Score = candidates[i].Score
+			details[full.ID] = full
+			candidates[i] = *full
+		}
+	}
+
+	var chosen *musicbrainz.Release
+	if m.Picker != nil {
+		ranked := musicbrainz.RankReleases(candidates, target, m.Prefs)
+		ordered := make([]musicbrainz.Release, len(ranked))
+		for i, r := range ranked {
+			ordered[i] = r.Release
+		}
+		chosen, err = m.Picker.ChooseRelease(ordered)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		best, err := musicbrainz.SelectEdition(candidates, req.Edition, target, m.Prefs)
+		if err != nil {
+			fmt.Fprintf(req.stderr(), "⚠️  Edition %s not available: %v; ranking releases instead\n", req.Edition, err)
+			best, err = musicbrainz.SelectEdition(candidates, musicbrainz.Edition{Policy: musicbrainz.EditionBest}, target, m.Prefs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		chosen = &best.Release
+		fmt.Fprintf(req.stdout(), "🏆 Chose %s (%s)\n", musicbrainz.Summarize(best.Release), best.Release.ID)
+		if len(best.Reasons) > 0 {
+			fmt.Fprintf(req.stdout(), "   because: %s\n", strings.Join(best.Reasons, ", "))
+		}
+	}
+
+	if full, ok := details[chosen.ID]; ok {
+		return full, nil
+	}
+
+	// Get full release details for the chosen result
+	release, err := m.client.GetReleaseByID(ctx, chosen.ID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch release details: %w", err)
+	}
+	return release, nil
+}
@@ -0,0 +1,471 @@
+// Package provider looks up album metadata from several sources and merges
+// their answers field by field, together with the tags the user gave on the
+// command line and in the batch configuration.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+)
+
+// Names of the built-in providers, as used in precedence lists.
+const (
+	NameMusicBrainz = "musicbrainz"
+	NameDiscogs     = "discogs"
+	NameGenres      = "genres"
+	NameYouTube     = "youtube"
+	// NameCLI stands for the tags given in Request.Flags and Request.Cover.
+	NameCLI = "cli"
+	// NameConfig stands for the metadata given in Request.Known.
+	NameConfig = "config"
+)
+
+// ErrNotApplicable is returned by a provider that has nothing to look up for
+// a request, e.g. MusicBrainz without an ID or query. The chain skips it
+// without counting it as a failure, and returns it itself when no provider
+// applied.
+var ErrNotApplicable = errors.New("not applicable")
+
+// Request describes the album whose metadata is looked up.
+type Request struct {
+	ReleaseID        string              // MusicBrainz release MBID
+	ReleaseGroupID   string              // MusicBrainz release group MBID
+	DiscogsReleaseID int                 // Discogs release ID
+	DiscogsMasterID  int                 // Discogs master ID
+	Query            string              // "Artist - Album" search query
+	Artist           string              // Album artist, if known
+	Album            string              // Album title, if known
+	URL              string              // Source playlist or video, if known
+	Edition          musicbrainz.Edition // Which edition to pick out of a release group
+	TrackCount       int                 // Tracks of the source, used to rank candidates (0 if unknown)
+	Duration         time.Duration       // Total length of the source (0 if unknown)
+	// Known is metadata the user already gave, e.g. in the batch config. It
+	// takes part in the merge as the "config" layer, which ranks above every
+	// provider unless a precedence says otherwise. Its tracks override the
+	// tags of the tracks with the same position.
+	Known *downloader.PlaylistMetadata
+	// Flags are the tags given on the command line, the "cli" layer, which
+	// ranks above the config. Title, composer and comment apply to every
+	// track; the track number is left to the track list.
+	Flags downloader.Metadata
+	Cover string // Cover given on the command line
+	// Log receives what providers report about their choices, and their
+	// warnings. Without it they go to standard output and error.
+	Log io.Writer
+}
+
+// stdout returns where a provider reports its choices.
+func (r Request) stdout() io.Writer {
+	if r.Log != nil {
+		return r.Log
+	}
+	return os.Stdout
+}
+
+// stderr returns where a provider reports warnings.
+func (r Request) stderr() io.Writer {
+	if r.Log != nil {
+		return r.Log
+	}
+	return os.Stderr
+}
+
+// Result is the answer of one provider.
+type Result struct {
+	Metadata *downloader.PlaylistMetadata
+	// Confidence ranges from 0 (a guess) to 1 (an exact ID match). Fields
+	// without an explicit precedence come from the most confident provider.
+	Confidence float64
+	// ID identifies the match in the provider, e.g. a MusicBrainz release ID.
+	ID string
+}
+
+// Provider is a source of album metadata.
+type Provider interface {
+	Name() string
+	Fetch(ctx context.Context, req Request) (*Result, error)
+}
+
+// Field is an album-level metadata field that can be taken from a different
+// provider than the rest.
+type Field string
+
+// Fields merged by a Chain. FieldTracks is the track list itself, which is
+// taken from a single provider so positions and durations stay together; the
+// track fields are then merged per track, matched by position.
+const (
+	FieldTitle       Field = "title"
+	FieldArtist      Field = "artist"
+	FieldAlbumArtist Field = "album_artist"
+	FieldYear        Field = "year"
+	FieldGenre       Field = "genre"
+	FieldLabel       Field = "label"
+	FieldCatalog     Field = "catalog"
+	FieldCountry     Field = "country"
+	FieldCover       Field = "cover"
+	FieldComment     Field = "comment"
+	FieldTracks      Field = "tracks"
+
+	FieldTrackTitle   Field = "track_title"
+	FieldTrackArtist  Field = "track_artist"
+	FieldComposer     Field = "composer"
+	FieldTrackComment Field = "track_comment"
+)
+
+// Fields lists every field in the order they are reported.
+var Fields = slices.Concat(albumFields, trackFields)
+
+// albumFields are merged once per album, trackFields once per track.
+var (
+	albumFields = []Field{
+		FieldTitle, FieldArtist, FieldAlbumArtist, FieldYear, FieldGenre, FieldLabel,
+		FieldCatalog, FieldCountry, FieldCover, FieldComment, FieldTracks,
+	}
+	trackFields = []Field{FieldTrackTitle, FieldTrackArtist, FieldComposer, FieldTrackComment}
+)
+
+// ParseField validates a field name.
+func ParseField(name string) (Field, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, f := range Fields {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown metadata field %q", name)
+}
+
+// Merged is the combined answer of a chain.
+type Merged struct {
+	Metadata *downloader.PlaylistMetadata
+	Sources  map[Field]string // Layer that supplied each album field that is set
+	// TrackSources holds, per track of Metadata.Tracks, the layer that
+	// supplied each track field that is set.
+	TrackSources []map[Field]string
+	Results      map[string]*Result // Answer of each provider and user layer that has something
+	Errors       map[string]error   // Failure of each provider that found nothing
+}
+
+// Found reports whether a provider found the album, as opposed to only the
+// user's own tags being known.
+func (m *Merged) Found() bool {
+	for name := range m.Results {
+		if name != NameCLI && name != NameConfig {
+			return true
+		}
+	}
+	return false
+}
+
+// ID returns the ID of the match of the named provider, or "".
+func (m *Merged) ID(name string) string {
+	if result := m.Results[name]; result != nil {
+		return result.ID
+	}
+	return ""
+}
+
+// Chain runs providers in order and merges their results.
+type Chain struct {
+	providers  []Provider
+	precedence map[Field][]string
+}
+
+// NewChain creates a chain of providers. Providers run in the given order;
+// each sees the artist and album found by the ones before it.
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{
+		providers:  providers,
+		precedence: map[Field][]string{},
+	}
+}
+
+// Prefer sets which providers a field is taken from, most preferred first.
+// The others follow in the default order: the cli layer, the config layer,
+// then providers by confidence. The track list itself prefers providers to
+// the config.
+func (c *Chain) Prefer(field Field, providers ...string) {
+	c.precedence[field] = providers
+}
+
+// Fetch asks every provider and merges their answers with the user's tags.
+// It fails only if neither a provider nor the user has anything, with
+// ErrNotApplicable when no provider applied; Merged.Errors lists the
+// providers that failed.
+func (c *Chain) Fetch(ctx context.Context, req Request) (*Merged, error) {
+	merged := &Merged{
+		Sources: map[Field]string{},
+		Results: map[string]*Result{},
+		Errors:  map[string]error{},
+	}
+
+	var order []string
+	for _, p := range c.providers {
+		result, err := p.Fetch(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !errors.Is(err, ErrNotApplicable) {
+				merged.Errors[p.Name()] = err
+			}
+			continue
+		}
+		if result == nil || result.Metadata == nil {
+			continue
+		}
+		merged.Results[p.Name()] = result
+		order = append(order, p.Name())
+
+		if req.Artist == "" {
+			req.Artist = result.Metadata.AlbumInfo.Artist
+		}
+		if req.Album == "" {
+			req.Album = result.Metadata.AlbumInfo.Title
+		}
+	}
+
+	if req.Known != nil {
+		merged.Results[NameConfig] = &Result{Metadata: req.Known}
+		order = append(order, NameConfig)
+	}
+	if flags := flagLayer(req.Flags, req.Cover); flags != nil {
+		merged.Results[NameCLI] = &Result{Metadata: flags}
+		order = append(order, NameCLI)
+	}
+	if len(order) == 0 {
+		return nil, merged.Err()
+	}
+
+	merged.Metadata = &downloader.PlaylistMetadata{}
+	for _, field := range albumFields {
+		for _, name := range c.candidates(field, order, merged.Results) {
+			if take(merged.Metadata, merged.Results[name].Metadata, field) {
+				merged.Sources[field] = name
+				break
+			}
+		}
+	}
+
+	for i := range merged.Metadata.Tracks {
+		track := &merged.Metadata.Tracks[i]
+		position := track.Position
+		if position <= 0 {
+			position = i + 1
+		}
+		sources := map[Field]string{}
+		for _, field := range trackFields {
+			for _, name := range c.candidates(field, order, merged.Results) {
+				if takeTrack(track, trackAt(merged.Results[name].Metadata, name, position), field) {
+					sources[field] = name
+					break
+				}
+			}
+		}
+		merged.TrackSources = append(merged.TrackSources, sources)
+	}
+	return merged, nil
+}
+
+// flagLayer turns command line tags into metadata, with the per-track tags
+// on a single track that trackAt hands out for every position. It returns
+// nil when no tag was given.
+func flagLayer(flags downloader.Metadata, cover string) *downloader.PlaylistMetadata {
+	if flags == (downloader.Metadata{Track: flags.Track}) && cover == "" {
+		return nil
+	}
+	pm := &downloader.PlaylistMetadata{
+		AlbumInfo: downloader.AlbumMetadata{
+			Title:       flags.Album,
+			Artist:      flags.Artist,
+			AlbumArtist: flags.AlbumArtist,
+			Year:        flags.Year,
+			Genre:       flags.Genre,
+			Comment:     flags.Comment,
+		},
+	}
+	if strings.Contains(cover, "://") {
+		pm.AlbumInfo.CoverURL = cover
+	} else {
+		pm.AlbumInfo.CoverPath = cover
+	}
+	if flags.Title != "" || flags.Composer != "" || flags.Comment != "" {
+		pm.Tracks = []downloader.TrackMetadata{{Title: flags.Title, Composer: flags.Composer, Comment: flags.Comment}}
+	}
+	return pm
+}
+
+// candidates orders the layers that answered for one field: the ones named
+// in its precedence first, then the rest in the default order. The cli
+// layer never supplies the track list, only tags for every track.
+func (c *Chain) candidates(field Field, order []string, results map[string]*Result) []string {
+	var names []string
+	listed := map[string]bool{}
+	if field == FieldTracks {
+		listed[NameCLI] = true
+	}
+	for _, name := range c.precedence[field] {
+		if listed[name] {
+			continue
+		}
+		listed[name] = true
+		if results[name] != nil {
+			names = append(names, name)
+		}
+	}
+
+	var rest []string
+	for _, name := range order {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		return rank(field, rest[i], results) > rank(field, rest[j], results)
+	})
+	return append(names, rest...)
+}
+
+// rank orders layers by default: the user's own tags above every provider,
+// except for the track list, where the config's tracks only override tags.
+func rank(field Field, name string, results map[string]*Result) float64 {
+	switch {
+	case name == NameCLI:
+		return 3
+	case name == NameConfig && field == FieldTracks:
+		return -1
+	case name == NameConfig:
+		return 2
+	}
+	return results[name].Confidence
+}
+
+// Err summarizes why no provider found anything: ErrNotApplicable when none
+// applied, otherwise the failures.
+func (m *Merged) Err() error {
+	if len(m.Errors) == 0 {
+		return ErrNotApplicable
+	}
+	names := make([]string, 0, len(m.Errors))
+	for name := range m.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 1 {
+		return m.Errors[names[0]]
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %v", name, m.Errors[name])
+	}
+	return errors.New(strings.Join(parts, "; "))
+}
+
+// trackAt returns the track of a layer at position: the track numbered so,
+// or the track at that index when the layer does not number its tracks. The
+// cli layer has one track that applies everywhere.
+func trackAt(pm *downloader.PlaylistMetadata, name string, position int) *downloader.TrackMetadata {
+	if name == NameCLI {
+		if len(pm.Tracks) == 0 {
+			return nil
+		}
+		return &pm.Tracks[0]
+	}
+	for i := range pm.Tracks {
+		if pm.Tracks[i].Position == position {
+			return &pm.Tracks[i]
+		}
+	}
+	if i := position - 1; i < len(pm.Tracks) && pm.Tracks[i].Position <= 0 {
+		return &pm.Tracks[i]
+	}
+	return nil
+}
+
+// takeTrack copies a track field from src into dst if src has it, and
+// reports whether it did.
+func takeTrack(dst, src *downloader.TrackMetadata, field Field) bool {
+	if src == nil {
+		return false
+	}
+	var d *string
+	var s string
+	switch field {
+	case FieldTrackTitle:
+		d, s = &dst.Title, src.Title
+	case FieldTrackArtist:
+		d, s = &dst.Artist, src.Artist
+	case FieldComposer:
+		d, s = &dst.Composer, src.Composer
+	case FieldTrackComment:
+		d, s = &dst.Comment, src.Comment
+	default:
+		return false
+	}
+	if strings.TrimSpace(s) == "" {
+		return false
+	}
+	*d = s
+	return true
+}
+
+// take copies field from src into dst if src has it, and reports whether it did.
+func take(dst, src *downloader.PlaylistMetadata, field Field) bool {
+	str := func(d *string, s string) bool {
+		if strings.TrimSpace(s) == "" {
+			return false
+		}
+		*d = s
+		return true
+	}
+
+	a, b := &dst.AlbumInfo, src.AlbumInfo
+	switch field {
+	case FieldTitle:
+		return str(&a.Title, b.Title)
+	case FieldArtist:
+		return str(&a.Artist, b.Artist)
+	case FieldAlbumArtist:
+		return str(&a.AlbumArtist, b.AlbumArtist)
+	case FieldYear:
+		return str(&a.Year, b.Year)
+	case FieldGenre:
+		return str(&a.Genre, b.Genre)
+	case FieldLabel:
+		return str(&a.Label, b.Label)
+	case FieldCatalog:
+		return str(&a.CatalogNum, b.CatalogNum)
+	case FieldCountry:
+		return str(&a.Country, b.Country)
+	case FieldCover:
+		if b.CoverURL == "" && b.CoverPath == "" {
+			return false
+		}
+		a.CoverURL, a.CoverPath = b.CoverURL, b.CoverPath
+		return true
+	case FieldComment:
+		return str(&a.Comment, b.Comment)
+	case FieldTracks:
+		if len(src.Tracks) == 0 {
+			return false
+		}
+		dst.Tracks = append([]downloader.TrackMetadata(nil), src.Tracks...)
+		a.TotalTracks = b.TotalTracks
+		if a.TotalTracks == 0 {
+			a.TotalTracks = len(src.Tracks)
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"iturtle-smart-fetcher/internal/discogs"
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+)
+
+func TestChainPrecedence(t *testing.T) {
+	mb := &fakeProvider{name: NameMusicBrainz, confidence: 0.8, pm: album("Partie Traumatic", "Black Kids", "2008", "", 11)}
+	yt := &fakeProvider{name: NameYouTube, confidence: 0.4, pm: album("Partie Traumatic (Deluxe)", "Black Kids - Topic", "", "", 14)}
+	genres := &fakeProvider{name: NameGenres, confidence: 1, pm: album("", "", "", "Indie Pop", 0)}
+
+	chain := NewChain(mb, yt, genres)
+	chain.Prefer(FieldTitle, NameYouTube)
+
+	merged, err := chain.Fetch(context.Background(), Request{Query: "Black Kids - Partie Traumatic"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info := merged.Metadata.AlbumInfo
+	if info.Title != "Partie Traumatic (Deluxe)" || merged.Sources[FieldTitle] != NameYouTube {
+		t.Errorf("expected the title from youtube, got %q from %s", info.Title, merged.Sources[FieldTitle])
+	}
+	if info.Year != "2008" || merged.Sources[FieldYear] != NameMusicBrainz {
+		t.Errorf("expected the year from musicbrainz, got %q from %s", info.Year, merged.Sources[FieldYear])
+	}
+	if info.Genre != "Indie Pop" || merged.Sources[FieldGenre] != NameGenres {
+		t.Errorf("expected the genre from genres, got %q from %s", info.Genre, merged.Sources[FieldGenre])
+	}
+	if len(merged.Metadata.Tracks) != 11 || info.TotalTracks != 11 || merged.Sources[FieldTracks] != NameMusicBrainz {
+		t.Errorf("expected the more confident track list, got %d tracks from %s", len(merged.Metadata.Tracks), merged.Sources[FieldTracks])
+	}
+	if genres.req.Artist != "Black Kids" {
+		t.Errorf("expected later providers to see the artist found earlier, got %q", genres.req.Artist)
+	}
+}
+
+func TestChainLayers(t *testing.T) {
+	mb := &fakeProvider{name: NameMusicBrainz, confidence: 1, pm: album("Partie Traumatic", "Black Kids", "2008", "Rock", 3)}
+	mb.pm.AlbumInfo.CoverURL = "https://coverartarchive.org/front"
+	mb.pm.Tracks[1].Title = "Partie Traumatic"
+	mb.pm.Tracks[1].Composer = "Reggie Youngblood"
+	known := album("", "", "", "Synth Pop", 0)
+	known.Tracks = []downloader.TrackMetadata{{Position: 2, Title: "Partie Traumatic (Album Version)"}}
+	req := Request{
+		Known: known,
+		Flags: downloader.Metadata{Genre: "Indie Pop", Comment: "ripped"},
+		Cover: "cover.jpg",
+	}
+
+	chain := NewChain(mb)
+	merged, err := chain.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info := merged.Metadata.AlbumInfo
+	if info.Genre != "Indie Pop" || merged.Sources[FieldGenre] != NameCLI {
+		t.Errorf("expected the flag genre to win, got %q from %s", info.Genre, merged.Sources[FieldGenre])
+	}
+	if info.CoverPath != "cover.jpg" || info.CoverURL != "" || merged.Sources[FieldCover] != NameCLI {
+		t.Errorf("expected the flag cover to win, got %+v from %s", info, merged.Sources[FieldCover])
+	}
+	if info.Year != "2008" || merged.Sources[FieldYear] != NameMusicBrainz {
+		t.Errorf("expected the year from musicbrainz, got %q from %s", info.Year, merged.Sources[FieldYear])
+	}
+	if len(merged.Metadata.Tracks) != 3 || merged.Sources[FieldTracks] != NameMusicBrainz {
+		t.Fatalf("expected the provider's track list, got %d tracks from %s", len(merged.Metadata.Tracks), merged.Sources[FieldTracks])
+	}
+
+	track, sources := merged.Metadata.Tracks[1], merged.TrackSources[1]
+	if track.Title != "Partie Traumatic (Album Version)" || sources[FieldTrackTitle] != NameConfig {
+		t.Errorf("expected the config track title to win, got %q from %s", track.Title, sources[FieldTrackTitle])
+	}
+	if track.Composer != "Reggie Youngblood" || sources[FieldComposer] != NameMusicBrainz {
+		t.Errorf("expected the composer from musicbrainz, got %q from %s", track.Composer, sources[FieldComposer])
+	}
+	for i, track := range merged.Metadata.Tracks {
+		if track.Comment != "ripped" || merged.TrackSources[i][FieldTrackComment] != NameCLI {
+			t.Errorf("track %d: expected the flag comment on every track, got %q", i+1, track.Comment)
+		}
+	}
+
+	chain.Prefer(FieldGenre, NameMusicBrainz)
+	chain.Prefer(FieldTrackTitle, NameMusicBrainz)
+	merged, err = chain.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.Metadata.AlbumInfo.Genre != "Rock" {
+		t.Errorf("expected the musicbrainz genre by precedence, got %q", merged.Metadata.AlbumInfo.Genre)
+	}
+	if merged.Metadata.Tracks[1].Title != "Partie Traumatic" {
+		t.Errorf("expected the musicbrainz track title by precedence, got %q", merged.Metadata.Tracks[1].Title)
+	}
+}
+
+func TestChainWithoutProviders(t *testing.T) {
+	merged, err := NewChain().Fetch(context.Background(), Request{Known: album("Partie Traumatic", "Black Kids", "", "", 2)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.Found() || len(merged.Metadata.Tracks) != 2 || merged.Sources[FieldTracks] != NameConfig {
+		t.Errorf("expected the config alone, got %+v", merged)
+	}
+	if _, err := NewChain().Fetch(context.Background(), Request{Flags: downloader.Metadata{Track: "3"}}); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable without any tags, got %v", err)
+	}
+}
+
+func TestChainErrors(t *testing.T) {
+	failing := &fakeProvider{name: NameMusicBrainz, err: errors.New("boom")}
+	skipping := &fakeProvider{name: NameGenres, err: ErrNotApplicable}
+
+	if _, err := NewChain(skipping).Fetch(context.Background(), Request{}); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable, got %v", err)
+	}
+	if _, err := NewChain(failing, skipping).Fetch(context.Background(), Request{}); err == nil || err.Error() != "boom" {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+
+	working := &fakeProvider{name: NameYouTube, confidence: 0.4, pm: album("Album", "", "", "", 1)}
+	merged, err := NewChain(failing, working).Fetch(context.Background(), Request{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.Errors[NameMusicBrainz] == nil {
+		t.Errorf("expected the failure to be reported, got %v", merged.Errors)
+	}
+}
+
+func TestParseField(t *testing.T) {
+	if f, err := ParseField(" Year "); err != nil || f != FieldYear {
+		t.Errorf("expected year, got %q, %v", f, err)
+	}
+	if _, err := ParseField("mood"); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestGenres(t *testing.T) {
+	g := NewGenres(map[string]string{"Black Kids": "Indie Pop"})
+
+	result, err := g.Fetch(context.Background(), Request{Query: "black kids - Partie Traumatic"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Metadata.AlbumInfo.Genre != "Indie Pop" {
+		t.Errorf("expected Indie Pop, got %q", result.Metadata.AlbumInfo.Genre)
+	}
+
+	if _, err := g.Fetch(context.Background(), Request{Artist: "Someone Else"}); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable, got %v", err)
+	}
+}
+
+func TestParseYouTubeOutput(t *testing.T) {
+	output := "WARNING: something\n" +
+		"Album - Partie Traumatic\tBlack Kids - Topic\t1\t204.0\tHit the Heartbrakes\n" +
+		"Album - Partie Traumatic\tBlack Kids - Topic\t2\tNA\tPartie Traumatic\n"
+
+	pm := parseYouTubeOutput(output)
+	if pm == nil {
+		t.Fatal("expected metadata")
+	}
+	if pm.AlbumInfo.Title != "Partie Traumatic" || pm.AlbumInfo.Artist != "Black Kids" || pm.AlbumInfo.TotalTracks != 2 {
+		t.Errorf("unexpected album info %+v", pm.AlbumInfo)
+	}
+	if pm.Tracks[0].Duration != "3:24" || pm.Tracks[1].Position != 2 || pm.Tracks[1].Duration != "" {
+		t.Errorf("unexpected tracks %+v", pm.Tracks)
+	}
+
+	if parseYouTubeOutput("ERROR: private video\n") != nil {
+		t.Error("expected nil without entries")
+	}
+}
+
+func TestMusicBrainzByID(t *testing.T) {
+	release := musicbrainz.Release{
+		ID:           "release-1",
+		Title:        "Partie Traumatic",
+		Date:         "2008-07-07",
+		ArtistCredit: []musicbrainz.ArtistCredit{{Name: "Black Kids"}},
+		Media: []musicbrainz.Medium{{Position: 1, Tracks: []musicbrainz.Track{
+			{Position: 1, Title: "Hit the Heartbrakes", Length: 204000},
+		}}},
+	}
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if !strings.Contains(r.URL.Path, "/release/release-1") || r.URL.Host == "coverartarchive.org" {
+				return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+			}
+			body, _ := json.Marshal(release)
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(string(body))), Header: http.Header{}}, nil
+		}),
+	}
+	mb := NewMusicBrainz(musicbrainz.NewClient(client, musicbrainz.WithRateLimit("", 0), musicbrainz.WithRateLimit("coverartarchive.org", 0)))
+
+	if _, err := mb.Fetch(context.Background(), Request{}); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable without an ID or query, got %v", err)
+	}
+
+	result, err := mb.Fetch(context.Background(), Request{ReleaseID: "release-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != "release-1" || result.Confidence != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.Metadata.AlbumInfo.Title != "Partie Traumatic" || len(result.Metadata.Tracks) != 1 {
+		t.Errorf("unexpected metadata %+v", result.Metadata)
+	}
+}
+
+func TestDiscogsMasterFallsBack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/masters/99":
+			w.Write([]byte(`{"id": 99, "title": "Wizard of Ahhhs", "year": 2007, "main_release": 1234,
+				"artists": [{"name": "Black Kids"}], "tracklist": [{"position": "A1", "title": "Hit the Heartbrakes"}]}`))
+		case "/masters/100":
+			w.Write([]byte(`{"id": 100, "title": "Partie Traumatic", "main_release": 5678}`))
+		case "/releases/5678":
+			w.Write([]byte(`{"id": 5678, "title": "Partie Traumatic", "labels": [{"name": "Almost Gold", "catno": "AG-002"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	dc := NewDiscogs(discogs.NewClient(nil, discogs.WithBaseURL(server.URL), discogs.WithRateLimit(0)))
+
+	if _, err := dc.Fetch(context.Background(), Request{Query: "Black Kids - Partie Traumatic"}); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable when searching without a token, got %v", err)
+	}
+
+	// Only an explicit query is searched for, not an album found elsewhere
+	searching := NewDiscogs(discogs.NewClient(nil, discogs.WithBaseURL(server.URL), discogs.WithRateLimit(0), discogs.WithToken("token")))
+	if _, err := searching.Fetch(context.Background(), Request{ReleaseID: "release-1", Artist: "Black Kids", Album: "Partie Traumatic"}); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable without a Discogs ID or query, got %v", err)
+	}
+
+	result, err := dc.Fetch(context.Background(), Request{DiscogsMasterID: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != "5678" || result.Confidence != 0.9 || result.Metadata.AlbumInfo.CatalogNum != "AG-002" {
+		t.Errorf("expected the main release, got %+v", result)
+	}
+
+	result, err = dc.Fetch(context.Background(), Request{DiscogsMasterID: 99})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ID != "" || result.Metadata.AlbumInfo.Title != "Wizard of Ahhhs" || len(result.Metadata.Tracks) != 1 {
+		t.Errorf("expected the master when its main release is missing, got %+v", result)
+	}
+}
+
+// album builds album metadata with count placeholder tracks.
+func album(title, artist, year, genre string, count int) *downloader.PlaylistMetadata {
+	pm := &downloader.PlaylistMetadata{AlbumInfo: downloader.AlbumMetadata{
+		Title: title, Artist: artist, Year: year, Genre: genre,
+	}}
+	for i := 1; i <= count; i++ {
+		pm.Tracks = append(pm.Tracks, downloader.TrackMetadata{Position: i})
+	}
+	return pm
+}
+
+type fakeProvider struct {
+	name       string
+	confidence float64
+	pm         *downloader.PlaylistMetadata
+	err        error
+	req        Request
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) Fetch(ctx context.Context, req Request) (*Result, error) {
+	f.req = req
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Result{Metadata: f.pm, Confidence: f.confidence}, nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"iturtle-smart-fetcher/internal/downloader"
+	"iturtle-smart-fetcher/internal/musicbrainz"
+)
+
+// youTubeTemplate is printed by yt-dlp once per playlist entry.
+const youTubeTemplate = "%(playlist_title|)s\t%(playlist_uploader|)s\t%(playlist_index|0)s\t%(duration|0)s\t%(title)s"
+
+// YouTube reads the album title, artist and track titles from the source
+// playlist through yt-dlp. It only knows what the uploader typed, so its
+// confidence is low and it mostly fills fields other providers lack.
+type YouTube struct {
+	runner    downloader.Runner
+	ytDLPPath string
+}
+
+// NewYouTube creates a YouTube provider running yt-dlp at ytDLPPath (or from PATH).
+func NewYouTube(r downloader.Runner, ytDLPPath string) *YouTube {
+	if r == nil {
+		r = downloader.ExecRunner{}
+	}
+	return &YouTube{runner: r, ytDLPPath: ytDLPPath}
+}
+
+// Name returns "youtube".
+func (y *YouTube) Name() string {
+	return NameYouTube
+}
+
+// Fetch probes the request's URL.
+func (y *YouTube) Fetch(ctx context.Context, req Request) (*Result, error) {
+	if req.URL == "" {
+		return nil, ErrNotApplicable
+	}
+
+	ytCmd := strings.TrimSpace(y.ytDLPPath)
+	if ytCmd == "" {
+		ytCmd = "yt-dlp"
+	}
+	output, err := y.runner.Run(ctx, ytCmd,
+		"--flat-playlist",
+		"--ignore-errors",
+		"--print", youTubeTemplate,
+		req.URL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pm := parseYouTubeOutput(output)
+	if pm == nil {
+		return nil, ErrNotApplicable
+	}
+	return &Result{Metadata: pm, Confidence: 0.4}, nil
+}
+
+// parseYouTubeOutput builds metadata from yt-dlp output, or returns nil if
+// the output has no entries.
+func parseYouTubeOutput(output string) *downloader.PlaylistMetadata {
+	var pm *downloader.PlaylistMetadata
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		if pm == nil {
+			pm = &downloader.PlaylistMetadata{}
+			// YouTube Music names album playlists "Album - Title"
+			pm.AlbumInfo.Title = strings.TrimPrefix(known(fields[0]), "Album - ")
+			pm.AlbumInfo.Artist = strings.TrimSuffix(known(fields[1]), " - Topic")
+		}
+
+		track := downloader.TrackMetadata{Position: len(pm.Tracks) + 1, Title: fields[4]}
+		if index, err := strconv.Atoi(fields[2]); err == nil && index > 0 {
+			track.Position = index
+		}
+		if seconds, err := strconv.ParseFloat(fields[3], 64); err == nil && seconds > 0 {
+			track.Duration = musicbrainz.FormatDuration(int(seconds * float64(time.Second/time.Millisecond)))
+		}
+		pm.Tracks = append(pm.Tracks, track)
+	}
+	if pm != nil {
+		pm.AlbumInfo.TotalTracks = len(pm.Tracks)
+	}
+	return pm
+}
+
+// known returns s, or "" for yt-dlp's placeholder for missing fields.
+func known(s string) string {
+	if s == "NA" {
+		return ""
+	}
+	return s
+}
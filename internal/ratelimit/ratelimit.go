@@ -0,0 +1,118 @@
+// Package ratelimit spaces out requests to an API and works out how long to
+// back off when the server throttles them. It is shared by the MusicBrainz
+// and Discogs clients.
+package ratelimit
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBackoff bounds a single backoff or Retry-After wait.
+const MaxBackoff = time.Minute
+
+// Limiter spaces out requests so that at most one starts per interval.
+// It is safe for concurrent use: callers reserve the next free slot under the
+// lock and then wait for it outside of it, so waiting never blocks others
+// from queueing up.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// New creates a Limiter that starts at most one request per interval. An
+// interval of 0 disables limiting.
+func New(interval time.Duration) *Limiter {
+	return &Limiter{interval: max(interval, 0)}
+}
+
+// Interval returns the minimum time between two requests.
+func (l *Limiter) Interval() time.Duration {
+	return l.interval
+}
+
+// Wait blocks until the caller's slot arrives or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(slot))
+}
+
+// Delay holds back every request for at least d, e.g. after the server
+// asked us to slow down with Retry-After.
+func (l *Limiter) Delay(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
+
+// sleepContext waits for d unless ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// IsThrottled reports whether a response asks us to retry later.
+func IsThrottled(status int) bool {
+	return status == http.StatusServiceUnavailable || status == http.StatusTooManyRequests
+}
+
+// RetryDelay returns how long to wait before retry number attempt (0-based).
+// A Retry-After header wins; otherwise the delay doubles on every attempt
+// with up to 50% random jitter so that concurrent clients spread out.
+func RetryDelay(header http.Header, attempt int, base time.Duration) time.Duration {
+	if d, ok := ParseRetryAfter(header.Get("Retry-After")); ok {
+		return min(d, MaxBackoff)
+	}
+	if base <= 0 {
+		return 0
+	}
+
+	d := base << attempt
+	if d <= 0 || d > MaxBackoff {
+		d = MaxBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d += time.Duration(rand.Int64N(half))
+	}
+	return d
+}
+
+// ParseRetryAfter parses a Retry-After value given in seconds or as an HTTP date.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterSpacesConcurrentCallers(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	l := New(interval)
+
+	var mu sync.Mutex
+	var starts []time.Time
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(context.Background()); err != nil {
+				t.Errorf("Wait failed: %v", err)
+				return
+			}
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for i := 1; i < len(starts); i++ {
+		// Allow for timer granularity
+		if gap := starts[i].Sub(starts[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("requests %d and %d only %s apart", i-1, i, gap)
+		}
+	}
+}
+
+func TestLimiterWaitHonorsContext(t *testing.T) {
+	l := New(time.Hour)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait should not block: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("expected context error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait ignored cancellation, took %s", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := ParseRetryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %s (ok=%v)", d, ok)
+	}
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if d, ok := ParseRetryAfter(future); !ok || d <= 0 || d > 10*time.Second {
+		t.Errorf("expected up to 10s from HTTP date, got %s (ok=%v)", d, ok)
+	}
+	if _, ok := ParseRetryAfter("soon"); ok {
+		t.Error("expected invalid value to be rejected")
+	}
+}
+
+func TestRetryDelayBacksOff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 0; attempt < 3; attempt++ {
+		d := RetryDelay(http.Header{}, attempt, base)
+		low := base << attempt
+		if d < low || d > low+low/2 {
+			t.Errorf("attempt %d: delay %s outside [%s, %s]", attempt, d, low, low+low/2)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "2")
+	if d := RetryDelay(header, 5, base); d != 2*time.Second {
+		t.Errorf("expected Retry-After to win, got %s", d)
+	}
+}
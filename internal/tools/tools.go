@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -15,11 +16,12 @@ type Options struct {
 
 // Paths contains resolved executable paths for required tools.
 type Paths struct {
-	YtDLP  string
-	FFmpeg string
+	YtDLP   string
+	FFmpeg  string
+	FFprobe string // Reads tagged files back; comes with ffmpeg
 }
 
-// Manager resolves yt-dlp and ffmpeg paths.
+// Manager resolves yt-dlp, ffmpeg and ffprobe paths.
 type Manager struct{}
 
 // New returns a new Manager.
@@ -28,6 +30,8 @@ func New() *Manager {
 }
 
 // Ensure locates yt-dlp and ffmpeg using explicit paths or system PATH.
+// ffprobe is looked for next to ffmpeg first, so that both come from the
+// same installation.
 func (m *Manager) Ensure(opts Options) (Paths, error) {
 	ytdlp, err := resolveTool("yt-dlp", opts.YtDLPPath)
 	if err != nil {
@@ -39,7 +43,20 @@ func (m *Manager) Ensure(opts Options) (Paths, error) {
 		return Paths{}, err
 	}
 
-	return Paths{YtDLP: ytdlp, FFmpeg: ffmpeg}, nil
+	ffprobe := siblingTool(ffmpeg, "ffprobe")
+	if !isExecutable(ffprobe) {
+		if ffprobe, err = resolveTool("ffprobe", ""); err != nil {
+			return Paths{}, fmt.Errorf("ffprobe not found next to %s or on PATH. It comes with ffmpeg", ffmpeg)
+		}
+	}
+
+	return Paths{YtDLP: ytdlp, FFmpeg: ffmpeg, FFprobe: ffprobe}, nil
+}
+
+// siblingTool returns the path of the tool name in the directory of path,
+// with the same extension, e.g. ffprobe.exe for ffmpeg.exe.
+func siblingTool(path, name string) string {
+	return filepath.Join(filepath.Dir(path), name+filepath.Ext(path))
 }
 
 // resolveTool finds a tool binary using the explicit path or system PATH.
@@ -12,12 +12,12 @@ func TestEnsureUsesOverrides(t *testing.T) {
 	// Create fake binaries
 	ytdlpPath := filepath.Join(tmpDir, "yt-dlp")
 	ffmpegPath := filepath.Join(tmpDir, "ffmpeg")
+	ffprobePath := filepath.Join(tmpDir, "ffprobe")
 
-	if err := os.WriteFile(ytdlpPath, []byte("fake"), 0755); err != nil {
-		t.Fatal(err)
-	}
-	if err := os.WriteFile(ffmpegPath, []byte("fake"), 0755); err != nil {
-		t.Fatal(err)
+	for _, path := range []string{ytdlpPath, ffmpegPath, ffprobePath} {
+		if err := os.WriteFile(path, []byte("fake"), 0755); err != nil {
+			t.Fatal(err)
+		}
 	}
 
 	m := New()
@@ -35,6 +35,9 @@ func TestEnsureUsesOverrides(t *testing.T) {
 	if paths.FFmpeg != ffmpegPath {
 		t.Errorf("expected ffmpeg path %s, got %s", ffmpegPath, paths.FFmpeg)
 	}
+	if paths.FFprobe != ffprobePath {
+		t.Errorf("expected the ffprobe next to ffmpeg %s, got %s", ffprobePath, paths.FFprobe)
+	}
 }
 
 func TestEnsureFailsWithInvalidPaths(t *testing.T) {
@@ -0,0 +1,150 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// albumPlaylistPrefix starts the ID of every official YouTube Music album playlist.
+const albumPlaylistPrefix = "OLAK5uy_"
+
+// Templates printed by yt-dlp while searching and probing album playlists.
+const (
+	albumSearchTemplate = "%(url)s\t%(title)s"
+	albumProbeTemplate  = "%(playlist_id)s\t%(playlist_count|0)s\t%(playlist_title)s\t%(playlist_uploader|)s"
+)
+
+// AlbumPlaylist is an official YouTube Music album playlist.
+type AlbumPlaylist struct {
+	ID        string // Playlist ID, starting with OLAK5uy_
+	Title     string
+	Channel   string
+	ItemCount int
+}
+
+// URL returns the playlist URL.
+func (p AlbumPlaylist) URL() string {
+	return "https://music.youtube.com/playlist?list=" + p.ID
+}
+
+// IsAlbumPlaylistID reports whether id is an official album playlist.
+func IsAlbumPlaylistID(id string) bool {
+	return strings.HasPrefix(id, albumPlaylistPrefix)
+}
+
+// FindAlbumPlaylists searches YouTube Music albums for "artist album" and
+// returns the official album playlists among the top results with their item
+// counts. Every result costs one extra yt-dlp call to count its items.
+func (s *Searcher) FindAlbumPlaylists(ctx context.Context, artist, album string) ([]AlbumPlaylist, error) {
+	n := s.Results
+	if n <= 0 {
+		n = DefaultResults
+	}
+
+	searchURL := "https://music.youtube.com/search?q=" + url.QueryEscape(strings.TrimSpace(artist+" "+album)) + "#albums"
+	output, err := s.runner.Run(ctx, s.ytDLP(),
+		"--flat-playlist",
+		"--ignore-errors",
+		"--playlist-end", strconv.Itoa(n),
+		"--print", albumSearchTemplate,
+		searchURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var playlists []AlbumPlaylist
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		resultURL, _, found := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+		if !found || !strings.HasPrefix(resultURL, "http") {
+			continue
+		}
+
+		playlist, err := s.probeAlbumPlaylist(ctx, resultURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if playlist == nil || seen[playlist.ID] {
+			continue
+		}
+		seen[playlist.ID] = true
+		playlists = append(playlists, *playlist)
+	}
+	return playlists, nil
+}
+
+// probeAlbumPlaylist resolves a search result (an album page or a playlist)
+// to its album playlist and counts the items. It returns nil if the result
+// is not an official album playlist.
+func (s *Searcher) probeAlbumPlaylist(ctx context.Context, resultURL string) (*AlbumPlaylist, error) {
+	output, err := s.runner.Run(ctx, s.ytDLP(),
+		"--flat-playlist",
+		"--ignore-errors",
+		"--print", albumProbeTemplate,
+		resultURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var playlist *AlbumPlaylist
+	items := 0
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 4)
+		if len(fields) != 4 || !IsAlbumPlaylistID(fields[0]) {
+			continue
+		}
+		items++
+		if playlist == nil {
+			count, _ := strconv.Atoi(fields[1])
+			playlist = &AlbumPlaylist{ID: fields[0], Title: fields[2], Channel: fields[3], ItemCount: count}
+			if playlist.Channel == "NA" {
+				playlist.Channel = ""
+			}
+		}
+	}
+	if playlist != nil && items > playlist.ItemCount {
+		// Older yt-dlp versions do not report playlist_count in flat mode
+		playlist.ItemCount = items
+	}
+	return playlist, nil
+}
+
+// PickAlbumPlaylist returns the playlist whose item count equals trackCount,
+// preferring the one whose title is closest to the album title when several
+// match. It fails with ErrNoMatch if no playlist has the right item count.
+func PickAlbumPlaylist(playlists []AlbumPlaylist, artist, album string, trackCount int) (*AlbumPlaylist, error) {
+	var matching []AlbumPlaylist
+	for _, p := range playlists {
+		if p.ItemCount == trackCount {
+			matching = append(matching, p)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w: no album playlist has %d items", ErrNoMatch, trackCount)
+	}
+
+	target := Track{Artist: artist, Title: album}
+	sort.SliceStable(matching, func(i, j int) bool {
+		si := titleSimilarity(target, Candidate{Title: matching[i].Title, Channel: matching[i].Channel})
+		sj := titleSimilarity(target, Candidate{Title: matching[j].Title, Channel: matching[j].Channel})
+		return si > sj
+	})
+	return &matching[0], nil
+}
+
+// ytDLP returns the yt-dlp command to run.
+func (s *Searcher) ytDLP() string {
+	if cmd := strings.TrimSpace(s.ytDLPPath); cmd != "" {
+		return cmd
+	}
+	return "yt-dlp"
+}
@@ -0,0 +1,81 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFindAlbumPlaylists(t *testing.T) {
+	runner := &albumRunner{outputs: map[string]string{
+		"search": "https://music.youtube.com/browse/MPREb_deluxe\tPartie Traumatic (Deluxe)\n" +
+			"https://music.youtube.com/browse/MPREb_std\tPartie Traumatic\n" +
+			"https://www.youtube.com/playlist?list=PLfan\tfan mix\n",
+		"MPREb_deluxe": strings.Repeat("OLAK5uy_deluxe\t14\tAlbum - Partie Traumatic (Deluxe)\tBlack Kids\n", 14),
+		"MPREb_std":    strings.Repeat("OLAK5uy_std\tNA\tAlbum - Partie Traumatic\tNA\n", 10),
+		"PLfan":        "PLfan\t10\tfan mix\tsomeone\n",
+	}}
+	searcher := NewSearcher(runner, "")
+
+	playlists, err := searcher.FindAlbumPlaylists(context.Background(), "Black Kids", "Partie Traumatic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(playlists) != 2 {
+		t.Fatalf("expected 2 album playlists, got %+v", playlists)
+	}
+	if playlists[0].ID != "OLAK5uy_deluxe" || playlists[0].ItemCount != 14 || playlists[0].Channel != "Black Kids" {
+		t.Errorf("unexpected first playlist %+v", playlists[0])
+	}
+	if playlists[1].ItemCount != 10 || playlists[1].Channel != "" {
+		t.Errorf("expected items to be counted without playlist_count, got %+v", playlists[1])
+	}
+	if playlists[1].URL() != "https://music.youtube.com/playlist?list=OLAK5uy_std" {
+		t.Errorf("unexpected URL %q", playlists[1].URL())
+	}
+	if !strings.Contains(runner.calls[0], "search?q=Black+Kids+Partie+Traumatic#albums") {
+		t.Errorf("unexpected search command %q", runner.calls[0])
+	}
+}
+
+func TestPickAlbumPlaylist(t *testing.T) {
+	playlists := []AlbumPlaylist{
+		{ID: "OLAK5uy_deluxe", Title: "Album - Partie Traumatic (Deluxe)", ItemCount: 14},
+		{ID: "OLAK5uy_other", Title: "Album - Greatest Hits", ItemCount: 10},
+		{ID: "OLAK5uy_std", Title: "Album - Partie Traumatic", ItemCount: 10},
+	}
+
+	best, err := PickAlbumPlaylist(playlists, "Black Kids", "Partie Traumatic", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if best.ID != "OLAK5uy_std" {
+		t.Errorf("expected the matching title with 10 items, got %+v", best)
+	}
+
+	if _, err := PickAlbumPlaylist(playlists, "Black Kids", "Partie Traumatic", 12); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+}
+
+// albumRunner answers each yt-dlp call with the output registered for a
+// substring of its URL; the search URL is matched by "search".
+type albumRunner struct {
+	outputs map[string]string
+	calls   []string
+}
+
+func (r *albumRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	target := args[len(args)-1]
+	r.calls = append(r.calls, target)
+	if strings.Contains(target, "/search?") {
+		return r.outputs["search"], nil
+	}
+	for key, output := range r.outputs {
+		if key != "search" && strings.Contains(target, key) {
+			return output, nil
+		}
+	}
+	return "", errors.New("unexpected URL " + target)
+}
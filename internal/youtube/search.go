@@ -0,0 +1,286 @@
+// Package youtube finds YouTube videos for songs known from MusicBrainz,
+// using yt-dlp's search extractor.
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"iturtle-smart-fetcher/internal/downloader"
+)
+
+// searchTemplate is printed by yt-dlp once per search result. Fields are
+// tab-separated so titles and channel names survive intact.
+const searchTemplate = "%(id)s\t%(duration|0)s\t%(channel|)s\t%(uploader|)s\t%(title)s"
+
+// DefaultResults is the number of search results compared per track.
+const DefaultResults = 5
+
+// Candidate score weights. A matching title and length are what make a video
+// the right song; the channel separates the studio version from re-uploads.
+const (
+	weightTitle    = 40.0
+	weightDuration = 30.0
+	weightTopic    = 20.0
+	weightOfficial = 10.0
+	weightVariant  = 15.0
+)
+
+// minScore is the score below which a best candidate is rejected as unlikely
+// to be the right song.
+const minScore = 30.0
+
+// ErrNoMatch is returned when no search result is close enough to the track.
+var ErrNoMatch = errors.New("no matching video found")
+
+// variantWords mark versions other than the studio recording. They only count
+// against a candidate when the track title does not contain them.
+var variantWords = []string{"live", "cover", "remix", "karaoke", "instrumental", "acoustic", "sped", "slowed", "nightcore", "reaction", "8d"}
+
+// noiseWords are ignored when comparing titles.
+var noiseWords = map[string]bool{
+	"official": true, "video": true, "audio": true, "lyric": true, "lyrics": true,
+	"music": true, "hd": true, "hq": true, "4k": true, "topic": true, "visualizer": true,
+	"ft": true, "feat": true, "the": true, "a": true,
+}
+
+// Track describes the song being looked for.
+type Track struct {
+	Artist   string
+	Title    string
+	Duration time.Duration // Zero when unknown
+}
+
+// Query returns the search query for the track.
+func (t Track) Query() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Artist + " - " + t.Title
+}
+
+// Candidate is a search result.
+type Candidate struct {
+	ID       string
+	Title    string
+	Channel  string
+	Duration time.Duration
+}
+
+// URL returns the watch URL of the candidate.
+func (c Candidate) URL() string {
+	return "https://www.youtube.com/watch?v=" + c.ID
+}
+
+// RankedCandidate is a candidate with its score and the reasons behind it.
+type RankedCandidate struct {
+	Candidate
+	Score   float64
+	Reasons []string
+}
+
+// Searcher searches YouTube through yt-dlp.
+type Searcher struct {
+	runner    downloader.Runner
+	ytDLPPath string
+	// Results is the number of search results compared per track (default 5).
+	Results int
+}
+
+// NewSearcher creates a Searcher running yt-dlp at ytDLPPath (or from PATH).
+func NewSearcher(r downloader.Runner, ytDLPPath string) *Searcher {
+	if r == nil {
+		r = downloader.ExecRunner{}
+	}
+	return &Searcher{
+		runner:    r,
+		ytDLPPath: ytDLPPath,
+		Results:   DefaultResults,
+	}
+}
+
+// Search returns the top results for query without downloading anything.
+func (s *Searcher) Search(ctx context.Context, query string) ([]Candidate, error) {
+	n := s.Results
+	if n <= 0 {
+		n = DefaultResults
+	}
+
+	output, err := s.runner.Run(ctx, s.ytDLP(),
+		"--flat-playlist",
+		"--ignore-errors",
+		"--print", searchTemplate,
+		fmt.Sprintf("ytsearch%d:%s", n, query),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSearchOutput(output), nil
+}
+
+// FindTrack searches for the track and returns the best-ranked video.
+func (s *Searcher) FindTrack(ctx context.Context, track Track) (*RankedCandidate, error) {
+	candidates, err := s.Search(ctx, track.Query())
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	best := Rank(candidates, track)[0]
+	if best.Score < minScore {
+		return nil, fmt.Errorf("%w (best was %q, score %.0f)", ErrNoMatch, best.Title, best.Score)
+	}
+	return &best, nil
+}
+
+// Rank scores candidates against the track and returns them best first.
+// Candidates with equal scores keep their search order.
+func Rank(candidates []Candidate, track Track) []RankedCandidate {
+	ranked := make([]RankedCandidate, len(candidates))
+	trackWords := words(track.Title)
+
+	for i, c := range candidates {
+		r := RankedCandidate{Candidate: c}
+		add := func(points float64, reason string, args ...any) {
+			r.Score += points
+			if reason != "" {
+				r.Reasons = append(r.Reasons, fmt.Sprintf(reason, args...))
+			}
+		}
+
+		if sim := titleSimilarity(track, c); sim > 0 {
+			add(weightTitle*sim, "title %.0f%% similar", sim*100)
+		}
+
+		if track.Duration > 0 && c.Duration > 0 {
+			diff := absDuration(c.Duration - track.Duration)
+			// Full points within 3s, nothing beyond 30s (intros, music video skits)
+			const slack, tolerance = 3 * time.Second, 30 * time.Second
+			switch {
+			case diff <= slack:
+				add(weightDuration, "length within %s", diff.Round(time.Second))
+			case diff < tolerance:
+				add(weightDuration*(1-float64(diff)/float64(tolerance)), "length %s off", diff.Round(time.Second))
+			}
+		}
+
+		channel := strings.ToLower(c.Channel)
+		switch {
+		case strings.HasSuffix(channel, " - topic"):
+			// Auto-generated YouTube Music channels carry the studio recordings
+			add(weightTopic, "%s channel", c.Channel)
+		case strings.Contains(channel, "vevo") || strings.Contains(channel, "official") ||
+			(track.Artist != "" && strings.Contains(normalize(channel), normalize(track.Artist))):
+			add(weightOfficial, "official channel %s", c.Channel)
+		}
+
+		candidateWords := words(c.Title)
+		for _, variant := range variantWords {
+			if candidateWords[variant] && !trackWords[variant] {
+				add(-weightVariant, "%s version", variant)
+			}
+		}
+
+		ranked[i] = r
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// titleSimilarity returns the share of the track's title and artist words
+// found in the candidate title or channel, from 0 to 1. Title words count
+// twice as much as artist words, since the artist is often only in the
+// channel name.
+func titleSimilarity(track Track, c Candidate) float64 {
+	have := words(c.Title + " " + c.Channel)
+
+	var total, found float64
+	for w := range words(track.Title) {
+		total += 2
+		if have[w] {
+			found += 2
+		}
+	}
+	for w := range words(track.Artist) {
+		total++
+		if have[w] {
+			found++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return found / total
+}
+
+// words returns the set of normalized words in s, without noise words.
+func words(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(normalize(s)) {
+		if !noiseWords[w] {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// normalize lower-cases s and replaces punctuation with spaces.
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		if r == '\'' || r == '’' {
+			// "Don't" and "Dont" should match
+			return -1
+		}
+		return ' '
+	}, s)
+}
+
+// parseSearchOutput extracts candidates from yt-dlp output, skipping warnings
+// and other lines that do not follow searchTemplate.
+func parseSearchOutput(output string) []Candidate {
+	var candidates []Candidate
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 5)
+		if len(fields) != 5 || fields[0] == "" {
+			continue
+		}
+		c := Candidate{
+			ID:      fields[0],
+			Channel: fields[2],
+			Title:   fields[4],
+		}
+		if c.Channel == "" || c.Channel == "NA" {
+			c.Channel = fields[3]
+		}
+		if c.Channel == "NA" {
+			c.Channel = ""
+		}
+		if seconds, err := strconv.ParseFloat(fields[1], 64); err == nil && seconds > 0 {
+			c.Duration = time.Duration(seconds * float64(time.Second))
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,97 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSearchOutput(t *testing.T) {
+	output := "WARNING: something\n" +
+		"abc123\t201.0\tBlack Kids - Topic\tBlack Kids - Topic\tHurricane Jane\n" +
+		"def456\tNA\tNA\tsomeuser\tHurricane Jane (cover)\n"
+
+	candidates := parseSearchOutput(output)
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(candidates))
+	}
+	if candidates[0].Duration != 201*time.Second || candidates[0].Channel != "Black Kids - Topic" {
+		t.Errorf("unexpected first candidate: %+v", candidates[0])
+	}
+	if candidates[1].Channel != "someuser" || candidates[1].Duration != 0 {
+		t.Errorf("expected uploader fallback and unknown duration, got %+v", candidates[1])
+	}
+	if candidates[0].URL() != "https://www.youtube.com/watch?v=abc123" {
+		t.Errorf("unexpected URL %q", candidates[0].URL())
+	}
+}
+
+func TestRankPrefersTopicAndDuration(t *testing.T) {
+	track := Track{Artist: "Black Kids", Title: "Hurricane Jane", Duration: 201 * time.Second}
+	candidates := []Candidate{
+		{ID: "video", Title: "Black Kids - Hurricane Jane (Official Video)", Channel: "BlackKidsVEVO", Duration: 245 * time.Second},
+		{ID: "live", Title: "Black Kids - Hurricane Jane (Live at Glastonbury)", Channel: "fan", Duration: 203 * time.Second},
+		{ID: "topic", Title: "Hurricane Jane", Channel: "Black Kids - Topic", Duration: 200 * time.Second},
+		{ID: "other", Title: "Jane Says", Channel: "Jane's Addiction - Topic", Duration: 201 * time.Second},
+	}
+
+	ranked := Rank(candidates, track)
+	if ranked[0].ID != "topic" {
+		t.Fatalf("expected Topic upload first, got %s (%v)", ranked[0].ID, ranked[0].Reasons)
+	}
+	for _, r := range ranked {
+		if r.ID == "live" && r.Score >= ranked[0].Score {
+			t.Errorf("expected live version to rank below the studio version")
+		}
+	}
+}
+
+func TestRankKeepsVariantsNamedInTitle(t *testing.T) {
+	track := Track{Artist: "Artist", Title: "Song (Live)"}
+	ranked := Rank([]Candidate{{ID: "live", Title: "Artist - Song (Live)"}}, track)
+	for _, reason := range ranked[0].Reasons {
+		if strings.Contains(reason, "live version") {
+			t.Errorf("did not expect a penalty for a live track, got %v", ranked[0].Reasons)
+		}
+	}
+}
+
+func TestFindTrack(t *testing.T) {
+	runner := &searchRunner{output: "abc123\t201\tBlack Kids - Topic\t\tHurricane Jane\n"}
+	searcher := NewSearcher(runner, "/opt/yt-dlp")
+	searcher.Results = 3
+
+	best, err := searcher.FindTrack(context.Background(), Track{Artist: "Black Kids", Title: "Hurricane Jane", Duration: 201 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if best.ID != "abc123" {
+		t.Errorf("unexpected candidate %+v", best)
+	}
+	if runner.name != "/opt/yt-dlp" || runner.args[len(runner.args)-1] != "ytsearch3:Black Kids - Hurricane Jane" {
+		t.Errorf("unexpected command %s %v", runner.name, runner.args)
+	}
+}
+
+func TestFindTrackNoMatch(t *testing.T) {
+	runner := &searchRunner{output: "xyz\t600\tsomeone\t\tCompletely Different Thing\n"}
+	searcher := NewSearcher(runner, "")
+
+	_, err := searcher.FindTrack(context.Background(), Track{Artist: "Black Kids", Title: "Hurricane Jane", Duration: 201 * time.Second})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+}
+
+type searchRunner struct {
+	output string
+	name   string
+	args   []string
+}
+
+func (r *searchRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	r.name, r.args = name, args
+	return r.output, nil
+}